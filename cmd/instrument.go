@@ -11,7 +11,12 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/backup"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -28,8 +33,26 @@ const (
 )
 
 var (
-	diffFile    string
-	excludeDirs string
+	diffFile      string
+	excludeDirs   string
+	propagation   string
+	configFile    string
+	appName       string
+	segmentNaming string
+
+	ignoredErrors  []string
+	expectedErrors []string
+
+	onlyIntegrations     []string
+	disabledIntegrations []string
+	ruleFiles            []string
+	writeInPlace         bool
+	verify               bool
+	reportFile           string
+	reportFormat         string
+
+	// instrumentOptions holds the optional instrumentation behavior enabled by command line flags
+	instrumentOptions parser.Options
 )
 
 var instrumentCmd = &cobra.Command{
@@ -129,6 +152,18 @@ func Instrument(packagePath string, patterns ...string) {
 	}
 	outputFile, err := setOutputFilePath(diffFile, packagePath)
 	cobra.CheckErr(err)
+	instrumentOptions.Propagation, err = parser.ParsePropagationStrategy(propagation)
+	cobra.CheckErr(err)
+	_, _, err = loadConfig(packagePath, patterns)
+	cobra.CheckErr(err)
+	if writeInPlace {
+		cobra.CheckErr(backup.CanCreate(packagePath))
+	}
+	_, err = report.ParseFormat(reportFormat)
+	cobra.CheckErr(err)
+	if reportFile != "" {
+		cobra.CheckErr(comment.EnableReport(packagePath))
+	}
 	if debug {
 		comment.EnableConsolePrinter(packagePath)
 	}
@@ -143,20 +178,251 @@ func Instrument(packagePath string, patterns ...string) {
 	runTUIMode(packagePath, patterns, outputFile)
 }
 
+// parseErrorRules parses the errors that are not noticed, and the errors that are noticed as expected errors.
+func parseErrorRules(ignored, expected []string) ([]parser.ErrorRule, error) {
+	rules := []parser.ErrorRule{}
+	for _, errs := range []struct {
+		names    []string
+		expected bool
+	}{{ignored, false}, {expected, true}} {
+		for _, name := range errs.names {
+			rule, err := parser.ParseErrorRule(name, errs.expected)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// loadConfig loads the configuration of the application at packagePath from its configuration file, or the file passed
+// with --config, and overrides it with the command line flags that are not empty. It returns the configuration, and the
+// package patterns that are loaded.
+func loadConfig(packagePath string, patterns []string) (parser.Config, []string, error) {
+	cfg := parser.Config{
+		AppName:           defaultAppName,
+		AgentVariableName: defaultAgentVariableName,
+		Options:           instrumentOptions,
+	}
+
+	var err error
+	path := configFile
+	if path == "" {
+		if path, err = config.Find(packagePath); err != nil {
+			return cfg, nil, err
+		}
+	}
+	file := &config.Config{}
+	if path != "" {
+		if file, err = config.Load(path); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	if file.AppName != "" {
+		cfg.AppName = file.AppName
+	}
+	if file.AgentVariableName != "" {
+		cfg.AgentVariableName = file.AgentVariableName
+	}
+	cfg.ExcludePackages = file.Packages.Exclude
+	cfg.Options.Integrations = file.Integrations.Enabled
+	if len(onlyIntegrations) > 0 {
+		cfg.Options.Integrations = onlyIntegrations
+	}
+	cfg.Options.DisabledIntegrations = file.Integrations.Disabled
+	if len(disabledIntegrations) > 0 {
+		cfg.Options.DisabledIntegrations = disabledIntegrations
+	}
+	cfg.Options.TraceFunctions = file.Functions.Allow
+	cfg.Options.SkipFunctions = file.Functions.Deny
+	if len(patterns) == 0 {
+		patterns = file.Packages.Include
+	}
+
+	if appName != "" {
+		cfg.AppName = appName
+	}
+	naming := file.SegmentNaming
+	if segmentNaming != "" {
+		naming = segmentNaming
+	}
+	if naming != "" {
+		if cfg.Options.SegmentNames, err = parser.ParseSegmentNaming(naming); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	ignored, expected := file.Errors.Ignore, file.Errors.Expected
+	if len(ignoredErrors) > 0 {
+		ignored = ignoredErrors
+	}
+	if len(expectedErrors) > 0 {
+		expected = expectedErrors
+	}
+	if cfg.Options.ErrorRules, err = parseErrorRules(ignored, expected); err != nil {
+		return cfg, nil, err
+	}
+
+	// rule files in the configuration file are relative to the directory it is in
+	rulePaths := ruleFiles
+	if len(rulePaths) == 0 {
+		for _, rulePath := range file.Rules {
+			if !filepath.IsAbs(rulePath) {
+				rulePath = filepath.Join(filepath.Dir(path), rulePath)
+			}
+			rulePaths = append(rulePaths, rulePath)
+		}
+	}
+	cfg.Options.Rules, err = rules.LoadAll(rulePaths)
+	return cfg, patterns, err
+}
+
+// instrumentationStep is a step of the instrumentation pipeline.
+type instrumentationStep struct {
+	desc string
+	fn   func() error
+}
+
+// instrumentationSteps returns the steps of the instrumentation pipeline. The changes are written to the diff file, or
+// to the files of the application when --write is set, after backing up the files that are changed. The report is
+// written last when --report is set.
+// onProgress receives granular progress updates as each file is written.
+func instrumentationSteps(manager *parser.InstrumentationManager, packagePath string, onProgress func(string)) []instrumentationStep {
+	steps := []instrumentationStep{}
+	if !writeInPlace {
+		steps = append(steps, instrumentationStep{"Creating diff file", manager.CreateDiffFile})
+	}
+	steps = append(steps,
+		instrumentationStep{"Detecting dependencies", manager.DetectDependencyIntegrations},
+		instrumentationStep{"Tracing package calls", manager.TracePackageCalls},
+		instrumentationStep{"Scanning application", manager.ScanApplication},
+		instrumentationStep{"Instrumenting application", manager.InstrumentApplication},
+		instrumentationStep{"Resolving unit tests", manager.ResolveUnitTests},
+	)
+
+	if !writeInPlace {
+		steps = append(steps, instrumentationStep{"Adding required modules", manager.AddRequiredModules})
+		if verify {
+			steps = append(steps, instrumentationStep{"Verifying instrumentation", manager.Verify})
+		}
+		return withReport(manager, append(steps,
+			instrumentationStep{"Writing diff file", func() error {
+				comment.WriteAll()
+				return manager.WriteDiff(onProgress)
+			}},
+		))
+	}
+
+	// the backup is created once the application has been instrumented, since nothing is changed before then
+	var b *backup.Backup
+	steps = append(steps,
+		instrumentationStep{"Backing up module files", func() error {
+			var err error
+			if b, err = backup.Create(packagePath); err != nil {
+				return err
+			}
+			return backupModuleFiles(b, packagePath)
+		}},
+		instrumentationStep{"Adding required modules", manager.AddRequiredModules},
+	)
+	if verify {
+		// the module files are restored when the instrumentation does not build, since no other file was changed
+		steps = append(steps, instrumentationStep{"Verifying instrumentation", func() error {
+			err := manager.Verify()
+			if err != nil {
+				if _, restoreErr := backup.Restore(packagePath); restoreErr != nil {
+					return errors.Join(err, restoreErr)
+				}
+			}
+			return err
+		}})
+	}
+	return withReport(manager, append(steps,
+		instrumentationStep{"Writing files", func() error {
+			comment.WriteAll()
+			return manager.WriteFiles(onProgress, b.Save)
+		}},
+	))
+}
+
+// withReport adds the step that writes the report of the info and warning comments, and of the instrumentation that
+// was added, to the steps when --report is set.
+func withReport(manager *parser.InstrumentationManager, steps []instrumentationStep) []instrumentationStep {
+	if reportFile == "" {
+		return steps
+	}
+	return append(steps, instrumentationStep{"Writing report", func() error {
+		format, err := report.ParseFormat(reportFormat)
+		if err != nil {
+			return err
+		}
+		return report.Write(reportFile, format, report.Report{
+			Tool:     common.ApplicationName,
+			Version:  AppVersion,
+			Findings: comment.Findings(),
+			Summary:  manager.Summary(),
+		})
+	}})
+}
+
+// backupModuleFiles backs up the go.mod and go.sum files of the module that contains the application, which are
+// changed when the modules required by the instrumentation are added.
+func backupModuleFiles(b *backup.Backup, packagePath string) error {
+	dir, err := filepath.Abs(packagePath)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil // not in a module, so there are no module files to change
+		}
+		dir = parent
+	}
+
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if err := b.Save(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doneMessage describes where the changes, and the report, were written.
+func doneMessage(packagePath, outputFile string) string {
+	message := fmt.Sprintf("\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s\n", outputFile, outputFile)
+	if writeInPlace {
+		message = fmt.Sprintf("\nDone! Changes written to the files of %s\nTip: Restore the original files with: %s rollback %s\n", packagePath, common.ApplicationName, packagePath)
+	}
+	if reportFile != "" {
+		message += fmt.Sprintf("Report written to: %s\n", reportFile)
+	}
+	return message
+}
+
 // runTextMode runs the instrumentation pipeline with plain text output to stdout.
 // This is used when the TUI is unavailable (e.g. CI/CD, piped output) or when
 // the --debug flag is enabled. It delegates to instrumentPackages for the core
 // logic and handles printing status and exit on error.
 func runTextMode(packagePath string, patterns []string, outputFile string) {
 	fmt.Printf("Instrumentation started for %s\n", packagePath)
-	fmt.Printf("Output file: %s\n\n", outputFile)
+	if writeInPlace {
+		fmt.Printf("Writing changes in place, with a backup in %s\n\n", filepath.Join(packagePath, backup.DirName))
+	} else {
+		fmt.Printf("Output file: %s\n\n", outputFile)
+	}
 
 	if err := instrumentPackages(packagePath, patterns, outputFile); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s\n", outputFile, outputFile)
+	fmt.Print(doneMessage(packagePath, outputFile))
 }
 
 // instrumentPackages loads Go packages from packagePath, runs the full
@@ -164,7 +430,10 @@ func runTextMode(packagePath string, patterns []string, outputFile string) {
 // It returns an error rather than exiting the process, making it safe to
 // call from both runTextMode (which handles os.Exit) and from tests.
 func instrumentPackages(packagePath string, patterns []string, outputFile string) error {
-	loadPatterns := patterns
+	cfg, loadPatterns, err := loadConfig(packagePath, patterns)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
 	if len(loadPatterns) == 0 {
 		loadPatterns = []string{defaultPackageName}
 	}
@@ -175,24 +444,8 @@ func instrumentPackages(packagePath string, patterns []string, outputFile string
 		return fmt.Errorf("loading packages: %w", err)
 	}
 
-	manager := parser.NewInstrumentationManager(pkgs, defaultAppName, defaultAgentVariableName, outputFile, packagePath)
-
-	steps := []struct {
-		desc string
-		fn   func() error
-	}{
-		{"Creating diff file", manager.CreateDiffFile},
-		{"Detecting dependencies", manager.DetectDependencyIntegrations},
-		{"Tracing package calls", manager.TracePackageCalls},
-		{"Scanning application", manager.ScanApplication},
-		{"Instrumenting application", manager.InstrumentApplication},
-		{"Resolving unit tests", manager.ResolveUnitTests},
-		{"Adding required modules", manager.AddRequiredModules},
-		{"Writing diff file", func() error {
-			comment.WriteAll()
-			return manager.WriteDiff(func(msg string) {})
-		}},
-	}
+	manager := parser.NewInstrumentationManager(pkgs, cfg, outputFile, packagePath)
+	steps := instrumentationSteps(manager, packagePath, func(msg string) {})
 
 	for _, step := range steps {
 		if err := step.fn(); err != nil {
@@ -209,7 +462,11 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 
 	// Worker goroutine
 	go func() {
-		loadPatterns := patterns
+		cfg, loadPatterns, err := loadConfig(packagePath, patterns)
+		if err != nil {
+			updates <- errMsg(err)
+			return
+		}
 		if len(loadPatterns) == 0 {
 			loadPatterns = []string{defaultPackageName}
 		}
@@ -222,29 +479,13 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 
 		updates <- pkgLoadedMsg(pkgs)
 
-		manager := parser.NewInstrumentationManager(pkgs, defaultAppName, defaultAgentVariableName, outputFile, packagePath)
-
-		steps := []struct {
-			desc string
-			fn   func() error
-		}{
-			{"Creating diff file", manager.CreateDiffFile},
-			{"Detecting dependencies", manager.DetectDependencyIntegrations},
-			{"Tracing package calls", manager.TracePackageCalls},
-			{"Scanning application", manager.ScanApplication},
-			{"Instrumenting application", manager.InstrumentApplication},
-			{"Resolving unit tests", manager.ResolveUnitTests},
-			{"Adding required modules", manager.AddRequiredModules},
-			{"Writing diff file", func() error {
-				comment.WriteAll()
-				// Pass a callback to WriteDiff to receive granular progress updates.
-				// This callback updates the UI with the name of the file currently being written,
-				// avoiding a "stalled" UI during this potentially long-running step.
-				return manager.WriteDiff(func(msg string) {
-					updates <- progressMsg{desc: msg}
-				})
-			}},
-		}
+		manager := parser.NewInstrumentationManager(pkgs, cfg, outputFile, packagePath)
+
+		// The progress callback updates the UI with the name of the file currently being written,
+		// avoiding a "stalled" UI during this potentially long-running step.
+		steps := instrumentationSteps(manager, packagePath, func(msg string) {
+			updates <- progressMsg{desc: msg}
+		})
 
 		for _, step := range steps {
 			updates <- progressMsg{desc: step.desc}
@@ -272,7 +513,7 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 			os.Exit(1)
 		}
 		if m.done {
-			fmt.Printf("\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s\n", m.outputFile, m.outputFile)
+			fmt.Print(doneMessage(packagePath, m.outputFile))
 		}
 	}
 }
@@ -348,6 +589,27 @@ func padding(s string, width int) int {
 func init() {
 	instrumentCmd.Flags().StringVarP(&diffFile, "output", "o", defaultOutputFilePath, "specify diff output file path")
 	instrumentCmd.Flags().StringVarP(&excludeDirs, "exclude", "e", "", "comma-separated list of folders to exclude from instrumentation")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.RecordPathValues, "record-path-values", false, "record net/http ServeMux path wildcard values as transaction attributes")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.RewriteHttpMethods, "rewrite-http-methods", false, "rewrite http.Get, http.Head, http.Post and http.PostForm calls in traced functions so they can be instrumented")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.TraceMainGoroutines, "trace-main-goroutines", false, "start a background transaction for each goroutine launched from main that calls a function defined in the application")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.CallGraph, "call-graph", false, "use a call graph of the application to decide which functions are traced, including callbacks and functions stored in struct fields")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.PreserveExportedAPIs, "preserve-exported-apis", false, "keep the signatures of exported functions in importable packages unchanged, and pass the transaction to a variant of them instead")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.CapturePanics, "capture-panics", false, "configure the agent to record panics as errors, and recover panics in functions called with a transaction started in main or run in a new goroutine")
+	instrumentCmd.Flags().BoolVar(&instrumentOptions.DetailedErrors, "detailed-errors", false, "notice errors as a newrelic.Error with a class derived from the type of the error, and attributes for the function that noticed it and the call that returned it")
+	instrumentCmd.Flags().StringSliceVar(&ignoredErrors, "ignore-errors", nil, "comma-separated list of sentinel errors and error types that are not noticed, such as database/sql.ErrNoRows or *example.com/app/store.NotFoundError")
+	instrumentCmd.Flags().StringSliceVar(&expectedErrors, "expected-errors", nil, "comma-separated list of sentinel errors and error types that are noticed as expected errors, such as io.EOF or context.Canceled")
+	instrumentCmd.Flags().StringSliceVar(&onlyIntegrations, "only", nil, "comma-separated list of the only integrations that are used, such as grpc,http-client; see list-integrations")
+	instrumentCmd.Flags().StringSliceVar(&disabledIntegrations, "disable", nil, "comma-separated list of integrations that are not used, such as errors,slog; see list-integrations")
+	instrumentCmd.Flags().StringSliceVar(&ruleFiles, "rules", nil, "comma-separated list of rule files that describe additional integrations")
+	instrumentCmd.Flags().StringVar(&configFile, "config", "", fmt.Sprintf("path to the configuration file of the application; defaults to %s in the application directory", config.FileName))
+	instrumentCmd.Flags().StringVar(&appName, "app-name", defaultAppName, "name of the application reported to New Relic; read from the environment by the agent if it is not set")
+	instrumentCmd.Flags().StringVar(&segmentNaming, "segment-naming", "", "how segments of traced functions are named: \"function\" (default) uses the function name, \"method\" adds the receiver type of methods, \"qualified\" adds the package name")
+	instrumentCmd.Flags().StringVar(&propagation, "propagation", string(parser.PropagateTransaction), "how transactions are passed to functions that do not accept one: \"transaction\" adds a *newrelic.Transaction as their last parameter, \"context\" adds a context.Context as their first parameter")
+	instrumentCmd.Flags().BoolVar(&writeInPlace, "write", false, fmt.Sprintf("write the changes to the files of the application instead of a diff file, after backing up the original files in %s; restore them with rollback", backup.DirName))
+	instrumentCmd.Flags().BoolVar(&verify, "verify", false, "type check the instrumented application and run go vet on it before the changes are written, and fail if the instrumentation breaks the build")
+	instrumentCmd.Flags().StringVar(&reportFile, "report", "", "write a report of the info and warning comments added to the application, and a summary of the instrumentation, to this file")
+	instrumentCmd.Flags().StringVar(&reportFormat, "report-format", string(report.FormatJSON), "format of the report: \"json\" or \"sarif\", which CI systems can show as annotations of the code")
+	instrumentCmd.MarkFlagsMutuallyExclusive("write", "output")
 	cobra.MarkFlagFilename(instrumentCmd.Flags(), "output", ".diff") // for file completion
 
 	rootCmd.AddCommand(instrumentCmd)
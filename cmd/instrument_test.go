@@ -3,10 +3,13 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/parser"
 )
 
 func TestValidateOutputFile(t *testing.T) {
@@ -387,3 +390,157 @@ func TestInstrumentPackages_InvalidPackagePath(t *testing.T) {
 		t.Errorf("expected error about loading packages, got: %v", err)
 	}
 }
+
+func TestInstrumentationSteps(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  bool
+		verify bool
+		report string
+		expect []string
+	}{
+		{
+			name:   "writes a diff file",
+			expect: []string{"Creating diff file", "Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Adding required modules", "Writing diff file"},
+		},
+		{
+			name:   "backs up files before they are written in place",
+			write:  true,
+			expect: []string{"Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Backing up module files", "Adding required modules", "Writing files"},
+		},
+		{
+			name:   "verifies the instrumentation before the diff file is written",
+			verify: true,
+			expect: []string{"Creating diff file", "Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Adding required modules", "Verifying instrumentation", "Writing diff file"},
+		},
+		{
+			name:   "verifies the instrumentation before files are written in place",
+			write:  true,
+			verify: true,
+			expect: []string{"Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Backing up module files", "Adding required modules", "Verifying instrumentation", "Writing files"},
+		},
+		{
+			name:   "writes the report after the diff file",
+			report: "report.json",
+			expect: []string{"Creating diff file", "Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Adding required modules", "Writing diff file", "Writing report"},
+		},
+		{
+			name:   "writes the report after files are written in place",
+			write:  true,
+			report: "report.sarif",
+			expect: []string{"Detecting dependencies", "Tracing package calls", "Scanning application", "Instrumenting application", "Resolving unit tests", "Backing up module files", "Adding required modules", "Writing files", "Writing report"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeInPlace, verify, reportFile = tt.write, tt.verify, tt.report
+			defer func() { writeInPlace, verify, reportFile = false, false, "" }()
+
+			steps := instrumentationSteps(nil, ".", nil)
+			got := []string{}
+			for _, step := range steps {
+				got = append(got, step.desc)
+			}
+			if !slices.Equal(got, tt.expect) {
+				t.Errorf("expected steps %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	content := `app_name: checkout-service
+packages:
+  include: ["./cmd/..."]
+  exclude: ["./internal/generated/..."]
+functions:
+  deny: ["String"]
+segment_naming: method
+errors:
+  ignore: [database/sql.ErrNoRows]
+  expected: [io.EOF]
+rules: [rules.yaml]
+`
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ruleContent := `rules:
+  - name: kafka-consumer
+    match:
+      package: example.com/platform/kafka
+      functions: [NewConsumer]
+    insert:
+      after: "{{.Variable}}.Use(nrkafka.Middleware({{.Agent}}))"
+      imports: [example.com/platform/kafka/nrkafka]
+`
+	if err := os.WriteFile(filepath.Join(dir, "rules.yaml"), []byte(ruleContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("settings are loaded from the configuration file", func(t *testing.T) {
+		cfg, patterns, err := loadConfig(dir, nil)
+		if err != nil {
+			t.Fatalf("loadConfig failed: %v", err)
+		}
+		if cfg.AppName != "checkout-service" || cfg.AgentVariableName != defaultAgentVariableName {
+			t.Errorf("unexpected app name %q and agent variable name %q", cfg.AppName, cfg.AgentVariableName)
+		}
+		if !slices.Equal(patterns, []string{"./cmd/..."}) || !slices.Equal(cfg.ExcludePackages, []string{"./internal/generated/..."}) {
+			t.Errorf("unexpected package patterns %v and excluded packages %v", patterns, cfg.ExcludePackages)
+		}
+		if cfg.Options.SegmentNames != parser.SegmentNameMethod || !slices.Equal(cfg.Options.SkipFunctions, []string{"String"}) {
+			t.Errorf("unexpected segment naming %q and skipped functions %v", cfg.Options.SegmentNames, cfg.Options.SkipFunctions)
+		}
+		if len(cfg.Options.ErrorRules) != 2 || cfg.Options.ErrorRules[0].Expected || !cfg.Options.ErrorRules[1].Expected {
+			t.Errorf("unexpected error rules %v", cfg.Options.ErrorRules)
+		}
+		if len(cfg.Options.Rules) != 1 || cfg.Options.Rules[0].Name != "kafka-consumer" {
+			t.Errorf("unexpected rules %v", cfg.Options.Rules)
+		}
+	})
+
+	t.Run("flags override the configuration file", func(t *testing.T) {
+		appName, segmentNaming, expectedErrors = "billing-service", "qualified", []string{"context.Canceled", "io.EOF"}
+		onlyIntegrations, disabledIntegrations = []string{"grpc", "http-client"}, []string{"errors"}
+		defer func() {
+			appName, segmentNaming, expectedErrors = "", "", nil
+			onlyIntegrations, disabledIntegrations = nil, nil
+		}()
+
+		cfg, patterns, err := loadConfig(dir, []string{"./..."})
+		if err != nil {
+			t.Fatalf("loadConfig failed: %v", err)
+		}
+		if cfg.AppName != "billing-service" || cfg.Options.SegmentNames != parser.SegmentNameQualified {
+			t.Errorf("unexpected app name %q and segment naming %q", cfg.AppName, cfg.Options.SegmentNames)
+		}
+		if !slices.Equal(patterns, []string{"./..."}) {
+			t.Errorf("unexpected package patterns %v", patterns)
+		}
+		if len(cfg.Options.ErrorRules) != 3 {
+			t.Errorf("unexpected error rules %v", cfg.Options.ErrorRules)
+		}
+		if !slices.Equal(cfg.Options.Integrations, []string{"grpc", "http-client"}) || !slices.Equal(cfg.Options.DisabledIntegrations, []string{"errors"}) {
+			t.Errorf("unexpected integrations %v and disabled integrations %v", cfg.Options.Integrations, cfg.Options.DisabledIntegrations)
+		}
+	})
+
+	t.Run("invalid settings are an error", func(t *testing.T) {
+		segmentNaming = "long"
+		defer func() { segmentNaming = "" }()
+
+		if _, _, err := loadConfig(dir, nil); err == nil {
+			t.Error("expected error for invalid segment naming, got nil")
+		}
+	})
+
+	t.Run("missing rule files are an error", func(t *testing.T) {
+		ruleFiles = []string{filepath.Join(dir, "missing.yaml")}
+		defer func() { ruleFiles = nil }()
+
+		if _, _, err := loadConfig(dir, nil); err == nil {
+			t.Error("expected error for a missing rule file, got nil")
+		}
+	})
+}
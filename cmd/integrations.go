@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/spf13/cobra"
+)
+
+var listIntegrationsCmd = &cobra.Command{
+	Use:   "list-integrations",
+	Short: "list the integrations that can be selected",
+	Long:  "list the integrations that can be selected with --only and --disable, along with the library each of them instruments",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ruleList, err := rules.LoadAll(listRuleFiles)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printIntegrations(os.Stdout, ruleList))
+	},
+}
+
+var listRuleFiles []string
+
+// printIntegrations writes a table of the integrations that can be selected by name, followed by the integrations
+// described by rules.
+func printIntegrations(w io.Writer, ruleList []rules.Rule) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tLIBRARY\tDESCRIPTION")
+	for _, integration := range parser.Integrations() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", integration.Name, integration.Library, integration.Description)
+	}
+	for _, rule := range ruleList {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rule.Name, rule.Match.Package, rule.Description)
+	}
+	return tw.Flush()
+}
+
+func init() {
+	listIntegrationsCmd.Flags().StringSliceVar(&listRuleFiles, "rules", nil, "comma-separated list of rule files whose integrations are also listed")
+	rootCmd.AddCommand(listIntegrationsCmd)
+}
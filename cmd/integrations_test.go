@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+)
+
+func TestPrintIntegrations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ruleList := []rules.Rule{{Name: "kafka-consumer", Description: "adds middleware to kafka consumers", Match: rules.Match{Package: "example.com/platform/kafka"}}}
+	if err := printIntegrations(buf, ruleList); err != nil {
+		t.Fatalf("printIntegrations failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(parser.Integrations())+2 {
+		t.Fatalf("expected a header and a line for each integration and rule, got %d lines", len(lines))
+	}
+	if fields := strings.Fields(lines[0]); len(fields) != 3 || fields[0] != "NAME" {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	for i, integration := range parser.Integrations() {
+		if !strings.HasPrefix(lines[i+1], integration.Name+" ") || !strings.Contains(lines[i+1], integration.Library) {
+			t.Errorf("expected line for integration %s, got %q", integration.Name, lines[i+1])
+		}
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "kafka-consumer ") || !strings.Contains(last, "example.com/platform/kafka") {
+		t.Errorf("expected line for rule kafka-consumer, got %q", last)
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <path>",
+	Short: "restore the files changed by instrument --write",
+	Long:  fmt.Sprintf("restore the files of an application that were changed by instrument --write to their original contents from the backup in %s, and remove the files it created", backup.DirName),
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(rollback(args[0], os.Stdout))
+	},
+}
+
+// rollback restores the backup of the application at packagePath, and reports the files that were restored.
+func rollback(packagePath string, w io.Writer) error {
+	restored, err := backup.Restore(packagePath)
+	for _, path := range restored {
+		fmt.Fprintf(w, "Restored %s\n", path)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring backup: %w", err)
+	}
+	fmt.Fprintf(w, "\nDone! Restored %d files in %s\n", len(restored), packagePath)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/backup"
+)
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.go")
+	sumFile := filepath.Join(dir, "go.sum")
+	if err := os.WriteFile(mainFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := backup.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{mainFile, sumFile} {
+		if err := b.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := rollback(dir, buf); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	for _, expect := range []string{"Restored main.go\n", "Restored go.sum\n", "Restored 2 files"} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("expected output to contain %q, got:\n%s", expect, buf.String())
+		}
+	}
+
+	contents, err := os.ReadFile(mainFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "package main\n" {
+		t.Errorf("expected main.go to be restored, got %q", contents)
+	}
+	if _, err := os.Stat(sumFile); !os.IsNotExist(err) {
+		t.Errorf("expected go.sum to be removed, since it did not exist before the backup")
+	}
+
+	if err := rollback(dir, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error when there is no backup to restore")
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <path>",
+	Short: "report how ready an application is to be instrumented",
+	Long:  "scan an application and trace it without changing it, then report the frameworks it uses, its entry points, the functions that can be traced, and what can not be instrumented",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(scan(args[0], os.Stdout))
+	},
+}
+
+// scan finds how ready the application at packagePath is to be instrumented, and prints the readiness report.
+// The configuration file of the application is used, along with the integration and rule flags of instrument.
+func scan(packagePath string, w io.Writer) error {
+	if _, err := os.Stat(packagePath); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	var err error
+	if instrumentOptions.Propagation, err = parser.ParsePropagationStrategy(propagation); err != nil {
+		return err
+	}
+	cfg, patterns, err := loadConfig(packagePath, nil)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if len(patterns) == 0 {
+		patterns = []string{defaultPackageName}
+	}
+	if debug {
+		comment.EnableConsolePrinter(packagePath)
+	}
+
+	fmt.Fprintf(w, "Scanning %s\n", packagePath)
+	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, patterns...)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	manager := parser.NewInstrumentationManager(pkgs, cfg, "", packagePath)
+	readiness, err := manager.Scan()
+	comment.WriteAll()
+	if err != nil {
+		return fmt.Errorf("scanning application: %w", err)
+	}
+	return printReadiness(w, readiness)
+}
+
+// printReadiness writes the readiness report of an application.
+func printReadiness(w io.Writer, readiness *parser.Readiness) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "\nFrameworks detected: %d\n", len(readiness.Frameworks))
+	if len(readiness.Frameworks) > 0 {
+		fmt.Fprintln(tw, "  LIBRARY\tSTATUS\tINTEGRATIONS")
+	}
+	for _, f := range readiness.Frameworks {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", f.Library, f.Status(), strings.Join(f.Integrations, ", "))
+	}
+
+	fmt.Fprintf(tw, "\nEntry points found: %d\n", len(readiness.EntryPoints))
+	for _, entryPoint := range readiness.EntryPoints {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", entryPoint.Kind, entryPoint.Name, entryPoint.Position)
+	}
+
+	fmt.Fprintf(tw, "\nFunctions reachable: %d of %d\n", len(readiness.ReachableFunctions), readiness.DeclaredFunctions)
+	for _, fn := range readiness.ReachableFunctions {
+		fmt.Fprintf(tw, "  %s\t%s\n", fn.Name, fn.Position)
+	}
+
+	fmt.Fprintf(tw, "\nOutbound calls that can not be instrumented: %d\n", len(readiness.OutboundCalls))
+	printFindings(tw, readiness.OutboundCalls)
+
+	fmt.Fprintf(tw, "\nGoroutines skipped: %d\n", len(readiness.SkippedGoroutines))
+	printFindings(tw, readiness.SkippedGoroutines)
+
+	return tw.Flush()
+}
+
+// printFindings writes the position and message of findings.
+func printFindings(w io.Writer, findings []comment.Finding) {
+	for _, finding := range findings {
+		fmt.Fprintf(w, "  %s:%d:%d\t%s\n", finding.File, finding.Line, finding.Column, finding.Message)
+	}
+}
+
+func init() {
+	scanCmd.Flags().StringSliceVar(&onlyIntegrations, "only", nil, "comma-separated list of the only integrations that are used, such as grpc,http-client; see list-integrations")
+	scanCmd.Flags().StringSliceVar(&disabledIntegrations, "disable", nil, "comma-separated list of integrations that are not used, such as errors,slog; see list-integrations")
+	scanCmd.Flags().StringSliceVar(&ruleFiles, "rules", nil, "comma-separated list of rule files that describe additional integrations")
+	scanCmd.Flags().StringVar(&configFile, "config", "", "path to the configuration file of the application")
+	scanCmd.Flags().BoolVar(&instrumentOptions.TraceMainGoroutines, "trace-main-goroutines", false, "trace goroutines launched from main like instrument does with this flag")
+	scanCmd.Flags().BoolVar(&instrumentOptions.CallGraph, "call-graph", false, "decide which functions are reachable with a call graph like instrument does with this flag")
+	scanCmd.Flags().StringVar(&propagation, "propagation", string(parser.PropagateTransaction), "how transactions are passed to functions, like instrument does with this flag")
+	rootCmd.AddCommand(scanCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+)
+
+func TestPrintReadiness(t *testing.T) {
+	readiness := &parser.Readiness{
+		Frameworks: []parser.Framework{
+			{Library: "database/sql"},
+			{Library: "net/http", Integrations: []string{"http-client", "http-server"}},
+		},
+		EntryPoints: []parser.EntryPoint{
+			{Kind: "main", Name: "main", Position: "main.go:20"},
+			{Kind: "http handler", Name: "/users listUsers", Position: "main.go:22"},
+		},
+		DeclaredFunctions:  3,
+		ReachableFunctions: []parser.Function{{Name: "main.listUsers", Position: "main.go:40"}},
+		OutboundCalls: []comment.Finding{
+			{File: "client.go", Line: 12, Column: 2, Message: `the "http.Get()" net/http method can not be instrumented and its outbound traffic can not be traced`},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := printReadiness(buf, readiness); err != nil {
+		t.Fatalf("printReadiness failed: %v", err)
+	}
+	output := buf.String()
+
+	expected := [][]string{
+		{"Frameworks detected: 2"},
+		{"database/sql", "unsupported"},
+		{"net/http", "supported", "http-client, http-server"},
+		{"Entry points found: 2"},
+		{"http handler", "/users listUsers", "main.go:22"},
+		{"Functions reachable: 1 of 3"},
+		{"main.listUsers", "main.go:40"},
+		{"Outbound calls that can not be instrumented: 1"},
+		{"client.go:12:2", `the "http.Get()" net/http method`},
+		{"Goroutines skipped: 0"},
+	}
+	lines := strings.Split(output, "\n")
+	for _, parts := range expected {
+		found := false
+		for _, line := range lines {
+			matches := true
+			for _, part := range parts {
+				matches = matches && strings.Contains(line, part)
+			}
+			found = found || matches
+		}
+		if !found {
+			t.Errorf("expected a line with %q, got:\n%s", parts, output)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/backup"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
+)
+
+const defaultUninstrumentDiffFileName = "new-relic-uninstrumentation.diff"
+
+var (
+	uninstrumentDiffFile string
+	uninstrumentInPlace  bool
+)
+
+var uninstrumentCmd = &cobra.Command{
+	Use:   "uninstrument <path>",
+	Short: "remove instrumentation",
+	Long:  "remove the instrumentation that was added to an application by instrument, and write these changes to a diff file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(uninstrument(args[0], uninstrumentDiffFile, uninstrumentInPlace, os.Stdout))
+	},
+}
+
+// uninstrument removes the generated instrumentation from the application at packagePath. The changes are written to
+// outputFile, or to the files of the application when inPlace is set, after backing up the files that are changed.
+func uninstrument(packagePath, outputFile string, inPlace bool, w io.Writer) error {
+	if _, err := os.Stat(packagePath); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if inPlace {
+		if err := backup.CanCreate(packagePath); err != nil {
+			return err
+		}
+	} else {
+		if outputFile == "" {
+			outputFile = filepath.Join(packagePath, defaultUninstrumentDiffFileName)
+		}
+		if err := validateOutputFile(outputFile); err != nil {
+			return err
+		}
+	}
+
+	_, patterns, err := loadConfig(packagePath, nil)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if len(patterns) == 0 {
+		patterns = []string{defaultPackageName}
+	}
+
+	fmt.Fprintf(w, "Removing instrumentation from %s\n", packagePath)
+	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, patterns...)
+	if err != nil {
+		return fmt.Errorf("loading packages: %w", err)
+	}
+
+	manager := parser.NewInstrumentationManager(pkgs, parser.Config{}, outputFile, packagePath)
+	if err := manager.RemoveInstrumentation(); err != nil {
+		return fmt.Errorf("removing instrumentation: %w", err)
+	}
+	comment.WriteAll()
+
+	if inPlace {
+		b, err := backup.Create(packagePath)
+		if err != nil {
+			return err
+		}
+		if err := manager.WriteFiles(nil, b.Save); err != nil {
+			return fmt.Errorf("writing files: %w", err)
+		}
+		fmt.Fprintf(w, "\nDone! Changes written to the files of %s\nTip: Restore the original files with: %s rollback %s\n", packagePath, common.ApplicationName, packagePath)
+		return nil
+	}
+
+	if err := manager.CreateDiffFile(); err != nil {
+		return fmt.Errorf("creating diff file: %w", err)
+	}
+	if err := manager.WriteDiff(nil); err != nil {
+		return fmt.Errorf("writing diff file: %w", err)
+	}
+	fmt.Fprintf(w, "\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s\n", outputFile, outputFile)
+	return nil
+}
+
+func init() {
+	uninstrumentCmd.Flags().StringVarP(&uninstrumentDiffFile, "output", "o", defaultOutputFilePath, fmt.Sprintf("specify diff output file path; defaults to %s in the application directory", defaultUninstrumentDiffFileName))
+	uninstrumentCmd.Flags().BoolVar(&uninstrumentInPlace, "write", false, fmt.Sprintf("write the changes to the files of the application instead of a diff file, after backing up the original files in %s; restore them with rollback", backup.DirName))
+	uninstrumentCmd.MarkFlagsMutuallyExclusive("output", "write")
+	cobra.MarkFlagFilename(uninstrumentCmd.Flags(), "output", ".diff") // for file completion
+	rootCmd.AddCommand(uninstrumentCmd)
+}
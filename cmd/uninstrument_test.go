@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUninstrument(t *testing.T) {
+	// the instrumentation of this application was written by hand, so there is nothing to remove
+	packagePath := filepath.Join("..", "end-to-end-tests", "semi-instrumented", "existing-transactions")
+	if _, err := os.Stat(packagePath); err != nil {
+		t.Skipf("test fixture not found: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.diff")
+	buf := &bytes.Buffer{}
+	if err := uninstrument(packagePath, outputFile, false, buf); err != nil {
+		t.Fatalf("uninstrument failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "git apply "+outputFile) {
+		t.Errorf("expected output to explain how to apply the diff, got:\n%s", buf.String())
+	}
+
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("output file not created: %v", err)
+	}
+	if info.Size() != 0 {
+		contents, _ := os.ReadFile(outputFile)
+		t.Errorf("expected no changes to instrumentation written by hand, got:\n%s", contents)
+	}
+}
+
+func TestUninstrument_InvalidArguments(t *testing.T) {
+	tests := []struct {
+		name        string
+		packagePath string
+		outputFile  string
+		expectErr   string
+	}{
+		{
+			name:        "invalid package path",
+			packagePath: "/nonexistent/path/to/package",
+			expectErr:   "invalid path",
+		},
+		{
+			name:        "invalid output file",
+			packagePath: t.TempDir(),
+			outputFile:  filepath.Join(t.TempDir(), "output.txt"),
+			expectErr:   ".diff extension",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uninstrument(tt.packagePath, tt.outputFile, false, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("expected an error containing %q, got: %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Package backup keeps the original contents of the files that are changed when instrumentation is written in place,
+// so that they can be restored exactly.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// DirName is the name of the backup directory in the root directory of an application.
+	DirName = ".go-easy-instrumentation-backup"
+
+	manifestName = "manifest.json"
+)
+
+// Manifest lists the files in a backup.
+type Manifest struct {
+	Created time.Time `json:"created"`
+	Files   []File    `json:"files"`
+}
+
+// File is a file that was backed up before it was changed.
+type File struct {
+	Path    string      `json:"path"`    // the path of the file, relative to the application directory
+	Existed bool        `json:"existed"` // false if the file was created, so that it is removed when the backup is restored
+	Mode    os.FileMode `json:"mode"`
+	Copy    string      `json:"copy,omitempty"` // the name of the copy of the original contents in the backup directory
+}
+
+// Backup is the backup of the files of an application that are changed by a single run of the tool.
+type Backup struct {
+	root     string
+	dir      string
+	manifest Manifest
+	saved    map[string]bool
+}
+
+// Exists returns true if the application in root has a backup that has not been restored.
+func Exists(root string) bool {
+	_, err := os.Stat(filepath.Join(root, DirName, manifestName))
+	return err == nil
+}
+
+// CanCreate returns an error if the application in root already has a backup, so that the original contents of its
+// files are not replaced by the changes of an earlier run.
+func CanCreate(root string) error {
+	if Exists(root) {
+		return fmt.Errorf("a backup of a previous run exists in %s: run rollback to restore it, or delete it to keep the changes", filepath.Join(root, DirName))
+	}
+	return nil
+}
+
+// Create starts a backup of the application in root. It is an error if the application already has a backup.
+func Create(root string) (*Backup, error) {
+	if err := CanCreate(root); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, DirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	b := &Backup{
+		root:     root,
+		dir:      dir,
+		manifest: Manifest{Created: time.Now(), Files: []File{}},
+		saved:    map[string]bool{},
+	}
+	return b, b.writeManifest()
+}
+
+// Save copies the contents of a file into the backup before it is changed. Files that do not exist are recorded so
+// that they are removed when the backup is restored. Saving a file more than once keeps its first contents.
+func (b *Backup) Save(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if b.saved[abs] {
+		return nil
+	}
+
+	absRoot, err := filepath.Abs(b.root)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil {
+		return err
+	}
+
+	file := File{Path: filepath.ToSlash(rel)}
+	info, err := os.Stat(abs)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		contents, err := os.ReadFile(abs)
+		if err != nil {
+			return err
+		}
+		file.Existed = true
+		file.Mode = info.Mode().Perm()
+		file.Copy = strconv.Itoa(len(b.manifest.Files))
+		if err := os.WriteFile(filepath.Join(b.dir, file.Copy), contents, 0644); err != nil {
+			return fmt.Errorf("backing up %s: %w", path, err)
+		}
+	}
+
+	b.saved[abs] = true
+	b.manifest.Files = append(b.manifest.Files, file)
+	return b.writeManifest()
+}
+
+// writeManifest writes the manifest after every change to the backup, so that the files changed before a failure can
+// still be restored.
+func (b *Backup) writeManifest() error {
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, manifestName), data, 0644)
+}
+
+// Restore restores the files in the backup of the application in root to their original contents, removes the files
+// that did not exist, and deletes the backup. It returns the paths of the restored files, relative to root.
+func Restore(root string) ([]string, error) {
+	dir := filepath.Join(root, DirName)
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no backup found in %s", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := Manifest{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("reading backup manifest: %w", err)
+	}
+
+	restored := []string{}
+	for _, file := range manifest.Files {
+		path := filepath.Join(root, filepath.FromSlash(file.Path))
+		if !file.Existed {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return restored, err
+			}
+			restored = append(restored, file.Path)
+			continue
+		}
+
+		contents, err := os.ReadFile(filepath.Join(dir, file.Copy))
+		if err != nil {
+			return restored, fmt.Errorf("reading backup of %s: %w", file.Path, err)
+		}
+		if err := os.WriteFile(path, contents, file.Mode); err != nil {
+			return restored, err
+		}
+		// WriteFile does not change the permissions of files that exist
+		if err := os.Chmod(path, file.Mode); err != nil {
+			return restored, err
+		}
+		restored = append(restored, file.Path)
+	}
+
+	return restored, os.RemoveAll(dir)
+}
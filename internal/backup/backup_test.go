@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path, contents string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string // the original files, by their path relative to the application
+		save   []string          // the files that are saved, in order
+		change map[string]string // the contents written to each file after it is saved
+		expect []string
+	}{
+		{
+			name:   "restores changed files",
+			files:  map[string]string{"main.go": "package main\n", "internal/db/db.go": "package db\n"},
+			save:   []string{"main.go", "internal/db/db.go"},
+			change: map[string]string{"main.go": "package main // changed\n", "internal/db/db.go": "package db // changed\n"},
+			expect: []string{"main.go", "internal/db/db.go"},
+		},
+		{
+			name:   "removes files that did not exist",
+			files:  map[string]string{"go.mod": "module example.com/app\n"},
+			save:   []string{"go.mod", "go.sum"},
+			change: map[string]string{"go.mod": "module example.com/app // changed\n", "go.sum": "checksums\n"},
+			expect: []string{"go.mod", "go.sum"},
+		},
+		{
+			name:   "keeps the first contents of files saved more than once",
+			files:  map[string]string{"main.go": "package main\n"},
+			save:   []string{"main.go", "main.go"},
+			change: map[string]string{"main.go": "package main // changed\n"},
+			expect: []string{"main.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, contents := range tt.files {
+				writeFile(t, filepath.Join(root, name), contents, 0640)
+			}
+
+			b, err := Create(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// files are changed after they are saved, so that saving a file again must not back up its changes
+			for _, name := range tt.save {
+				if err := b.Save(filepath.Join(root, name)); err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, filepath.Join(root, name), tt.change[name], 0644)
+			}
+			assert.True(t, Exists(root))
+
+			restored, err := Restore(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, restored)
+			assert.False(t, Exists(root))
+			assert.NoDirExists(t, filepath.Join(root, DirName))
+
+			for _, name := range tt.expect {
+				path := filepath.Join(root, name)
+				original, existed := tt.files[name]
+				if !existed {
+					assert.NoFileExists(t, path)
+					continue
+				}
+				assert.Equal(t, original, readFile(t, path))
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	root := t.TempDir()
+	if err := CanCreate(root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(root); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, CanCreate(root))
+	_, err := Create(root)
+	assert.Error(t, err)
+}
+
+func TestRestoreWithoutBackup(t *testing.T) {
+	_, err := Restore(t.TempDir())
+	assert.ErrorContains(t, err, "no backup found")
+}
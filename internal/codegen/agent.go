@@ -12,7 +12,7 @@ const (
 	agentErrorVariableName  string = "agentInitError"
 )
 
-func InitializeAgent(AppName, AgentVariableName string) []dst.Stmt {
+func InitializeAgent(AppName, AgentVariableName string, configOptions ...dst.Expr) []dst.Stmt {
 	newappArgs := []dst.Expr{
 		&dst.CallExpr{
 			Fun: &dst.Ident{
@@ -21,6 +21,8 @@ func InitializeAgent(AppName, AgentVariableName string) []dst.Stmt {
 			},
 		},
 	}
+	// options are applied after the environment, so that they are not overwritten by it
+	newappArgs = append(newappArgs, configOptions...)
 	if AppName != "" {
 		AppName = "\"" + AppName + "\""
 		newappArgs = append([]dst.Expr{&dst.CallExpr{
@@ -61,6 +63,47 @@ func InitializeAgent(AppName, AgentVariableName string) []dst.Stmt {
 	return []dst.Stmt{agentInit, panicOnError(agentErrorVariableName)}
 }
 
+// ConfigRecordPanics generates a config option that makes a deferred call to Transaction.End recover panics,
+// record them as errors, and then re-panic them.
+//
+//	func(cfg *newrelic.Config) {
+//		cfg.ErrorCollector.RecordPanics = true
+//	}
+func ConfigRecordPanics() dst.Expr {
+	return &dst.FuncLit{
+		Type: &dst.FuncType{
+			Params: &dst.FieldList{
+				List: []*dst.Field{
+					{
+						Names: []*dst.Ident{dst.NewIdent("cfg")},
+						Type:  &dst.StarExpr{X: &dst.Ident{Name: "Config", Path: NewRelicAgentImportPath}},
+					},
+				},
+			},
+		},
+		Body: &dst.BlockStmt{
+			List: []dst.Stmt{
+				&dst.AssignStmt{
+					Lhs: []dst.Expr{
+						&dst.SelectorExpr{
+							X:   &dst.SelectorExpr{X: dst.NewIdent("cfg"), Sel: dst.NewIdent("ErrorCollector")},
+							Sel: dst.NewIdent("RecordPanics"),
+						},
+					},
+					Tok: token.ASSIGN,
+					Rhs: []dst.Expr{dst.NewIdent("true")},
+					Decs: dst.AssignStmtDecorations{
+						NodeDecs: dst.NodeDecs{
+							Before: dst.NewLine,
+							After:  dst.NewLine,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 func ShutdownAgent(AgentVariableName string) *dst.ExprStmt {
 	return &dst.ExprStmt{
 		X: &dst.CallExpr{
@@ -52,3 +52,13 @@ func NewContextParameter(name string) *dst.Field {
 		},
 	}
 }
+
+// BackgroundContext returns a call to `context.Background()`
+func BackgroundContext() dst.Expr {
+	return &dst.CallExpr{
+		Fun: &dst.Ident{
+			Name: "Background",
+			Path: "context",
+		},
+	}
+}
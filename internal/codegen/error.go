@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/token"
 	"go/types"
+	"strconv"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -11,14 +12,39 @@ import (
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 )
 
+const (
+	// ErrorFunctionAttribute is the attribute of a detailed error that contains the function the error was noticed in.
+	ErrorFunctionAttribute = "code.function"
+	// ErrorSourceAttribute is the attribute of a detailed error that contains the function call that returned the error.
+	ErrorSourceAttribute = "error.source"
+	// CapturedReturnComment starts the comment on the assignment that captures the values returned by a call, so that its error can be noticed.
+	CapturedReturnComment = "// generated by " + common.ApplicationName + "; "
+)
+
+// ErrorDetails describe an error, so that it can be noticed as a newrelic.Error with a class and attributes.
+type ErrorDetails struct {
+	Class    string // the type of the error; the dynamic type of the error is used if this is empty
+	Function string // the function that the error is noticed in
+	Source   string // the function call that returned the error
+}
+
+// ErrorRule decides if errors that match it are noticed. Errors match a rule if they wrap a sentinel error, or an error of a type.
+type ErrorRule struct {
+	Target   dst.Expr // the sentinel error, or the type of error that errors are matched against
+	Type     bool     // Target is a type, and errors are matched with errors.As rather than errors.Is
+	Expected bool     // errors that match the rule are noticed as expected errors; otherwise they are not noticed
+}
+
 // IfErrorNotNilNoticeError creates an if statement that checks if the errorVariable is not nil, and calls notice error if its not nil
+// If details are passed, the error is noticed as a newrelic.Error with a class and attributes. If rules are passed, the error is
+// noticed according to the rules it matches.
 //
 // Example:
 //
 //	if err != nil {
 //		txn.NoticeError(err)
 //	}
-func IfErrorNotNilNoticeError(errorVariable, transactionVariable dst.Expr) *dst.IfStmt {
+func IfErrorNotNilNoticeError(errorVariable, transactionVariable dst.Expr, rules []ErrorRule, details ...ErrorDetails) *dst.IfStmt {
 	return &dst.IfStmt{
 		Cond: &dst.BinaryExpr{
 			X:  dst.Clone(errorVariable).(dst.Expr),
@@ -27,14 +53,15 @@ func IfErrorNotNilNoticeError(errorVariable, transactionVariable dst.Expr) *dst.
 		},
 		Body: &dst.BlockStmt{
 			List: []dst.Stmt{
-				NoticeError(dst.Clone(errorVariable).(dst.Expr), transactionVariable, nil),
+				NoticeErrorWithRules(NoticeError(dst.Clone(errorVariable).(dst.Expr), transactionVariable, nil, details...), errorVariable, rules),
 			},
 		},
 	}
 }
 
 // NoticeError Generates a statement that calls txn.NoticeError(err)
-func NoticeError(errExpr, transactionVariable dst.Expr, stmtBlock dst.Stmt) *dst.ExprStmt {
+// If details are passed, the error is noticed as a newrelic.Error with a class and attributes.
+func NoticeError(errExpr, transactionVariable dst.Expr, stmtBlock dst.Stmt, details ...ErrorDetails) *dst.ExprStmt {
 	var decs dst.ExprStmtDecorations
 	// copy all decs below the current statement into this statement
 	if stmtBlock != nil {
@@ -44,6 +71,11 @@ func NoticeError(errExpr, transactionVariable dst.Expr, stmtBlock dst.Stmt) *dst
 		stmtBlock.Decorations().Start.Clear()
 	}
 
+	noticed := dst.Clone(errExpr).(dst.Expr)
+	if len(details) > 0 {
+		noticed = DetailedError(errExpr, details[0])
+	}
+
 	return &dst.ExprStmt{
 		X: &dst.CallExpr{
 			Fun: &dst.SelectorExpr{
@@ -52,17 +84,159 @@ func NoticeError(errExpr, transactionVariable dst.Expr, stmtBlock dst.Stmt) *dst
 					Name: "NoticeError",
 				},
 			},
-			Args: []dst.Expr{dst.Clone(errExpr).(dst.Expr)},
+			Args: []dst.Expr{noticed},
 		},
 		Decs: decs,
 	}
 }
 
+// NoticeErrorWithRules guards a statement generated by NoticeError with the rules that the noticed error may match. Errors that
+// match a rule that expects them are noticed as expected errors, and errors that match a rule that ignores them are not noticed.
+// The statement is returned as it is if there are no rules.
+//
+//	if errors.Is(err, io.EOF) {
+//		txn.NoticeExpectedError(err)
+//	} else if !errors.Is(err, sql.ErrNoRows) && !errors.As(err, new(*NotFoundError)) {
+//		txn.NoticeError(err)
+//	}
+func NoticeErrorWithRules(notice *dst.ExprStmt, errExpr dst.Expr, rules []ErrorRule) dst.Stmt {
+	if len(rules) == 0 {
+		return notice
+	}
+
+	var expected, ignored dst.Expr
+	for _, rule := range rules {
+		if rule.Expected {
+			expected = joinConditions(expected, token.LOR, matchesErrorRule(errExpr, rule))
+		} else {
+			ignored = joinConditions(ignored, token.LAND, &dst.UnaryExpr{Op: token.NOT, X: matchesErrorRule(errExpr, rule)})
+		}
+	}
+
+	// the guard takes the place of the notice statement, along with its decorations
+	decs := notice.Decs.NodeDecs
+	notice.Decs.Before = dst.None
+	notice.Decs.Start.Clear()
+
+	var stmt dst.Stmt = notice
+	if ignored != nil {
+		stmt = &dst.IfStmt{
+			Cond: ignored,
+			Body: &dst.BlockStmt{List: []dst.Stmt{notice}},
+		}
+	}
+	if expected != nil {
+		expectedNotice := dst.Clone(notice).(*dst.ExprStmt)
+		if call, ok := expectedNotice.X.(*dst.CallExpr); ok {
+			if sel, ok := call.Fun.(*dst.SelectorExpr); ok {
+				sel.Sel = dst.NewIdent("NoticeExpectedError")
+			}
+		}
+		if ignored == nil {
+			stmt = &dst.BlockStmt{List: []dst.Stmt{notice}}
+		}
+		stmt = &dst.IfStmt{
+			Cond: expected,
+			Body: &dst.BlockStmt{List: []dst.Stmt{expectedNotice}},
+			Else: stmt,
+		}
+	}
+
+	stmt.Decorations().Before = decs.Before
+	stmt.Decorations().Start = decs.Start
+	return stmt
+}
+
+// matchesErrorRule generates an expression that checks if an error matches a rule.
+//
+//	errors.Is(err, sql.ErrNoRows)
+//	errors.As(err, new(*NotFoundError))
+func matchesErrorRule(errExpr dst.Expr, rule ErrorRule) dst.Expr {
+	function := "Is"
+	target := dst.Clone(rule.Target).(dst.Expr)
+	if rule.Type {
+		function = "As"
+		target = &dst.CallExpr{Fun: dst.NewIdent("new"), Args: []dst.Expr{target}}
+	}
+	return &dst.CallExpr{
+		Fun:  &dst.Ident{Name: function, Path: "errors"},
+		Args: []dst.Expr{dst.Clone(errExpr).(dst.Expr), target},
+	}
+}
+
+// joinConditions joins two conditions with a binary operator. The condition is returned as it is if there is nothing to join it to.
+func joinConditions(x dst.Expr, op token.Token, y dst.Expr) dst.Expr {
+	if x == nil {
+		return y
+	}
+	return &dst.BinaryExpr{X: x, Op: op, Y: y}
+}
+
+// DetailedError generates a newrelic.Error for an error, so that errors are grouped by their class in the UI.
+//
+//	newrelic.Error{
+//		Message: err.Error(),
+//		Class:   "*url.Error",
+//		Attributes: map[string]interface{}{
+//			"code.function": "fetch",
+//			"error.source":  "http.Get",
+//		},
+//	}
+func DetailedError(errExpr dst.Expr, details ErrorDetails) dst.Expr {
+	var class dst.Expr = &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(details.Class)}
+	if details.Class == "" {
+		class = &dst.CallExpr{
+			Fun:  &dst.Ident{Name: "Sprintf", Path: "fmt"},
+			Args: []dst.Expr{&dst.BasicLit{Kind: token.STRING, Value: `"%T"`}, dst.Clone(errExpr).(dst.Expr)},
+		}
+	}
+
+	fields := []dst.Expr{
+		&dst.KeyValueExpr{
+			Key: dst.NewIdent("Message"),
+			Value: &dst.CallExpr{
+				Fun: &dst.SelectorExpr{X: dst.Clone(errExpr).(dst.Expr), Sel: dst.NewIdent("Error")},
+			},
+		},
+		&dst.KeyValueExpr{Key: dst.NewIdent("Class"), Value: class},
+	}
+
+	attributes := []dst.Expr{}
+	for _, attribute := range [][2]string{{ErrorFunctionAttribute, details.Function}, {ErrorSourceAttribute, details.Source}} {
+		if attribute[1] == "" {
+			continue
+		}
+		attributes = append(attributes, &dst.KeyValueExpr{
+			Key:   &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(attribute[0])},
+			Value: &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(attribute[1])},
+			Decs:  dst.KeyValueExprDecorations{NodeDecs: dst.NodeDecs{Before: dst.NewLine, After: dst.NewLine}},
+		})
+	}
+	if len(attributes) > 0 {
+		fields = append(fields, &dst.KeyValueExpr{
+			Key: dst.NewIdent("Attributes"),
+			Value: &dst.CompositeLit{
+				Type: &dst.MapType{Key: dst.NewIdent("string"), Value: &dst.InterfaceType{Methods: &dst.FieldList{Opening: true, Closing: true}}},
+				Elts: attributes,
+			},
+		})
+	}
+
+	for _, field := range fields {
+		field.Decorations().Before = dst.NewLine
+		field.Decorations().After = dst.NewLine
+	}
+	return &dst.CompositeLit{
+		Type: &dst.Ident{Name: "Error", Path: NewRelicAgentImportPath},
+		Elts: fields,
+	}
+}
+
 // CaptureErrorReturnCallExpression checks if the return values of a function call is an error, and generates code to assign the return values to variables,
 // check if the error is not nil, and call txn.NoticeError(err) if the error is not nil.
 // It returns the statements that need to be added to the tree, and the expressions that are assigned to the return values of the function call.
 // The list of expressions can be used to replace the expression in the return statement.
-func CaptureErrorReturnCallExpression(pkg *decorator.Package, call *dst.CallExpr, transactionVariable dst.Expr) ([]dst.Stmt, []dst.Expr) {
+func CaptureErrorReturnCallExpression(pkg *decorator.Package, call *dst.CallExpr, transactionVariable dst.Expr, rules []ErrorRule, details ...ErrorDetails) ([]dst.Stmt, []dst.Expr) {
 	t := util.TypeOf(call, pkg)
 	if t == nil {
 		return nil, nil
@@ -101,7 +275,7 @@ func CaptureErrorReturnCallExpression(pkg *decorator.Package, call *dst.CallExpr
 		return nil, nil
 	}
 
-	typesHeader := fmt.Sprintf("// generated by %s; ", common.ApplicationName)
+	typesHeader := CapturedReturnComment
 	variableAssignments := make([]dst.Expr, numReturnVariables)
 	assignmentReturns := make([]dst.Expr, numReturnVariables) // this is a duplicate of variableAssignments so it can be used again in the tree
 	for indx := range variableAssignments {
@@ -124,8 +298,78 @@ func CaptureErrorReturnCallExpression(pkg *decorator.Package, call *dst.CallExpr
 			},
 		},
 	}
-	errCapture := IfErrorNotNilNoticeError(variableAssignments[errorIndex], transactionVariable)
+	errCapture := IfErrorNotNilNoticeError(variableAssignments[errorIndex], transactionVariable, rules, details...)
 	retStmts := []dst.Stmt{assignStmt, errCapture}
 
 	return retStmts, assignmentReturns
 }
+
+// RecoveredError generates an expression that wraps a value returned by recover() in an error, so that it can be noticed.
+//
+//	fmt.Errorf("panic: %v", r)
+func RecoveredError(recovered dst.Expr) dst.Expr {
+	return &dst.CallExpr{
+		Fun: &dst.Ident{
+			Name: "Errorf",
+			Path: "fmt",
+		},
+		Args: []dst.Expr{
+			&dst.BasicLit{
+				Kind:  token.STRING,
+				Value: `"panic: %v"`,
+			},
+			dst.Clone(recovered).(dst.Expr),
+		},
+	}
+}
+
+// DeferRecoverNoticePanic generates a defer statement that recovers a panic, notices it on the transaction, and then re-panics.
+//
+//	defer func() {
+//		if r := recover(); r != nil {
+//			txn.NoticeError(fmt.Errorf("panic: %v", r))
+//			panic(r)
+//		}
+//	}()
+func DeferRecoverNoticePanic(transactionVariable dst.Expr) *dst.DeferStmt {
+	recovered := dst.NewIdent("r")
+	return &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.FuncLit{
+				Type: &dst.FuncType{Params: &dst.FieldList{}},
+				Body: &dst.BlockStmt{
+					List: []dst.Stmt{
+						&dst.IfStmt{
+							Init: &dst.AssignStmt{
+								Lhs: []dst.Expr{dst.Clone(recovered).(dst.Expr)},
+								Tok: token.DEFINE,
+								Rhs: []dst.Expr{&dst.CallExpr{Fun: dst.NewIdent("recover")}},
+							},
+							Cond: &dst.BinaryExpr{
+								X:  dst.Clone(recovered).(dst.Expr),
+								Op: token.NEQ,
+								Y:  dst.NewIdent("nil"),
+							},
+							Body: &dst.BlockStmt{
+								List: []dst.Stmt{
+									NoticeError(RecoveredError(recovered), transactionVariable, nil),
+									&dst.ExprStmt{
+										X: &dst.CallExpr{
+											Fun:  dst.NewIdent("panic"),
+											Args: []dst.Expr{dst.Clone(recovered).(dst.Expr)},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Decs: dst.DeferStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+	}
+}
@@ -2,6 +2,8 @@ package codegen
 
 import (
 	"go/token"
+	"strconv"
+	"strings"
 
 	"github.com/dave/dst"
 )
@@ -115,20 +117,135 @@ func WrapRequestContext(request dst.Expr, txnVariable dst.Expr, nodeDecs *dst.No
 	}
 
 	return &dst.AssignStmt{
-		Tok: token.ASSIGN,
-		Lhs: []dst.Expr{dst.Clone(request).(dst.Expr)},
-		Rhs: []dst.Expr{
+		Tok:  token.ASSIGN,
+		Lhs:  []dst.Expr{dst.Clone(request).(dst.Expr)},
+		Rhs:  []dst.Expr{RequestWithTransactionContext(request, txnVariable)},
+		Decs: decs,
+	}
+}
+
+// RequestWithTransactionContext returns a call to `newrelic.RequestWithTransactionContext(request, txnVariable)`
+// request will be cloned, but txnVariable WILL NOT BE CLONED
+func RequestWithTransactionContext(request dst.Expr, txnVariable dst.Expr) *dst.CallExpr {
+	return &dst.CallExpr{
+		Fun: &dst.Ident{
+			Name: "RequestWithTransactionContext",
+			Path: NewRelicAgentImportPath,
+		},
+		Args: []dst.Expr{
+			dst.Clone(request).(dst.Expr),
+			txnVariable,
+		},
+	}
+}
+
+// HttpMethodConstant returns the net/http constant for an HTTP method, such as http.MethodGet
+func HttpMethodConstant(method string) dst.Expr {
+	return &dst.Ident{
+		Name: "Method" + method[:1] + strings.ToLower(method[1:]),
+		Path: HttpImportPath,
+	}
+}
+
+// NewRequestWithContext returns a call to `http.NewRequestWithContext(context, method, url, body)`
+// this is protected from using the same object, and will always clone inputs
+func NewRequestWithContext(context dst.Expr, method string, url, body dst.Expr) *dst.CallExpr {
+	return &dst.CallExpr{
+		Fun: &dst.Ident{
+			Name: "NewRequestWithContext",
+			Path: HttpImportPath,
+		},
+		Args: []dst.Expr{
+			dst.Clone(context).(dst.Expr),
+			HttpMethodConstant(method),
+			dst.Clone(url).(dst.Expr),
+			dst.Clone(body).(dst.Expr),
+		},
+	}
+}
+
+// SetRequestHeader returns a statement that sets a header on an HTTP request: `request.Header.Set(key, value)`
+func SetRequestHeader(request dst.Expr, key string, value dst.Expr) *dst.ExprStmt {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X: &dst.SelectorExpr{
+					X:   dst.Clone(request).(dst.Expr),
+					Sel: dst.NewIdent("Header"),
+				},
+				Sel: dst.NewIdent("Set"),
+			},
+			Args: []dst.Expr{
+				&dst.BasicLit{
+					Kind:  token.STRING,
+					Value: strconv.Quote(key),
+				},
+				dst.Clone(value).(dst.Expr),
+			},
+		},
+	}
+}
+
+// FormBody returns an expression that encodes url.Values as a request body: `strings.NewReader(values.Encode())`
+func FormBody(values dst.Expr) dst.Expr {
+	return &dst.CallExpr{
+		Fun: &dst.Ident{
+			Name: "NewReader",
+			Path: "strings",
+		},
+		Args: []dst.Expr{
 			&dst.CallExpr{
-				Fun: &dst.Ident{
-					Name: "RequestWithTransactionContext",
-					Path: NewRelicAgentImportPath,
+				Fun: &dst.SelectorExpr{
+					X:   dst.Clone(values).(dst.Expr),
+					Sel: dst.NewIdent("Encode"),
 				},
-				Args: []dst.Expr{
-					dst.Clone(request).(dst.Expr),
-					txnVariable,
+			},
+		},
+	}
+}
+
+// InstrumentedHttpClient returns an expression that creates an http client with a New Relic round tripper
+// wrapping the default transport: `&http.Client{Transport: newrelic.NewRoundTripper(nil)}`
+func InstrumentedHttpClient() dst.Expr {
+	return &dst.UnaryExpr{
+		Op: token.AND,
+		X: &dst.CompositeLit{
+			Type: &dst.Ident{
+				Name: "Client",
+				Path: HttpImportPath,
+			},
+			Elts: []dst.Expr{
+				&dst.KeyValueExpr{
+					Key: dst.NewIdent("Transport"),
+					Value: &dst.CallExpr{
+						Fun: &dst.Ident{
+							Name: "NewRoundTripper",
+							Path: NewRelicAgentImportPath,
+						},
+						Args: []dst.Expr{dst.NewIdent("nil")},
+					},
 				},
 			},
 		},
-		Decs: decs,
+	}
+}
+
+// InstrumentedHttpClientDeclaration returns a package level declaration of a variable with the given name that is assigned
+// an http client with a New Relic round tripper: `var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}`
+func InstrumentedHttpClientDeclaration(name string) *dst.GenDecl {
+	return &dst.GenDecl{
+		Tok: token.VAR,
+		Specs: []dst.Spec{
+			&dst.ValueSpec{
+				Names:  []*dst.Ident{dst.NewIdent(name)},
+				Values: []dst.Expr{InstrumentedHttpClient()},
+			},
+		},
+		Decs: dst.GenDeclDecorations{
+			NodeDecs: dst.NodeDecs{
+				Before: dst.EmptyLine,
+				After:  dst.EmptyLine,
+			},
+		},
 	}
 }
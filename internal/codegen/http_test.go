@@ -131,3 +131,22 @@ func Test_addTxnToRequestContext(t *testing.T) {
 		})
 	}
 }
+
+func TestHttpMethodConstant(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{method: "GET", want: "MethodGet"},
+		{method: "HEAD", want: "MethodHead"},
+		{method: "POST", want: "MethodPost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			want := &dst.Ident{Name: tt.want, Path: HttpImportPath}
+			if got := HttpMethodConstant(tt.method); !reflect.DeepEqual(got, want) {
+				t.Errorf("HttpMethodConstant() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package codegen
 
 import (
+	"go/token"
 	"slices"
 
 	"github.com/dave/dst"
@@ -59,3 +60,37 @@ func CreateStatementBlock(spacingBefore bool, stmts ...dst.Stmt) {
 		}
 	}
 }
+
+// DeclareVariable returns a statement that declares a variable with its zero value: `var name varType`
+// varType WILL NOT BE CLONED
+func DeclareVariable(name string, varType dst.Expr) *dst.DeclStmt {
+	return &dst.DeclStmt{
+		Decl: &dst.GenDecl{
+			Tok: token.VAR,
+			Specs: []dst.Spec{
+				&dst.ValueSpec{
+					Names: []*dst.Ident{dst.NewIdent(name)},
+					Type:  varType,
+				},
+			},
+		},
+	}
+}
+
+// InitFunction returns a package init function declaration containing the given statements
+// statements WILL NOT BE CLONED
+func InitFunction(stmts ...dst.Stmt) *dst.FuncDecl {
+	return &dst.FuncDecl{
+		Name: dst.NewIdent("init"),
+		Type: &dst.FuncType{},
+		Body: &dst.BlockStmt{
+			List: stmts,
+		},
+		Decs: dst.FuncDeclDecorations{
+			NodeDecs: dst.NodeDecs{
+				Before: dst.EmptyLine,
+				After:  dst.EmptyLine,
+			},
+		},
+	}
+}
@@ -3,6 +3,7 @@ package codegen
 import (
 	"fmt"
 	"go/token"
+	"strconv"
 
 	"github.com/dave/dst"
 )
@@ -60,6 +61,17 @@ func TxnNewGoroutine(transaction dst.Expr) *dst.CallExpr {
 	}
 }
 
+// AssignTxnNewGoroutine returns an assignment of txn.NewGoroutine() to a new transaction variable
+//
+//	nrTxn := txn.NewGoroutine()
+func AssignTxnNewGoroutine(transactionVariableName string, transaction dst.Expr) *dst.AssignStmt {
+	return &dst.AssignStmt{
+		Lhs: []dst.Expr{dst.NewIdent(transactionVariableName)},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{TxnNewGoroutine(transaction)},
+	}
+}
+
 // starts a NewRelic transaction
 // if overwireVariable is true, the transaction variable will be overwritten by variable assignment, otherwise it will be defined
 func StartTransaction(appVariableName, transactionVariableName, transactionName string, overwriteVariable bool) *dst.AssignStmt {
@@ -126,3 +138,108 @@ func TxnFromContextExpression(contextObject dst.Expr) dst.Expr {
 		},
 	}
 }
+
+// SetTransactionName returns a call to `transaction.SetName(name)`
+func SetTransactionName(transaction dst.Expr, name string) *dst.ExprStmt {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.Clone(transaction).(dst.Expr),
+				Sel: dst.NewIdent("SetName"),
+			},
+			Args: []dst.Expr{
+				&dst.BasicLit{
+					Kind:  token.STRING,
+					Value: strconv.Quote(name),
+				},
+			},
+		},
+	}
+}
+
+// AddAttribute returns a call to `transaction.AddAttribute(key, value)`
+func AddAttribute(transaction dst.Expr, key string, value dst.Expr) *dst.ExprStmt {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.Clone(transaction).(dst.Expr),
+				Sel: dst.NewIdent("AddAttribute"),
+			},
+			Args: []dst.Expr{
+				&dst.BasicLit{
+					Kind:  token.STRING,
+					Value: strconv.Quote(key),
+				},
+				dst.Clone(value).(dst.Expr),
+			},
+		},
+	}
+}
+
+// TransactionGoroutine returns a go statement that runs the body in a function literal that is passed a new
+// transaction started from the agent. The transaction is ended when the goroutine returns.
+//
+//	go func(nrTxn *newrelic.Transaction) {
+//		defer nrTxn.End()
+//		body...
+//	}(agent.StartTransaction("transactionName"))
+func TransactionGoroutine(agentVariable dst.Expr, transactionName string, body ...dst.Stmt) *dst.GoStmt {
+	deferEnd := &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent(DefaultTransactionVariable),
+				Sel: dst.NewIdent("End"),
+			},
+		},
+	}
+
+	// each statement is written on its own line
+	stmts := append([]dst.Stmt{deferEnd}, body...)
+	for _, stmt := range stmts {
+		stmt.Decorations().Before = dst.NewLine
+		stmt.Decorations().After = dst.NewLine
+	}
+
+	return &dst.GoStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.FuncLit{
+				Type: &dst.FuncType{
+					Params: &dst.FieldList{
+						List: []*dst.Field{NewTransactionParameter(DefaultTransactionVariable)},
+					},
+				},
+				Body: &dst.BlockStmt{
+					List: stmts,
+				},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.SelectorExpr{
+						X:   dst.Clone(agentVariable).(dst.Expr),
+						Sel: dst.NewIdent("StartTransaction"),
+					},
+					Args: []dst.Expr{
+						&dst.BasicLit{
+							Kind:  token.STRING,
+							Value: strconv.Quote(transactionName),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ForwardCall returns a statement that calls a function with the given arguments, and returns its values if it returns any.
+//
+//	return fooWithTxn(a, b, nil)
+func ForwardCall(fun dst.Expr, args []dst.Expr, returnsValues bool) dst.Stmt {
+	call := &dst.CallExpr{
+		Fun:  fun,
+		Args: args,
+	}
+	if returnsValues {
+		return &dst.ReturnStmt{Results: []dst.Expr{call}}
+	}
+	return &dst.ExprStmt{X: call}
+}
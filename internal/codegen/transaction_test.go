@@ -283,3 +283,123 @@ func Test_generateNoticeError(t *testing.T) {
 		})
 	}
 }
+
+func TestSetTransactionName(t *testing.T) {
+	got := SetTransactionName(dst.NewIdent("txn"), "GET /items/{id}")
+	want := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent("txn"),
+				Sel: dst.NewIdent("SetName"),
+			},
+			Args: []dst.Expr{
+				&dst.BasicLit{
+					Kind:  token.STRING,
+					Value: `"GET /items/{id}"`,
+				},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestAddAttribute(t *testing.T) {
+	got := AddAttribute(dst.NewIdent("txn"), "id", dst.NewIdent("id"))
+	want := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent("txn"),
+				Sel: dst.NewIdent("AddAttribute"),
+			},
+			Args: []dst.Expr{
+				&dst.BasicLit{
+					Kind:  token.STRING,
+					Value: `"id"`,
+				},
+				dst.NewIdent("id"),
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestTransactionGoroutine(t *testing.T) {
+	body := &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("worker")}}
+	got := TransactionGoroutine(dst.NewIdent("app"), "worker", body)
+	want := &dst.GoStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.FuncLit{
+				Type: &dst.FuncType{
+					Params: &dst.FieldList{
+						List: []*dst.Field{NewTransactionParameter("nrTxn")},
+					},
+				},
+				Body: &dst.BlockStmt{
+					List: []dst.Stmt{
+						&dst.DeferStmt{
+							Call: &dst.CallExpr{
+								Fun: &dst.SelectorExpr{
+									X:   dst.NewIdent("nrTxn"),
+									Sel: dst.NewIdent("End"),
+								},
+							},
+							Decs: dst.DeferStmtDecorations{NodeDecs: dst.NodeDecs{Before: dst.NewLine, After: dst.NewLine}},
+						},
+						&dst.ExprStmt{
+							X:    &dst.CallExpr{Fun: dst.NewIdent("worker")},
+							Decs: dst.ExprStmtDecorations{NodeDecs: dst.NodeDecs{Before: dst.NewLine, After: dst.NewLine}},
+						},
+					},
+				},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.SelectorExpr{
+						X:   dst.NewIdent("app"),
+						Sel: dst.NewIdent("StartTransaction"),
+					},
+					Args: []dst.Expr{
+						&dst.BasicLit{
+							Kind:  token.STRING,
+							Value: `"worker"`,
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestAssignTxnNewGoroutine(t *testing.T) {
+	got := AssignTxnNewGoroutine("nrTxn", dst.NewIdent("txn"))
+	want := &dst.AssignStmt{
+		Lhs: []dst.Expr{dst.NewIdent("nrTxn")},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.SelectorExpr{
+					X:   dst.NewIdent("txn"),
+					Sel: dst.NewIdent("NewGoroutine"),
+				},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestForwardCall(t *testing.T) {
+	got := ForwardCall(dst.NewIdent("fooWithTxn"), []dst.Expr{dst.NewIdent("a"), dst.NewIdent("nil")}, true)
+	want := &dst.ReturnStmt{
+		Results: []dst.Expr{
+			&dst.CallExpr{
+				Fun:  dst.NewIdent("fooWithTxn"),
+				Args: []dst.Expr{dst.NewIdent("a"), dst.NewIdent("nil")},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+
+	got = ForwardCall(dst.NewIdent("fooWithTxn"), []dst.Expr{dst.NewIdent("nil")}, false)
+	assert.Equal(t, &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("fooWithTxn"), Args: []dst.Expr{dst.NewIdent("nil")}}}, got)
+}
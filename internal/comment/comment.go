@@ -1,11 +1,12 @@
 // comment is a library that provides a generalized way to provide feedback to the user about the state of their code
 // and our ability to instrument it. It does this primarily by adding comments in the diff file that either provide
 // information or warnings to the user. It also provides a way to print this information to the console if the user
-// enables debug mode.
+// enables debug mode, and to record it for a report of the instrumentation.
 package comment
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -21,21 +22,37 @@ const (
 	DebugConsoleHeader string = "Debug"
 )
 
+// writeComment adds comments before the existing comments of a node, unless the node already has them, so that
+// instrumenting code again does not repeat them.
 func writeComment(node dst.Node, comments []string) {
 	decs := node.Decorations()
+	if HasComment(decs, comments) {
+		return
+	}
 	if len(decs.Start) > 0 {
 		comments = append(comments, "//")
 	}
 	decs.Start.Prepend(comments...)
 }
 
+// HasComment returns true if the lines of a comment are already written, in order, before a node.
+func HasComment(decs *dst.NodeDecs, comments []string) bool {
+	existing := decs.Start.All()
+	for i := 0; i+len(comments) <= len(existing); i++ {
+		if slices.Equal(existing[i:i+len(comments)], comments) {
+			return true
+		}
+	}
+	return false
+}
+
 // Info appends a comment to a node that alerts a user to a non-critical issue in their code.
-// It also adds the comment to the console printer if it is enabled.
+// It also adds the comment to the console printer and the report if they are enabled.
 //
-// The message is the main comment, and additionalInfo is a list of optional new lines that will
+// The rule identifies the kind of issue in the report. The message is the main comment, and additionalInfo is a list of optional new lines that will
 // be added to the comment. The positionNode is the node where the issue is occurring. The commentNode
 // is the node where the comment will be added, for readability purposes, these may not be the same node.
-func Info(pkg *decorator.Package, commentNode dst.Node, positionNode dst.Node, message string, additionalInfo ...string) {
+func Info(pkg *decorator.Package, rule Rule, commentNode dst.Node, positionNode dst.Node, message string, additionalInfo ...string) {
 	comments := []string{
 		fmt.Sprintf("// %s: %s", InfoHeader, message),
 	}
@@ -45,15 +62,16 @@ func Info(pkg *decorator.Package, commentNode dst.Node, positionNode dst.Node, m
 
 	writeComment(commentNode, comments)
 	printer.add(pkg, positionNode, InfoConsoleHeader, message, additionalInfo...)
+	recorder.add(pkg, positionNode, LevelInfo, rule, message, additionalInfo...)
 }
 
 // Warn appends a comment to a node that alerts a user to an important issue in their code.
-// It also adds the comment to the console printer if it is enabled.
+// It also adds the comment to the console printer and the report if they are enabled.
 //
-// The message is the main comment, and additionalInfo is a list of optional new lines that will
+// The rule identifies the kind of issue in the report. The message is the main comment, and additionalInfo is a list of optional new lines that will
 // be added to the comment. The positionNode is the node where the issue is occurring. The commentNode
 // is the node where the comment will be added, for readability purposes, these may not be the same node.
-func Warn(pkg *decorator.Package, commentNode dst.Node, positionNode dst.Node, message string, additionalInfo ...string) {
+func Warn(pkg *decorator.Package, rule Rule, commentNode dst.Node, positionNode dst.Node, message string, additionalInfo ...string) {
 	comments := []string{
 		fmt.Sprintf("// %s: %s", WarnHeader, message),
 	}
@@ -63,6 +81,7 @@ func Warn(pkg *decorator.Package, commentNode dst.Node, positionNode dst.Node, m
 
 	writeComment(commentNode, comments)
 	printer.add(pkg, positionNode, WarnConsoleHeader, message, additionalInfo...)
+	recorder.add(pkg, positionNode, LevelWarning, rule, message, additionalInfo...)
 }
 
 // Debug logs a message to the console only when debug mode is enabled.
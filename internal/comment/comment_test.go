@@ -8,7 +8,7 @@ import (
 
 func TestInfo(t *testing.T) {
 	node := &dst.Ident{Name: "hi"}
-	Info(nil, node, nil, "message", "additionalInfo")
+	Info(nil, Rule{}, node, nil, "message", "additionalInfo")
 
 	decs := node.Decorations()
 	if len(decs.Start) != 2 {
@@ -26,7 +26,7 @@ func TestInfo(t *testing.T) {
 	}
 
 	nodeWithComments := &dst.Ident{Name: "hi", Decs: dst.IdentDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// existing comment"}}}}
-	Info(nil, nodeWithComments, nil, "message", "additionalInfo")
+	Info(nil, Rule{}, nodeWithComments, nil, "message", "additionalInfo")
 	decs = nodeWithComments.Decorations()
 	if len(decs.Start) != 4 {
 		t.Errorf("Expected 4 comments, got %d", len(decs.Start))
@@ -45,9 +45,53 @@ func TestInfo(t *testing.T) {
 	}
 }
 
+func TestInfoIsNotRepeated(t *testing.T) {
+	node := &dst.Ident{Name: "hi", Decs: dst.IdentDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// existing comment"}}}}
+	Info(nil, Rule{}, node, nil, "message", "additionalInfo")
+	Info(nil, Rule{}, node, nil, "message", "additionalInfo")
+
+	decs := node.Decorations()
+	expected := []string{
+		"// NR INFO: message",
+		"// additionalInfo",
+		"//",
+		"// existing comment",
+	}
+	if len(decs.Start) != len(expected) {
+		t.Fatalf("Expected %d comments, got %d: %v", len(expected), len(decs.Start), decs.Start)
+	}
+	for i, comment := range decs.Start {
+		if comment != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], comment)
+		}
+	}
+}
+
+func TestHasComment(t *testing.T) {
+	decs := &dst.NodeDecs{Start: []string{"// NR INFO: message", "// additionalInfo", "//", "// existing comment"}}
+	tests := []struct {
+		name     string
+		comments []string
+		want     bool
+	}{
+		{name: "first lines", comments: []string{"// NR INFO: message", "// additionalInfo"}, want: true},
+		{name: "last line", comments: []string{"// existing comment"}, want: true},
+		{name: "lines out of order", comments: []string{"// additionalInfo", "// NR INFO: message"}, want: false},
+		{name: "missing line", comments: []string{"// NR WARN: message"}, want: false},
+		{name: "more lines than the node has", comments: []string{"// NR INFO: message", "// additionalInfo", "//", "// existing comment", "//"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasComment(decs, tt.comments); got != tt.want {
+				t.Errorf("HasComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestWarn(t *testing.T) {
 	node := &dst.Ident{Name: "hi"}
-	Warn(nil, node, nil, "message", "additionalInfo")
+	Warn(nil, Rule{}, node, nil, "message", "additionalInfo")
 
 	decs := node.Decorations()
 	if len(decs.Start) != 2 {
@@ -65,7 +109,7 @@ func TestWarn(t *testing.T) {
 	}
 
 	nodeWithComments := &dst.Ident{Name: "hi", Decs: dst.IdentDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// existing comment"}}}}
-	Warn(nil, nodeWithComments, nil, "message", "additionalInfo")
+	Warn(nil, Rule{}, nodeWithComments, nil, "message", "additionalInfo")
 	decs = nodeWithComments.Decorations()
 	if len(decs.Start) != 4 {
 		t.Errorf("Expected 4 comments, got %d", len(decs.Start))
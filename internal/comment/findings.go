@@ -0,0 +1,101 @@
+package comment
+
+import (
+	"path/filepath"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// Rule identifies the kind of issue that an info or warning comment is about, so that the issue can be reported.
+type Rule struct {
+	ID          string // a stable identifier of the issue, like unchecked-error
+	Integration string // the name of the integration that found the issue, if any
+	Docs        string // a link to the documentation about the issue
+}
+
+// Level is how important a finding is.
+type Level string
+
+const (
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+)
+
+// Finding is an info or warning comment that was added to the application.
+type Finding struct {
+	Level       Level    `json:"level"`
+	File        string   `json:"file,omitempty"` // the path of the file relative to the application, with forward slashes
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	Integration string   `json:"integration,omitempty"`
+	RuleID      string   `json:"ruleId"`
+	Docs        string   `json:"docs,omitempty"`
+	Message     string   `json:"message"`
+	Details     []string `json:"details,omitempty"`
+}
+
+// findingRecorder collects the findings of info and warning comments for the report of the instrumentation.
+type findingRecorder struct {
+	appRoot  string // the absolute path of the application
+	findings []Finding
+}
+
+// initialize this with EnableReport if the findings are reported
+var recorder *findingRecorder
+
+// EnableReport starts recording the info and warning comments that are added to the application at applicationPath.
+func EnableReport(applicationPath string) error {
+	appRoot, err := filepath.Abs(applicationPath)
+	if err != nil {
+		return err
+	}
+	recorder = &findingRecorder{appRoot: appRoot}
+	return nil
+}
+
+// Findings returns the info and warning comments that were recorded since EnableReport was called, in the order they
+// were added.
+func Findings() []Finding {
+	if recorder == nil {
+		return nil
+	}
+	return recorder.findings
+}
+
+// add records a finding at the position of a node. The same finding is only recorded once.
+func (r *findingRecorder) add(pkg *decorator.Package, node dst.Node, level Level, rule Rule, message string, additionalInfo ...string) {
+	if r == nil {
+		return
+	}
+
+	finding := Finding{
+		Level:       level,
+		Integration: rule.Integration,
+		RuleID:      rule.ID,
+		Docs:        rule.Docs,
+		Message:     message,
+		Details:     additionalInfo,
+	}
+	if pos := util.Position(node, pkg); pos != nil && pos.IsValid() {
+		finding.File = filepath.ToSlash(pos.Filename)
+		if name, err := filepath.Rel(r.appRoot, pos.Filename); err == nil {
+			finding.File = filepath.ToSlash(name)
+		}
+		finding.Line = pos.Line
+		finding.Column = pos.Column
+	}
+
+	for _, f := range r.findings {
+		if f.File == finding.File && f.Line == finding.Line && f.Column == finding.Column && f.RuleID == finding.RuleID && f.Message == finding.Message {
+			return
+		}
+	}
+	r.findings = append(r.findings, finding)
+}
+
+// Record adds a finding to the report, if it is enabled, for an issue that is commented on without Info or Warn.
+func Record(pkg *decorator.Package, rule Rule, level Level, positionNode dst.Node, message string, additionalInfo ...string) {
+	recorder.add(pkg, positionNode, level, rule, message, additionalInfo...)
+}
@@ -0,0 +1,78 @@
+package comment
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestFindings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app", "main.go")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	code := "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"
+	if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, path, code, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := decorator.NewDecorator(fset)
+	file, err := dec.DecorateFile(astFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &decorator.Package{Decorator: dec, Package: &packages.Package{Fset: fset}}
+	stmt := file.Decls[0].(*dst.FuncDecl).Body.List[0]
+
+	defer func() { recorder = nil }()
+	if err := EnableReport(dir); err != nil {
+		t.Fatal(err)
+	}
+	rule := Rule{ID: "example", Integration: "core", Docs: "https://example.com/docs"}
+	Warn(pkg, rule, stmt, stmt, "message", "additionalInfo")
+	Warn(pkg, rule, stmt, stmt, "message", "additionalInfo")
+	Info(pkg, Rule{ID: "no-position"}, stmt, nil, "other message")
+	Debug(pkg, stmt, "debug messages are not reported")
+
+	expected := []Finding{
+		{
+			Level:       LevelWarning,
+			File:        "app/main.go",
+			Line:        4,
+			Column:      2,
+			Integration: "core",
+			RuleID:      "example",
+			Docs:        "https://example.com/docs",
+			Message:     "message",
+			Details:     []string{"additionalInfo"},
+		},
+		{
+			Level:   LevelInfo,
+			RuleID:  "no-position",
+			Message: "other message",
+		},
+	}
+	if got := Findings(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected findings %+v, got %+v", expected, got)
+	}
+}
+
+func TestFindingsNotEnabled(t *testing.T) {
+	Warn(nil, Rule{ID: "example"}, &dst.Ident{Name: "hi"}, nil, "message")
+	if got := Findings(); got != nil {
+		t.Errorf("Expected no findings, got %+v", got)
+	}
+}
@@ -0,0 +1,100 @@
+// Package config reads the configuration file that an application checks in to configure how it is instrumented.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FileName is the name of the configuration file in the root directory of an application.
+	FileName = ".go-easy-instrumentation.yaml"
+)
+
+// Config is the configuration file of an application.
+//
+//	app_name: checkout-service
+//	agent_variable_name: NewRelicAgent
+//	integrations:
+//	  disabled: [slog]
+//	packages:
+//	  include: ["./..."]
+//	  exclude: ["./internal/generated/..."]
+//	functions:
+//	  deny: ["*_test", "String"]
+//	segment_naming: method
+//	errors:
+//	  ignore: [database/sql.ErrNoRows]
+//	  expected: [io.EOF, context.Canceled]
+//	rules: [./instrumentation/rules.yaml]
+type Config struct {
+	AppName           string       `yaml:"app_name"`
+	AgentVariableName string       `yaml:"agent_variable_name"`
+	Integrations      Integrations `yaml:"integrations"`
+	Packages          Packages     `yaml:"packages"`
+	Functions         Functions    `yaml:"functions"`
+	SegmentNaming     string       `yaml:"segment_naming"`
+	Errors            Errors       `yaml:"errors"`
+	Rules             []string     `yaml:"rules"` // paths of rule files, relative to the configuration file
+}
+
+// Integrations selects the integrations that are used to instrument the application by name.
+type Integrations struct {
+	Enabled  []string `yaml:"enabled"`  // the only integrations that are used; all integrations are used if this is empty
+	Disabled []string `yaml:"disabled"` // integrations that are not used
+}
+
+// Packages selects the packages of the application that are instrumented.
+type Packages struct {
+	Include []string `yaml:"include"` // package patterns that are loaded, such as "./..."
+	Exclude []string `yaml:"exclude"` // patterns of packages that are not instrumented
+}
+
+// Functions selects the functions that are traced when they are called with a transaction.
+type Functions struct {
+	Allow []string `yaml:"allow"` // patterns of the only functions that are traced; all functions are traced if this is empty
+	Deny  []string `yaml:"deny"`  // patterns of functions that are not traced
+}
+
+// Errors selects the errors that are not noticed, or are noticed as expected errors.
+type Errors struct {
+	Ignore   []string `yaml:"ignore"`   // sentinel errors and error types that are not noticed
+	Expected []string `yaml:"expected"` // sentinel errors and error types that are noticed as expected errors
+}
+
+// Find returns the path of the configuration file in the root directory of an application, or an empty string if
+// the application does not have one.
+func Find(dir string) (string, error) {
+	path := filepath.Join(dir, FileName)
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Load reads a configuration file. Fields that are not part of the configuration are an error, so that misspelled
+// settings are not silently ignored.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing configuration file %s: %w", path, err)
+	}
+	return config, nil
+}
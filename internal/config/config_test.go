@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "all settings",
+			content: `app_name: checkout-service
+agent_variable_name: nrApp
+integrations:
+  enabled: [http-client, grpc]
+  disabled: [slog]
+packages:
+  include: ["./..."]
+  exclude: ["./internal/generated/..."]
+functions:
+  allow: ["Store.*"]
+  deny: ["String"]
+segment_naming: method
+errors:
+  ignore: [database/sql.ErrNoRows]
+  expected: [io.EOF, context.Canceled]
+rules: [./rules.yaml]
+`,
+			want: &Config{
+				AppName:           "checkout-service",
+				AgentVariableName: "nrApp",
+				Integrations:      Integrations{Enabled: []string{"http-client", "grpc"}, Disabled: []string{"slog"}},
+				Packages:          Packages{Include: []string{"./..."}, Exclude: []string{"./internal/generated/..."}},
+				Functions:         Functions{Allow: []string{"Store.*"}, Deny: []string{"String"}},
+				SegmentNaming:     "method",
+				Errors:            Errors{Ignore: []string{"database/sql.ErrNoRows"}, Expected: []string{"io.EOF", "context.Canceled"}},
+				Rules:             []string{"./rules.yaml"},
+			},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    &Config{},
+		},
+		{
+			name:    "unknown settings are an error",
+			content: "appname: checkout-service\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			content: "app_name: [checkout-service\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Find(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "", path)
+
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("app_name: app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err = Find(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, FileName), path)
+}
@@ -0,0 +1,62 @@
+// report writes the info and warning comments that the instrumentation added to an application, and a summary of
+// the instrumentation, to a file that CI systems can read. Reports are written as JSON, or as SARIF so that the
+// comments can be shown as annotations of the code.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+)
+
+// Format is the format a report is written in.
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatSARIF Format = "sarif"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatJSON, FormatSARIF:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown report format %q: must be %q or %q", name, FormatJSON, FormatSARIF)
+}
+
+// Summary counts the instrumentation that was added to an application.
+type Summary struct {
+	Transactions  int `json:"transactions"`  // the transactions that are started
+	Segments      int `json:"segments"`      // the segments that are created for traced functions
+	Errors        int `json:"errors"`        // the errors that are noticed
+	ExternalCalls int `json:"externalCalls"` // the requests and clients that external calls are traced for
+}
+
+// Report is what the instrumentation of an application found and added.
+type Report struct {
+	Tool     string            `json:"tool"`
+	Version  string            `json:"version"`
+	Findings []comment.Finding `json:"findings"`
+	Summary  Summary           `json:"summary"`
+}
+
+// Write writes a report to a file in a format.
+func Write(path string, format Format, report Report) error {
+	if report.Findings == nil {
+		report.Findings = []comment.Finding{}
+	}
+
+	var v any = report
+	if format == FormatSARIF {
+		v = toSARIF(report)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,165 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/stretchr/testify/assert"
+)
+
+var testReport = Report{
+	Tool:    "go-easy-instrumentation",
+	Version: "1.0.0",
+	Findings: []comment.Finding{
+		{
+			Level:       comment.LevelWarning,
+			File:        "pkg/store.go",
+			Line:        12,
+			Column:      2,
+			Integration: "errors",
+			RuleID:      "unchecked-error",
+			Docs:        "https://example.com/errors",
+			Message:     "Unchecked Error",
+		},
+		{
+			Level:       comment.LevelInfo,
+			File:        "main.go",
+			Line:        30,
+			Column:      2,
+			Integration: "core",
+			RuleID:      "goroutine-in-main",
+			Message:     "goroutines in main are not traced",
+			Details:     []string{"please instrument manually"},
+		},
+		{
+			Level:       comment.LevelWarning,
+			File:        "main.go",
+			Line:        40,
+			Column:      1,
+			Integration: "errors",
+			RuleID:      "unchecked-error",
+			Docs:        "https://example.com/errors",
+			Message:     "Unchecked Error",
+		},
+	},
+	Summary: Summary{Transactions: 2, Segments: 3, Errors: 4, ExternalCalls: 1},
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{name: "json", want: FormatJSON},
+		{name: "sarif", want: FormatSARIF},
+		{name: "xml", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := Write(path, FormatJSON, testReport); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testReport, got)
+	assert.Contains(t, string(data), `"ruleId": "unchecked-error"`)
+	assert.Contains(t, string(data), `"externalCalls": 1`)
+}
+
+func TestWriteJSONWithoutFindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := Write(path, FormatJSON, Report{Tool: "go-easy-instrumentation"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), `"findings": []`)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.sarif")
+	if err := Write(path, FormatSARIF, testReport); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got sarifLog
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sarifVersion, got.Version)
+	if len(got.Runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(got.Runs))
+	}
+	run := got.Runs[0]
+
+	// each rule is described once, in the order it is first found
+	assert.Equal(t, []sarifRule{
+		{ID: "unchecked-error", HelpURI: "https://example.com/errors", Properties: sarifProperties{Integration: "errors"}},
+		{ID: "goroutine-in-main", Properties: sarifProperties{Integration: "core"}},
+	}, run.Tool.Driver.Rules)
+
+	assert.Equal(t, []sarifResult{
+		{
+			RuleID:     "unchecked-error",
+			RuleIndex:  0,
+			Level:      "warning",
+			Message:    sarifMessage{Text: "Unchecked Error"},
+			Locations:  []sarifLocation{location("pkg/store.go", 12, 2)},
+			Properties: sarifProperties{Integration: "errors", Docs: "https://example.com/errors"},
+		},
+		{
+			RuleID:     "goroutine-in-main",
+			RuleIndex:  1,
+			Level:      "note",
+			Message:    sarifMessage{Text: "goroutines in main are not traced\nplease instrument manually"},
+			Locations:  []sarifLocation{location("main.go", 30, 2)},
+			Properties: sarifProperties{Integration: "core"},
+		},
+		{
+			RuleID:     "unchecked-error",
+			RuleIndex:  0,
+			Level:      "warning",
+			Message:    sarifMessage{Text: "Unchecked Error"},
+			Locations:  []sarifLocation{location("main.go", 40, 1)},
+			Properties: sarifProperties{Integration: "errors", Docs: "https://example.com/errors"},
+		},
+	}, run.Results)
+	assert.Equal(t, &testReport.Summary, run.Properties.Summary)
+}
+
+// location returns the SARIF location of a line and column of a file.
+func location(file string, line, column int) sarifLocation {
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: file, URIBaseID: sarifSourceRoot},
+		Region:           &sarifRegion{StartLine: line, StartColumn: column},
+	}}
+}
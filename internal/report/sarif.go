@@ -0,0 +1,141 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// sarifSourceRoot is the base that the files of the findings are relative to, which is the application.
+	sarifSourceRoot = "%SRCROOT%"
+	toolURI         = "https://github.com/newrelic/go-easy-instrumentation"
+)
+
+// The types below are the parts of the SARIF 2.1.0 format that reports use.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool       `json:"tool"`
+	Results    []sarifResult   `json:"results"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID         string          `json:"id"`
+	HelpURI    string          `json:"helpUri,omitempty"`
+	Properties sarifProperties `json:"properties,omitzero"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties sarifProperties `json:"properties,omitzero"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifProperties struct {
+	Integration string   `json:"integration,omitempty"`
+	Docs        string   `json:"docs,omitempty"`
+	Summary     *Summary `json:"summary,omitempty"`
+}
+
+// sarifLevel returns the SARIF level of a finding: info comments are notes.
+func sarifLevel(level comment.Level) string {
+	if level == comment.LevelWarning {
+		return "warning"
+	}
+	return "note"
+}
+
+// toSARIF converts a report to a SARIF log with a single run. Each rule of the findings is described once by the
+// tool, and the summary is a property of the run.
+func toSARIF(report Report) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           report.Tool,
+			Version:        report.Version,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results:    []sarifResult{},
+		Properties: sarifProperties{Summary: &report.Summary},
+	}
+
+	rules := map[string]int{}
+	for _, finding := range report.Findings {
+		index, ok := rules[finding.RuleID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			rules[finding.RuleID] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:         finding.RuleID,
+				HelpURI:    finding.Docs,
+				Properties: sarifProperties{Integration: finding.Integration},
+			})
+		}
+
+		result := sarifResult{
+			RuleID:     finding.RuleID,
+			RuleIndex:  index,
+			Level:      sarifLevel(finding.Level),
+			Message:    sarifMessage{Text: strings.Join(append([]string{finding.Message}, finding.Details...), "\n")},
+			Properties: sarifProperties{Integration: finding.Integration, Docs: finding.Docs},
+		}
+		if finding.File != "" {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: finding.File, URIBaseID: sarifSourceRoot},
+			}}
+			if finding.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line, StartColumn: finding.Column}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
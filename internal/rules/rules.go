@@ -0,0 +1,145 @@
+// Package rules reads rule files, which describe integrations that wrap the objects created by a library without
+// writing Go code.
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ScopeTraced applies a rule in the functions that a transaction is passed to, such as main.
+	ScopeTraced = "traced"
+	// ScopeAll applies a rule in every function of the application.
+	ScopeAll = "all"
+
+	// AssignmentDefine matches assignments that declare variables, such as "x := f()".
+	AssignmentDefine = "define"
+	// AssignmentAssign matches assignments to existing variables, such as "x = f()".
+	AssignmentAssign = "assign"
+	// AssignmentAny matches both kinds of assignment.
+	AssignmentAny = "any"
+)
+
+// File is a rule file.
+//
+//	rules:
+//	  - name: kafka-consumer
+//	    description: adds middleware to kafka consumers
+//	    match:
+//	      package: example.com/platform/kafka
+//	      functions: [NewConsumer]
+//	    insert:
+//	      after: "{{.Variable}}.Use(nrkafka.Middleware({{.Agent}}))"
+//	      imports: [example.com/platform/kafka/nrkafka]
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule matches the assignment of the result of a call to a function of a library, and inserts code that
+// instruments the assigned object.
+type Rule struct {
+	Name        string `yaml:"name"`        // the name used to select the rule with --only and --disable
+	Description string `yaml:"description"` // what the rule instruments
+	Scope       string `yaml:"scope"`       // where the rule is applied: traced (default) or all
+	Match       Match  `yaml:"match"`
+	Insert      Insert `yaml:"insert"`
+}
+
+// Match describes the assignments that a rule applies to.
+type Match struct {
+	Package    string   `yaml:"package"`    // the import path of the package that declares the functions
+	Functions  []string `yaml:"functions"`  // the names of the functions whose results are assigned
+	Assignment string   `yaml:"assignment"` // the kind of assignment: define, assign or any (default)
+	Result     int      `yaml:"result"`     // the index of the assigned variable that is instrumented
+}
+
+// Insert describes the code that a rule adds. Templates are Go code, which can use these values:
+//
+//	{{.Variable}}    the variable the result of the call is assigned to
+//	{{.Agent}}       the New Relic application, for rules applied in traced functions
+//	{{.Transaction}} the New Relic transaction, for rules applied in traced functions
+//	{{.Call}}        the matched call, for wrap templates
+type Insert struct {
+	After   string   `yaml:"after"`   // statements that are inserted after the assignment
+	Wrap    string   `yaml:"wrap"`    // an expression that replaces the matched call
+	Imports []string `yaml:"imports"` // the import paths of the packages used by the templates
+}
+
+// Load reads a rule file. Fields that are not part of the format are an error, so that misspelled settings are
+// not silently ignored.
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rule file: %w", err)
+	}
+
+	file := &File{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing rule file %s: %w", path, err)
+	}
+
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if rule.Scope == "" {
+			rule.Scope = ScopeTraced
+		}
+		if rule.Match.Assignment == "" {
+			rule.Match.Assignment = AssignmentAny
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule in %s: %w", path, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// LoadAll reads rule files in order, and returns all of their rules.
+func LoadAll(paths []string) ([]Rule, error) {
+	all := []Rule{}
+	for _, path := range paths {
+		rules, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rules...)
+	}
+	return all, nil
+}
+
+// validate returns an error if a rule is missing a required setting, or has a setting with an unknown value.
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("rules must have a name")
+	}
+	if r.Match.Package == "" {
+		return fmt.Errorf("rule %q must match a package", r.Name)
+	}
+	if len(r.Match.Functions) == 0 {
+		return fmt.Errorf("rule %q must match at least one function", r.Name)
+	}
+	if !slices.Contains([]string{ScopeTraced, ScopeAll}, r.Scope) {
+		return fmt.Errorf("rule %q has unknown scope %q: must be %s or %s", r.Name, r.Scope, ScopeTraced, ScopeAll)
+	}
+	if !slices.Contains([]string{AssignmentDefine, AssignmentAssign, AssignmentAny}, r.Match.Assignment) {
+		return fmt.Errorf("rule %q has unknown assignment %q: must be %s, %s or %s", r.Name, r.Match.Assignment, AssignmentDefine, AssignmentAssign, AssignmentAny)
+	}
+	if r.Match.Result < 0 {
+		return fmt.Errorf("rule %q has a negative result index", r.Name)
+	}
+	if (r.Insert.After == "") == (r.Insert.Wrap == "") {
+		return fmt.Errorf("rule %q must have exactly one of an after or a wrap template", r.Name)
+	}
+	if r.Insert.Wrap != "" && r.Match.Result != 0 {
+		return fmt.Errorf("rule %q wraps calls, which can only be matched when their only result is assigned", r.Name)
+	}
+	return nil
+}
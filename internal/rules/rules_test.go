@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Rule
+		wantErr bool
+	}{
+		{
+			name: "defaults are set",
+			content: `rules:
+  - name: kafka-consumer
+    description: adds middleware to kafka consumers
+    match:
+      package: example.com/platform/kafka
+      functions: [NewConsumer, NewGroupConsumer]
+    insert:
+      after: "{{.Variable}}.Use(nrkafka.Middleware({{.Agent}}))"
+      imports: [example.com/platform/kafka/nrkafka]
+`,
+			want: []Rule{
+				{
+					Name:        "kafka-consumer",
+					Description: "adds middleware to kafka consumers",
+					Scope:       ScopeTraced,
+					Match:       Match{Package: "example.com/platform/kafka", Functions: []string{"NewConsumer", "NewGroupConsumer"}, Assignment: AssignmentAny},
+					Insert:      Insert{After: "{{.Variable}}.Use(nrkafka.Middleware({{.Agent}}))", Imports: []string{"example.com/platform/kafka/nrkafka"}},
+				},
+			},
+		},
+		{
+			name: "all settings",
+			content: `rules:
+  - name: cache
+    scope: all
+    match:
+      package: example.com/platform/cache
+      functions: [Open]
+      assignment: define
+      result: 0
+    insert:
+      wrap: "nrcache.Wrap({{.Call}})"
+      imports: [example.com/platform/cache/nrcache]
+`,
+			want: []Rule{
+				{
+					Name:   "cache",
+					Scope:  ScopeAll,
+					Match:  Match{Package: "example.com/platform/cache", Functions: []string{"Open"}, Assignment: AssignmentDefine},
+					Insert: Insert{Wrap: "nrcache.Wrap({{.Call}})", Imports: []string{"example.com/platform/cache/nrcache"}},
+				},
+			},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    nil,
+		},
+		{
+			name:    "unknown settings are an error",
+			content: "rules:\n  - name: cache\n    packages: example.com/platform/cache\n",
+			wantErr: true,
+		},
+		{
+			name:    "rules must have a name",
+			content: "rules:\n  - match: {package: example.com/platform/cache, functions: [Open]}\n    insert: {after: \"x()\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "rules must match a function",
+			content: "rules:\n  - name: cache\n    match: {package: example.com/platform/cache}\n    insert: {after: \"x()\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "rules must have one template",
+			content: "rules:\n  - name: cache\n    match: {package: example.com/platform/cache, functions: [Open]}\n    insert: {after: \"x()\", wrap: \"y()\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown scopes are an error",
+			content: "rules:\n  - name: cache\n    scope: main\n    match: {package: example.com/platform/cache, functions: [Open]}\n    insert: {after: \"x()\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown assignments are an error",
+			content: "rules:\n  - name: cache\n    match: {package: example.com/platform/cache, functions: [Open], assignment: var}\n    insert: {after: \"x()\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "wrapped calls must only assign one result",
+			content: "rules:\n  - name: cache\n    match: {package: example.com/platform/cache, functions: [Open], result: 1}\n    insert: {wrap: \"y({{.Call}})\"}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		content := "rules:\n  - name: " + name + "\n    match: {package: example.com/" + name + ", functions: [New]}\n    insert: {after: \"x()\"}\n"
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := LoadAll([]string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")})
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "a", got[0].Name)
+		assert.Equal(t, "b", got[1].Name)
+	}
+
+	_, err = LoadAll([]string{filepath.Join(dir, "missing.yaml")})
+	assert.Error(t, err)
+}
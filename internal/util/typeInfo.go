@@ -55,6 +55,84 @@ func TypeOf(expr dst.Expr, pkg *decorator.Package) types.Type {
 	return pkg.TypesInfo.TypeOf(astExpr)
 }
 
+// ObjectOf returns the types.Object that the ident defines or refers to according to go types info
+func ObjectOf(ident *dst.Ident, pkg *decorator.Package) types.Object {
+	if ident == nil || pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+
+	astIdent, ok := pkg.Decorator.Ast.Nodes[ident].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	return pkg.TypesInfo.ObjectOf(astIdent)
+}
+
+// Selection returns the types.Selection of a selector expression that selects a field or method according to go types info.
+// It returns nil if the selector is a qualified identifier, such as a package level function.
+func Selection(sel *dst.SelectorExpr, pkg *decorator.Package) *types.Selection {
+	if sel == nil || pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+
+	astSel, ok := pkg.Decorator.Ast.Nodes[sel].(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	return pkg.TypesInfo.Selections[astSel]
+}
+
+// LookupVariable returns the variable in scope at the position of node that satisfies match and was declared
+// closest before node. Only variables declared before node that are not shadowed at its position are considered,
+// and package level variables are ignored.
+func LookupVariable(node dst.Node, pkg *decorator.Package, match func(*types.Var) bool) *types.Var {
+	if pkg == nil || pkg.Types == nil {
+		return nil
+	}
+
+	astNode := pkg.Decorator.Ast.Nodes[node]
+	if astNode == nil {
+		return nil
+	}
+
+	pos := astNode.Pos()
+	innermost := pkg.Types.Scope().Innermost(pos)
+	var nearest *types.Var
+	for scope := innermost; scope != nil && scope != pkg.Types.Scope(); scope = scope.Parent() {
+		for _, name := range scope.Names() {
+			v, ok := scope.Lookup(name).(*types.Var)
+			if !ok || v.Pos() >= pos || name == "_" || (nearest != nil && v.Pos() < nearest.Pos()) || !match(v) {
+				continue
+			}
+			if _, obj := innermost.LookupParent(name, pos); obj == v {
+				nearest = v
+			}
+		}
+	}
+	return nearest
+}
+
+// IsDeclared returns true if name refers to a declaration in scope at the position of node, including package level
+// declarations, imports and predeclared identifiers.
+func IsDeclared(node dst.Node, pkg *decorator.Package, name string) bool {
+	if pkg == nil || pkg.Types == nil {
+		return false
+	}
+
+	astNode := pkg.Decorator.Ast.Nodes[node]
+	if astNode == nil {
+		return false
+	}
+
+	pos := astNode.Pos()
+	scope := pkg.Types.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent(name, pos)
+	return obj != nil
+}
+
 func IsUnderlyingType(underlyingType types.Type, name string) bool {
 	if underlyingType == nil {
 		return false
@@ -213,3 +291,124 @@ func IsGenerated(decorator *decorator.Decorator, file *dst.File) bool {
 func IsTestPackage(pkg *decorator.Package) bool {
 	return strings.HasSuffix(pkg.ID, ".test") || pkg.ForTest != ""
 }
+
+// IsConstant returns true if the expression is a constant or nil according to go types info.
+func IsConstant(expr dst.Expr, pkg *decorator.Package) bool {
+	if expr == nil || pkg == nil || pkg.TypesInfo == nil {
+		return false
+	}
+
+	astExpr, ok := pkg.Decorator.Ast.Nodes[expr].(ast.Expr)
+	if !ok {
+		return false
+	}
+	tv, ok := pkg.TypesInfo.Types[astExpr]
+	return ok && (tv.Value != nil || tv.IsNil())
+}
+
+// TypeExpression returns an expression that declares the type in a file of pkg. It returns false if the type can
+// not be written there, like unexported types of other packages, type parameters, or anonymous structs and interfaces.
+func TypeExpression(t types.Type, pkg *decorator.Package) (dst.Expr, bool) {
+	switch v := t.(type) {
+	case *types.Basic:
+		if v.Info()&types.IsUntyped != 0 || v.Kind() == types.UnsafePointer {
+			return nil, false
+		}
+		return dst.NewIdent(v.Name()), true
+	case *types.Named:
+		return typeNameExpression(v.Obj(), v.TypeArgs(), pkg)
+	case *types.Alias:
+		return typeNameExpression(v.Obj(), v.TypeArgs(), pkg)
+	case *types.Pointer:
+		elem, ok := TypeExpression(v.Elem(), pkg)
+		return &dst.StarExpr{X: elem}, ok
+	case *types.Slice:
+		elem, ok := TypeExpression(v.Elem(), pkg)
+		return &dst.ArrayType{Elt: elem}, ok
+	case *types.Array:
+		elem, ok := TypeExpression(v.Elem(), pkg)
+		return &dst.ArrayType{Len: &dst.BasicLit{Kind: token.INT, Value: fmt.Sprint(v.Len())}, Elt: elem}, ok
+	case *types.Map:
+		key, keyOk := TypeExpression(v.Key(), pkg)
+		value, valueOk := TypeExpression(v.Elem(), pkg)
+		return &dst.MapType{Key: key, Value: value}, keyOk && valueOk
+	case *types.Chan:
+		elem, ok := TypeExpression(v.Elem(), pkg)
+		dir := dst.SEND | dst.RECV
+		switch v.Dir() {
+		case types.SendOnly:
+			dir = dst.SEND
+		case types.RecvOnly:
+			dir = dst.RECV
+		}
+		return &dst.ChanType{Dir: dir, Value: elem}, ok
+	case *types.Signature:
+		if v.TypeParams().Len() > 0 {
+			return nil, false
+		}
+		params, paramsOk := tupleFields(v.Params(), v.Variadic(), pkg)
+		results, resultsOk := tupleFields(v.Results(), false, pkg)
+		return &dst.FuncType{Params: params, Results: results}, paramsOk && resultsOk
+	case *types.Interface:
+		if !v.Empty() {
+			return nil, false
+		}
+		return &dst.InterfaceType{Methods: &dst.FieldList{}}, true
+	case *types.Struct:
+		if v.NumFields() > 0 {
+			return nil, false
+		}
+		return &dst.StructType{Fields: &dst.FieldList{}}, true
+	}
+	return nil, false
+}
+
+// typeNameExpression returns an expression that refers to a named type, qualified by its package if it is declared
+// in a package other than pkg.
+func typeNameExpression(obj *types.TypeName, typeArgs *types.TypeList, pkg *decorator.Package) (dst.Expr, bool) {
+	ident := dst.NewIdent(obj.Name())
+	if obj.Pkg() != nil && obj.Pkg().Path() != pkg.PkgPath {
+		if !obj.Exported() {
+			return nil, false
+		}
+		ident.Path = obj.Pkg().Path()
+	}
+	if typeArgs.Len() == 0 {
+		return ident, true
+	}
+
+	indices := make([]dst.Expr, typeArgs.Len())
+	for i := range indices {
+		arg, ok := TypeExpression(typeArgs.At(i), pkg)
+		if !ok {
+			return nil, false
+		}
+		indices[i] = arg
+	}
+	if len(indices) == 1 {
+		return &dst.IndexExpr{X: ident, Index: indices[0]}, true
+	}
+	return &dst.IndexListExpr{X: ident, Indices: indices}, true
+}
+
+// tupleFields returns the fields of a parameter or result list, without names.
+func tupleFields(tuple *types.Tuple, variadic bool, pkg *decorator.Package) (*dst.FieldList, bool) {
+	fields := &dst.FieldList{}
+	for i := range tuple.Len() {
+		t := tuple.At(i).Type()
+		if variadic && i == tuple.Len()-1 {
+			elem, ok := TypeExpression(t.(*types.Slice).Elem(), pkg)
+			if !ok {
+				return nil, false
+			}
+			fields.List = append(fields.List, &dst.Field{Type: &dst.Ellipsis{Elt: elem}})
+			continue
+		}
+		expr, ok := TypeExpression(t, pkg)
+		if !ok {
+			return nil, false
+		}
+		fields.List = append(fields.List, &dst.Field{Type: expr})
+	}
+	return fields, true
+}
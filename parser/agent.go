@@ -83,6 +83,72 @@ func findErrorVariable(stmt *dst.AssignStmt, pkg *decorator.Package) dst.Expr {
 	return nil
 }
 
+// errorSourceCall returns the function call that an error is assigned from by a statement, or nil if the error is not
+// assigned from a function call.
+func errorSourceCall(stmt dst.Stmt) *dst.CallExpr {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return nil
+	}
+	call, _ := assign.Rhs[0].(*dst.CallExpr)
+	return call
+}
+
+// errorClass returns the class that errors of a type are grouped by, such as *url.Error. Errors whose type is an
+// interface are grouped by their dynamic type, so an empty string is returned for them.
+func errorClass(t types.Type) string {
+	if t == nil || types.IsInterface(t) {
+		return ""
+	}
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+// calledFunctionName returns the name of the function or method called by a call expression, qualified by its package
+// or receiver type, such as http.Get or http.Client.Do.
+func calledFunctionName(call *dst.CallExpr, pkg *decorator.Package) string {
+	var ident *dst.Ident
+	switch fun := call.Fun.(type) {
+	case *dst.Ident:
+		ident = fun
+	case *dst.SelectorExpr:
+		ident = fun.Sel
+	}
+
+	fn, ok := util.ObjectOf(ident, pkg).(*types.Func)
+	if ident == nil || !ok || fn.Pkg() == nil {
+		return util.WriteExpr(call.Fun, pkg)
+	}
+	if named := namedReceiver(fn); named != nil {
+		return fmt.Sprintf("%s.%s.%s", fn.Pkg().Name(), named.Obj().Name(), fn.Name())
+	}
+	return fmt.Sprintf("%s.%s", fn.Pkg().Name(), fn.Name())
+}
+
+// errorDetails returns the details that an error returned by a function call is noticed with when detailed errors are enabled.
+// The class of the error is derived from the type returned by the call, or from errType if the call is unknown. If detailed
+// errors are not enabled, nil is returned so that the error is noticed as it is.
+func (m *InstrumentationManager) errorDetails(tracing *tracestate.State, call *dst.CallExpr, errType types.Type) []codegen.ErrorDetails {
+	if !m.options.DetailedErrors {
+		return nil
+	}
+
+	pkg := m.getDecoratorPackage()
+	details := codegen.ErrorDetails{Function: tracing.FunctionName()}
+	if call != nil {
+		details.Source = calledFunctionName(call, pkg)
+		if index, ok := errorReturnIndex(call, pkg); ok {
+			errType = util.TypeOf(call, pkg)
+			if tuple, ok := errType.(*types.Tuple); ok {
+				errType = tuple.At(index).Type()
+			}
+		}
+	}
+	details.Class = errorClass(errType)
+	return []codegen.ErrorDetails{details}
+}
+
 // StatelessTracingFunctions
 //////////////////////////////////////////////
 
@@ -94,15 +160,22 @@ func InstrumentMain(manager *InstrumentationManager, c *dstutil.Cursor) {
 		// We don't want to propagate tracing into the setup function so later on in our trace function we will ignore it
 		checkForExistingApplicationInFunctions(manager, c)
 		if decl.Name.Name == "main" {
+			setupEnd := 0
 			if !checkForExistingApplicationInMain(manager, decl) {
 				comment.Debug(manager.getDecoratorPackage(), decl, "Injecting New Relic agent initialization into main()")
-				agentDecl := codegen.InitializeAgent(manager.appName, manager.agentVariableName)
+				configOptions := []dst.Expr{}
+				if manager.options.CapturePanics {
+					configOptions = append(configOptions, codegen.ConfigRecordPanics())
+				}
+				agentDecl := codegen.InitializeAgent(manager.appName, manager.agentVariableName, configOptions...)
 				decl.Body.List = append(agentDecl, decl.Body.List...)
+				setupEnd = len(agentDecl)
 				comment.Debug(manager.getDecoratorPackage(), decl, "Injecting agent shutdown into main()")
 				decl.Body.List = append(decl.Body.List, codegen.ShutdownAgent(manager.agentVariableName))
 				// add go-agent/v3/newrelic to imports
 				manager.addImport(codegen.NewRelicAgentImportPath)
 			}
+			instrumentDefaultHttpClient(manager, decl, setupEnd)
 			newMain, _ := TraceFunction(manager, decl, tracestate.Main(manager.agentVariableName))
 
 			// this will skip the tracing of this function in the outer tree walking algorithm
@@ -214,8 +287,10 @@ func checkForExistingApplicationInMain(manager *InstrumentationManager, decl *ds
 				continue
 			}
 
-			if path.Path == codegen.NewRelicAgentImportPath {
-				manager.agentVariableName = assign.Lhs[0].(*dst.Ident).Name
+			// the transports of http clients are wrapped with a round tripper in main too
+			agent, ok := assign.Lhs[0].(*dst.Ident)
+			if ok && path.Path == codegen.NewRelicAgentImportPath {
+				manager.agentVariableName = agent.Name
 				manager.setupFunc = decl
 				return true
 			}
@@ -283,7 +358,7 @@ func shouldNoticeError(stmt dst.Stmt, pkg *decorator.Package, tracing *tracestat
 // If it finds that an error is returned, it will add a line after the assignment statement to capture an error
 // with a newrelic transaction. All transactions are assumed to be named "txn"
 func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State, functionCallWasTraced bool) bool {
-	if tracing.IsMain() {
+	if tracing.IsMain() || !manager.integrationEnabled(errorsIntegration) {
 		return false
 	}
 
@@ -296,7 +371,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 		for i, result := range nodeVal.Results {
 			call, ok := result.(*dst.CallExpr)
 			if ok {
-				newSmts, retVals := codegen.CaptureErrorReturnCallExpression(pkg, call, tracing.TransactionVariable())
+				newSmts, retVals := codegen.CaptureErrorReturnCallExpression(pkg, call, tracing.TransactionVariable(), manager.errorRules(), manager.errorDetails(tracing, call, nil)...)
 				if newSmts == nil {
 					return false
 				}
@@ -322,25 +397,36 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 			if cachedExpr != nil && util.AssertExpressionEqual(result, cachedExpr) {
 				manager.errorCache.Clear()
 				comment.Debug(pkg, stmt, "Injecting error nil check with NoticeError before return")
-				capture := codegen.IfErrorNotNilNoticeError(cachedExpr, tracing.TransactionVariable())
+				details := manager.errorDetails(tracing, errorSourceCall(manager.errorCache.GetStatement()), util.TypeOf(cachedExpr, pkg))
+				capture := codegen.IfErrorNotNilNoticeError(cachedExpr, tracing.TransactionVariable(), manager.errorRules(), details...)
 				capture.Decs.Before = dst.EmptyLine
 				c.InsertBefore(capture)
 				return true
 			}
 		}
 	case *dst.IfStmt:
+		if noticeRecoveredPanic(manager, nodeVal, c, tracing) {
+			return true
+		}
 		if nodeVal.Init != nil {
 			NoticeError(manager, nodeVal.Init, c, tracing, functionCallWasTraced)
 		}
 		if shouldNoticeError(stmt, pkg, tracing) {
 			errExpr := manager.errorCache.GetExpression()
+			// errors that are already noticed were checked by a previous run
+			if errExpr != nil && noticesError(nodeVal.Body, tracing.TransactionVariable()) {
+				manager.errorCache.Clear()
+				return false
+			}
 			if errExpr != nil {
 				var stmtBlock dst.Stmt
 				if nodeVal.Body != nil && len(nodeVal.Body.List) > 0 {
 					stmtBlock = nodeVal.Body.List[0]
 				}
 				comment.Debug(pkg, stmt, "Injecting NoticeError into error handling block")
-				nodeVal.Body.List = append([]dst.Stmt{codegen.NoticeError(errExpr, tracing.TransactionVariable(), stmtBlock)}, nodeVal.Body.List...)
+				details := manager.errorDetails(tracing, errorSourceCall(manager.errorCache.GetStatement()), util.TypeOf(errExpr, pkg))
+				notice := codegen.NoticeError(errExpr, tracing.TransactionVariable(), stmtBlock, details...)
+				nodeVal.Body.List = append([]dst.Stmt{codegen.NoticeErrorWithRules(notice, errExpr, manager.errorRules())}, nodeVal.Body.List...)
 				manager.errorCache.Clear()
 				return true
 			}
@@ -364,7 +450,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 		cachedErrExpr := manager.errorCache.GetExpression()
 		if cachedErrExpr != nil {
 			stmt := manager.errorCache.GetStatement()
-			comment.Warn(pkg, stmt, stmt, fmt.Sprintf("Unchecked Error \"%s\", please consult New Relic documentation on error capture", util.WriteExpr(cachedErrExpr, pkg)))
+			comment.Warn(pkg, uncheckedErrorRule, stmt, stmt, fmt.Sprintf("Unchecked Error \"%s\", please consult New Relic documentation on error capture", util.WriteExpr(cachedErrExpr, pkg)))
 			manager.errorCache.Clear()
 		}
 
@@ -674,3 +674,310 @@ func main() {
 		})
 	}
 }
+
+func TestInstrumentMainGoroutines(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "start a transaction for each worker goroutine",
+			code: `package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+func worker(id int, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func main() {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		// start a worker
+		go worker(i, wg)
+	}
+	wg.Wait()
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func worker(id int, wg *sync.WaitGroup, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("worker").End()
+
+	defer wg.Done()
+	_, err := http.Get("http://example.com")
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		// start a worker
+		go func(nrTxn *newrelic.Transaction, i int, wg *sync.WaitGroup) {
+			defer nrTxn.End()
+			err := worker(i, wg, nrTxn)
+			if err != nil {
+				nrTxn.NoticeError(err)
+			}
+		}(NewRelicAgent.StartTransaction("worker"), i, wg)
+	}
+	wg.Wait()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "pass transaction in context to worker",
+			code: `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func worker(ctx context.Context, jobs <-chan int) {
+	for job := range jobs {
+		fmt.Println(job)
+	}
+}
+
+func main() {
+	jobs := make(chan int)
+	go worker(context.Background(), jobs)
+	jobs <- 1
+	close(jobs)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func worker(ctx context.Context, jobs <-chan int) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("worker").End()
+
+	for job := range jobs {
+		fmt.Println(job)
+	}
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	jobs := make(chan int)
+	go func(nrTxn *newrelic.Transaction, jobs <-chan int) {
+		defer nrTxn.End()
+		worker(newrelic.NewContext(context.Background(), nrTxn), jobs)
+	}(NewRelicAgent.StartTransaction("worker"), jobs)
+	jobs <- 1
+	close(jobs)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "method receivers are evaluated when the goroutine is launched",
+			code: `package main
+
+import "fmt"
+
+type W struct {
+	n int
+}
+
+func (w *W) run(k int) {
+	fmt.Println(w.n, k)
+}
+
+func getW() *W {
+	return &W{3}
+}
+
+func main() {
+	w := &W{1}
+	go w.run(5)
+	w = &W{2}
+	go getW().run(6)
+	var v W
+	go v.run(7)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type W struct {
+	n int
+}
+
+func (w *W) run(k int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("run").End()
+
+	fmt.Println(w.n, k)
+}
+
+func getW() *W {
+	return &W{3}
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	w := &W{1}
+	go func(nrTxn *newrelic.Transaction, w *W) {
+		defer nrTxn.End()
+		w.run(5, nrTxn)
+	}(NewRelicAgent.StartTransaction("run"), w)
+	w = &W{2}
+	go func(nrTxn *newrelic.Transaction, arg *W) {
+		defer nrTxn.End()
+		arg.run(6, nrTxn)
+	}(NewRelicAgent.StartTransaction("run"), getW())
+	var v W
+	go func(nrTxn *newrelic.Transaction, v *W) {
+		defer nrTxn.End()
+		v.run(7, nrTxn)
+	}(NewRelicAgent.StartTransaction("run"), &v)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "arguments are evaluated when the goroutine is launched",
+			code: `package main
+
+import "fmt"
+
+func worker(name string, count int, total int) {
+	fmt.Println(name, count, total)
+}
+
+func main() {
+	x := 1
+	go worker("jobs", x, x+1)
+	x = 2
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func worker(name string, count int, total int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("worker").End()
+
+	fmt.Println(name, count, total)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	x := 1
+	go func(nrTxn *newrelic.Transaction, arg int, arg2 int) {
+		defer nrTxn.End()
+		worker("jobs", arg, arg2, nrTxn)
+	}(NewRelicAgent.StartTransaction("worker"), x, x+1)
+	x = 2
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "function literal goroutines are not traced",
+			code: `package main
+
+import "fmt"
+
+func main() {
+	go func() {
+		fmt.Println("hello")
+	}()
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	// NR INFO: go-easy-instrumentation doesn't support tracing goroutines in a main method; please instrument manually.
+	// https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines
+	go func() {
+		fmt.Println("hello")
+	}()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, Options{TraceMainGoroutines: true}, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
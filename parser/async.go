@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	asyncLaunchMethod = "Go"
+	asyncWaitMethod   = "Wait"
+)
+
+// asyncLaunchTypes are the types with a Go method that runs a function literal in a new goroutine.
+// The value is true if the Wait method of the type returns the first error returned by the function literals.
+var asyncLaunchTypes = map[string]bool{
+	"sync.WaitGroup":                                     false,
+	"golang.org/x/sync/errgroup.Group":                   true,
+	"github.com/sourcegraph/conc.WaitGroup":              false,
+	"github.com/sourcegraph/conc/pool.Pool":              false,
+	"github.com/sourcegraph/conc/pool.ErrorPool":         true,
+	"github.com/sourcegraph/conc/pool.ContextPool":       true,
+	"github.com/sourcegraph/conc/pool.ResultPool":        false,
+	"github.com/sourcegraph/conc/pool.ResultErrorPool":   false,
+	"github.com/sourcegraph/conc/pool.ResultContextPool": false,
+}
+
+// asyncLaunchType returns the name of the type of expr, including its package path, if it is
+// a type that launches goroutines. Pointers and generic type arguments are ignored.
+func asyncLaunchType(expr dst.Expr, manager *InstrumentationManager) (string, bool) {
+	t := util.TypeOf(expr, manager.getDecoratorPackage())
+	if t == nil {
+		return "", false
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return "", false
+	}
+
+	name := named.Obj().Pkg().Path() + "." + named.Obj().Name()
+	_, ok = asyncLaunchTypes[name]
+	return name, ok
+}
+
+// getAsyncLaunchSite returns the function literal passed to a method that runs it in a new goroutine, such as:
+//
+//	g.Go(func() error { ... })
+func getAsyncLaunchSite(manager *InstrumentationManager, stmt dst.Stmt) (*dst.FuncLit, bool) {
+	exprStmt, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return nil, false
+	}
+
+	call, ok := exprStmt.X.(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != asyncLaunchMethod {
+		return nil, false
+	}
+
+	lit, ok := call.Args[0].(*dst.FuncLit)
+	if !ok {
+		return nil, false
+	}
+
+	_, ok = asyncLaunchType(sel.X, manager)
+	return lit, ok
+}
+
+// hasGoroutineTransaction returns true if the function literal already assigns a new goroutine transaction.
+func hasGoroutineTransaction(lit *dst.FuncLit) bool {
+	if len(lit.Body.List) == 0 {
+		return false
+	}
+
+	assign, ok := lit.Body.List[0].(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return false
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == "NewGoroutine"
+}
+
+// traceAsyncLaunchSite traces a function literal that is run in a new goroutine by an API such as errgroup.Group.Go,
+// sync.WaitGroup.Go, or a conc pool. The signature of the function literal is defined by the API, so a new goroutine
+// transaction is assigned at the start of the function literal body and an async segment is created:
+//
+//	g.Go(func() error {
+//		nrTxn := nrTxn.NewGoroutine()
+//		defer nrTxn.StartSegment("async function literal").End()
+//		...
+//	})
+//
+// The body of the function literal is then traced, so errors returned from it are noticed.
+// Returns true if the launch site was traced.
+func traceAsyncLaunchSite(manager *InstrumentationManager, stmt dst.Stmt, tracing *tracestate.State) bool {
+	if tracing.IsMain() {
+		return false
+	}
+
+	lit, ok := getAsyncLaunchSite(manager, stmt)
+	if !ok || hasGoroutineTransaction(lit) {
+		return false
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, "Tracing function literal run in a new goroutine")
+	TraceFunction(manager, lit, tracing.AsyncFunctionLiteral())
+	codegen.PrependStatementToFunctionLit(lit, codegen.AssignTxnNewGoroutine(codegen.DefaultTransactionVariable, tracing.TransactionVariable()))
+	manager.addImport(codegen.NewRelicAgentImportPath)
+	return true
+}
+
+// NoticeAsyncWaitError notices the error returned by waiting on goroutines launched by an errgroup.Group or
+// a conc pool when the caller discards it:
+//
+//	if err := g.Wait(); err != nil {
+//		nrTxn.NoticeError(err)
+//	}
+//
+// Errors returned by Wait that are assigned to a variable are noticed in the same way as any other error.
+func NoticeAsyncWaitError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if tracing.IsMain() || c.Index() < 0 {
+		return false
+	}
+
+	exprStmt, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+
+	call, ok := exprStmt.X.(*dst.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != asyncWaitMethod {
+		return false
+	}
+
+	name, ok := asyncLaunchType(sel.X, manager)
+	if !ok || !asyncLaunchTypes[name] {
+		return false
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Capturing error returned by %s.Wait", name))
+	errVariable := dst.NewIdent("err")
+	capture := codegen.IfErrorNotNilNoticeError(errVariable, tracing.TransactionVariable(), manager.errorRules(), manager.errorDetails(tracing, call, nil)...)
+	capture.Init = &dst.AssignStmt{
+		Lhs: []dst.Expr{errVariable},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{call},
+	}
+	capture.Decs.NodeDecs = exprStmt.Decs.NodeDecs
+	c.Replace(capture)
+	return true
+}
@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceAsyncLaunchSite(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "errgroup function literals",
+			code: `package main
+
+import (
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func fetchAll(urls []string) error {
+	g := new(errgroup.Group)
+	for _, url := range urls {
+		g.Go(func() error {
+			resp, err := http.Get(url)
+			if err != nil {
+				return err
+			}
+			return resp.Body.Close()
+		})
+	}
+	return g.Wait()
+}
+
+func main() {
+	fetchAll([]string{"http://example.com"})
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"golang.org/x/sync/errgroup"
+)
+
+func fetchAll(urls []string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("fetchAll").End()
+
+	g := new(errgroup.Group)
+	for _, url := range urls {
+		g.Go(func() error {
+			nrTxn := nrTxn.NewGoroutine()
+			defer nrTxn.StartSegment("async function literal").End()
+
+			resp, err := http.Get(url)
+			if err != nil {
+				nrTxn.NoticeError(err)
+				return err
+			}
+
+			// generated by go-easy-instrumentation; returnValue0:error
+			returnValue0 := resp.Body.Close()
+			if returnValue0 != nil {
+				nrTxn.NoticeError(returnValue0)
+			}
+
+			return returnValue0
+		})
+	}
+
+	// generated by go-easy-instrumentation; returnValue0:error
+	returnValue0 := g.Wait()
+	if returnValue0 != nil {
+		nrTxn.NoticeError(returnValue0)
+	}
+
+	return returnValue0
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("fetchAll")
+	fetchAll([]string{"http://example.com"}, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "errgroup with context calling a traced function",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func fetch(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultClient.Do(req)
+	return err
+}
+
+func fetchAll(ctx context.Context, urls []string) {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, url := range urls {
+		g.Go(func() error {
+			return fetch(ctx, url)
+		})
+	}
+	g.Wait()
+}
+
+func main() {
+	fetchAll(context.Background(), []string{"http://example.com"})
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"golang.org/x/sync/errgroup"
+)
+
+func fetch(ctx context.Context, url string) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return err
+	}
+	_, err = http.DefaultClient.Do(req)
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func fetchAll(ctx context.Context, urls []string) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetchAll").End()
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, url := range urls {
+		g.Go(func() error {
+			nrTxn := nrTxn.NewGoroutine()
+			defer nrTxn.StartSegment("async function literal").End()
+
+			return fetch(newrelic.NewContext(ctx, nrTxn), url)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		nrTxn.NoticeError(err)
+	}
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("fetchAll")
+	fetchAll(newrelic.NewContext(context.Background(), nrTxn), []string{"http://example.com"})
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentMain, NoticeAsyncWaitError)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package parser
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// functionID identifies a function declared in the application by its package and the key it is stored by
+// in the traced functions of that package.
+type functionID struct {
+	packageName string
+	key         string
+}
+
+// callGraph is a call graph of the application built from its SSA form with variable type analysis (VTA).
+// It is used to decide which functions are reachable from the entry points of the application, and to find the
+// functions that may be called by calls to function values, such as callbacks or functions stored in struct fields.
+type callGraph struct {
+	graph     *callgraph.Graph
+	functions map[functionID]*ssa.Function    // functions declared in the application
+	callees   map[token.Pos][]*ssa.Function   // functions declared in the application that may be called by the call at a position
+	reachable map[*ssa.Function]bool          // functions reachable from the entry points of the application
+	ids       map[*ssa.Function]functionID    // the inverse of functions
+	packages  map[*types.Package]*ssa.Package // SSA packages of the application
+}
+
+// newCallGraph builds the SSA form of the packages of the application and computes a call graph for it.
+// Test packages are ignored. Packages imported by the application are created from their type information only,
+// so calls made by code outside of the application are not part of the graph.
+func newCallGraph(m *InstrumentationManager) *callGraph {
+	var prog *ssa.Program
+	g := &callGraph{
+		functions: map[functionID]*ssa.Function{},
+		callees:   map[token.Pos][]*ssa.Function{},
+		reachable: map[*ssa.Function]bool{},
+		ids:       map[*ssa.Function]functionID{},
+		packages:  map[*types.Package]*ssa.Package{},
+	}
+
+	created := map[*types.Package]bool{}
+	imported := []*types.Package{}
+	for _, path := range m.getSortedPackages() {
+		pkg := m.packages[path].pkg
+		if util.IsTestPackage(pkg) || pkg.Types == nil || pkg.IllTyped {
+			continue
+		}
+		if prog == nil {
+			prog = ssa.NewProgram(pkg.Fset, ssa.InstantiateGenerics)
+		}
+
+		created[pkg.Types] = true
+		imported = append(imported, pkg.Types.Imports()...)
+		g.packages[pkg.Types] = prog.CreatePackage(pkg.Types, pkg.Package.Syntax, pkg.TypesInfo, false)
+	}
+	if prog == nil {
+		return g
+	}
+
+	for len(imported) > 0 {
+		pkg := imported[0]
+		imported = imported[1:]
+		if created[pkg] {
+			continue
+		}
+		created[pkg] = true
+		imported = append(imported, pkg.Imports()...)
+		prog.CreatePackage(pkg, nil, nil, true)
+	}
+	prog.Build()
+
+	functions := ssautil.AllFunctions(prog)
+	g.graph = vta.CallGraph(functions, cha.CallGraph(prog))
+
+	// methods of types that are never used as a value are not part of the program, but still need an id so that
+	// they are not considered reachable
+	for pkg := range g.packages {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+			named, ok := typeName.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			for i := range named.NumMethods() {
+				if fn := prog.FuncValue(named.Method(i)); fn != nil {
+					functions[fn] = true
+				}
+			}
+		}
+	}
+
+	for fn := range functions {
+		if id, ok := g.functionID(fn); ok {
+			g.functions[id] = fn
+			g.ids[fn] = id
+		}
+	}
+
+	for fn, node := range g.graph.Nodes {
+		if fn == nil || fn.Pkg == nil || g.packages[fn.Pkg.Pkg] == nil {
+			continue
+		}
+		for _, edge := range node.Out {
+			if edge.Site == nil {
+				continue
+			}
+			if _, ok := g.ids[edge.Callee.Func]; ok {
+				pos := edge.Site.Common().Pos()
+				g.callees[pos] = append(g.callees[pos], edge.Callee.Func)
+			}
+		}
+	}
+	return g
+}
+
+// functionID returns the id of a function declared in the application. Function literals, synthetic wrappers
+// and functions declared outside of the application do not have an id.
+func (g *callGraph) functionID(fn *ssa.Function) (functionID, bool) {
+	if fn == nil || fn.Pkg == nil || g.packages[fn.Pkg.Pkg] == nil || fn.Synthetic != "" || fn.Parent() != nil {
+		return functionID{}, false
+	}
+
+	obj, ok := fn.Object().(*types.Func)
+	if !ok {
+		return functionID{}, false
+	}
+
+	key := obj.Name()
+	if fn.Signature.Recv() != nil {
+		named := namedReceiver(obj)
+		if named == nil {
+			return functionID{}, false
+		}
+		key = methodKey(named.Obj().Name(), obj.Name())
+	}
+	return functionID{packageName: obj.Pkg().Path(), key: key}, true
+}
+
+// addEntryPoint marks a function, and every function reachable from it, as reachable.
+func (g *callGraph) addEntryPoint(id functionID) {
+	fn, ok := g.functions[id]
+	if !ok || g.reachable[fn] {
+		return
+	}
+
+	queue := []*ssa.Function{fn}
+	g.reachable[fn] = true
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+
+		next := append([]*ssa.Function{}, fn.AnonFuncs...)
+		if node := g.graph.Nodes[fn]; node != nil {
+			for _, edge := range node.Out {
+				next = append(next, edge.Callee.Func)
+			}
+		}
+
+		for _, callee := range next {
+			if !g.reachable[callee] {
+				g.reachable[callee] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+}
+
+// isReachable returns true if a function is reachable from an entry point of the application.
+// Functions that are not part of the call graph are always considered reachable.
+func (g *callGraph) isReachable(id functionID) bool {
+	fn, ok := g.functions[id]
+	return !ok || g.reachable[fn]
+}
+
+// addCallGraphEntryPoint marks a function declaration as an entry point of the call graph.
+func (m *InstrumentationManager) addCallGraphEntryPoint(packageName string, decl *dst.FuncDecl) {
+	if m.callGraph == nil || decl == nil {
+		return
+	}
+	m.callGraph.addEntryPoint(functionID{packageName: packageName, key: functionKey(decl)})
+}
+
+// passesContext returns true if any argument of a call is a context.Context.
+func passesContext(call *dst.CallExpr, pkg *decorator.Package) bool {
+	for _, arg := range call.Args {
+		t := util.TypeOf(arg, pkg)
+		if t != nil && t.String() == contextType {
+			return true
+		}
+	}
+	return false
+}
+
+// getDynamicInvocationInfo returns the invocation info for a call to a function value using the functions that the
+// call graph found may be called by it. The signature of a function value can not be changed, so the transaction
+// can only be passed through a context argument of the call. If the call graph is not enabled, nil is returned.
+func (m *InstrumentationManager) getDynamicInvocationInfo(call *dst.CallExpr) *invocationInfo {
+	pkg := m.getDecoratorPackage()
+	if m.callGraph == nil || !passesContext(call, pkg) {
+		return nil
+	}
+
+	astCall, ok := pkg.Decorator.Ast.Nodes[call].(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+
+	invocations := []*invocationInfo{}
+	for _, fn := range m.callGraph.callees[astCall.Lparen] {
+		id := m.callGraph.ids[fn]
+		state, ok := m.packages[id.packageName]
+		if !ok || state.tracedFuncs[id.key] == nil {
+			continue
+		}
+
+		invocations = append(invocations, &invocationInfo{
+			functionName: fn.Name(),
+			packageName:  id.packageName,
+			call:         call,
+			decl:         state.tracedFuncs[id.key].body,
+		})
+	}
+
+	switch len(invocations) {
+	case 0:
+		return nil
+	case 1:
+		return invocations[0]
+	default:
+		return &invocationInfo{
+			functionName:    util.WriteExpr(call.Fun, pkg),
+			packageName:     m.getPackageName(),
+			call:            call,
+			implementations: invocations,
+		}
+	}
+}
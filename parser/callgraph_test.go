@@ -0,0 +1,337 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceCallGraph(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "trace a callback passed a context",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+	return err
+}
+
+func run(ctx context.Context, callback func(context.Context) error) error {
+	return callback(ctx)
+}
+
+func main() {
+	run(context.Background(), fetch)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(ctx context.Context) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func run(ctx context.Context, callback func(context.Context) error) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("run").End()
+
+	return callback(ctx)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(newrelic.NewContext(context.Background(), nrTxn), fetch)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "trace a function stored in a struct field",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type worker struct {
+	work func(context.Context) error
+}
+
+func fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+	return err
+}
+
+func (w *worker) run(ctx context.Context) error {
+	return w.work(ctx)
+}
+
+func main() {
+	w := &worker{work: fetch}
+	w.run(context.Background())
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type worker struct {
+	work func(context.Context) error
+}
+
+func fetch(ctx context.Context) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func (w *worker) run(ctx context.Context) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("run").End()
+
+	return w.work(ctx)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	w := &worker{work: fetch}
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	w.run(newrelic.NewContext(context.Background(), nrTxn))
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "implementations that are not reachable are not traced",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type fetcher interface {
+	fetch(ctx context.Context) error
+}
+
+type remote struct{}
+
+func (remote) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+	return err
+}
+
+type unused struct{}
+
+func (unused) fetch(ctx context.Context) error {
+	return check(ctx)
+}
+
+func check(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func main() {
+	var f fetcher = remote{}
+	f.fetch(context.Background())
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type fetcher interface {
+	fetch(ctx context.Context) error
+}
+
+type remote struct{}
+
+func (remote) fetch(ctx context.Context) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return err
+	}
+	_, err = http.DefaultTransport.RoundTrip(req)
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+type unused struct{}
+
+func (unused) fetch(ctx context.Context) error {
+	return check(ctx)
+}
+
+func check(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	var f fetcher = remote{}
+	nrTxn := NewRelicAgent.StartTransaction("fetch")
+	f.fetch(newrelic.NewContext(context.Background(), nrTxn))
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "function values without a context are not traced",
+			code: `package main
+
+import "net/http"
+
+func fetch() error {
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func run(callback func() error) error {
+	return callback()
+}
+
+func main() {
+	run(fetch)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch() error {
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func run(callback func() error, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("run").End()
+
+	// generated by go-easy-instrumentation; returnValue0:error
+	returnValue0 := callback()
+	if returnValue0 != nil {
+		nrTxn.NoticeError(returnValue0)
+	}
+
+	return returnValue0
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(fetch, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, Options{CallGraph: true}, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate/traceobject"
+)
+
+// carrierCall is a call to a function that may be passed the transaction in a struct parameter.
+type carrierCall struct {
+	pkg    *decorator.Package
+	call   *dst.CallExpr
+	caller *types.Func // the function declaration the call is made in, if any
+}
+
+// findCarrierFunctions finds the functions declared in this application that read the transaction from a struct parameter
+// that carries it, rather than being passed it in a new parameter, and returns the index of that parameter for each of them.
+//
+// The transaction can only be added to the struct by the callers of a function, so a struct parameter is only used if
+// every call to the function passes a struct literal, or the struct parameter of a function that also reads the transaction
+// from it. Functions that are used as values, called in go statements, or that implement an interface are passed the
+// transaction like any other function.
+func findCarrierFunctions(m *InstrumentationManager) map[*types.Func]int {
+	candidates := map[*types.Func]int{}
+	for _, path := range m.getSortedPackages() {
+		pkg := m.packages[path].pkg
+		if util.IsTestPackage(pkg) || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fn, ok := declaredFunction(decl, pkg)
+				if !ok || m.implementedInterface(fn) != nil {
+					continue
+				}
+				sig := fn.Type().(*types.Signature)
+				if sig.TypeParams().Len() > 0 || sig.RecvTypeParams().Len() > 0 {
+					continue
+				}
+				if index := traceobject.CarrierParameter(sig); index >= 0 {
+					candidates[fn] = index
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return candidates
+	}
+
+	calls := map[*types.Func][]carrierCall{}
+	for _, path := range m.getSortedPackages() {
+		pkg := m.packages[path].pkg
+		if util.IsTestPackage(pkg) || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				caller, _ := declaredFunction(decl, pkg)
+				callees := map[*dst.Ident]*dst.CallExpr{}
+				goCalls := map[*dst.CallExpr]bool{}
+				dst.Inspect(decl, func(n dst.Node) bool {
+					switch v := n.(type) {
+					case *dst.GoStmt:
+						// the arguments of a go statement can not be changed to carry the transaction
+						goCalls[v.Call] = true
+					case *dst.CallExpr:
+						if ident := calleeIdent(v, pkg); ident != nil && !goCalls[v] {
+							callees[ident] = v
+						}
+					case *dst.Ident:
+						fn, ok := referencedFunction(v, pkg)
+						if !ok {
+							return true
+						}
+						if _, ok := candidates[fn]; !ok {
+							return true
+						}
+						if call, ok := callees[v]; ok {
+							calls[fn] = append(calls[fn], carrierCall{pkg: pkg, call: call, caller: caller})
+						} else if fdecl, ok := decl.(*dst.FuncDecl); !ok || fdecl.Name != v {
+							delete(candidates, fn)
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	// a function stops being a candidate when one of its calls can not be passed the transaction in the struct,
+	// until every remaining candidate can be
+	for changed := true; changed; {
+		changed = false
+		for fn, index := range candidates {
+			for _, c := range calls[fn] {
+				if !passesCarrier(c, index, candidates) {
+					delete(candidates, fn)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// passesCarrier returns true if a call can be passed the transaction in the struct argument at index.
+func passesCarrier(c carrierCall, index int, candidates map[*types.Func]int) bool {
+	if index >= len(c.call.Args) {
+		return false
+	}
+	arg := c.call.Args[index]
+	if traceobject.IsCarrierLiteral(arg) {
+		return true
+	}
+
+	ident, ok := arg.(*dst.Ident)
+	if !ok || c.caller == nil {
+		return false
+	}
+	callerIndex, ok := candidates[c.caller]
+	return ok && util.ObjectOf(ident, c.pkg) == c.caller.Type().(*types.Signature).Params().At(callerIndex)
+}
+
+// declaredFunction returns the function object of a function declaration.
+func declaredFunction(decl dst.Decl, pkg *decorator.Package) (*types.Func, bool) {
+	fdecl, ok := decl.(*dst.FuncDecl)
+	if !ok {
+		return nil, false
+	}
+	fn, ok := util.ObjectOf(fdecl.Name, pkg).(*types.Func)
+	return fn, ok
+}
+
+// calleeIdent returns the identifier that names the function or method called by a call, or nil if it calls a
+// function value or a method expression.
+func calleeIdent(call *dst.CallExpr, pkg *decorator.Package) *dst.Ident {
+	switch fun := call.Fun.(type) {
+	case *dst.Ident:
+		return fun
+	case *dst.SelectorExpr:
+		if sel := util.Selection(fun, pkg); sel != nil && sel.Kind() == types.MethodVal {
+			return fun.Sel
+		}
+	}
+	return nil
+}
+
+// referencedFunction returns the function or method that an identifier refers to.
+// Qualified identifiers, such as pkg.Func, are resolved to the function of the package they refer to.
+func referencedFunction(ident *dst.Ident, pkg *decorator.Package) (*types.Func, bool) {
+	var obj types.Object
+	switch node := pkg.Decorator.Ast.Nodes[ident].(type) {
+	case *ast.Ident:
+		obj = pkg.TypesInfo.ObjectOf(node)
+	case *ast.SelectorExpr:
+		obj = pkg.TypesInfo.ObjectOf(node.Sel)
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, false
+	}
+	return fn.Origin(), true
+}
+
+// carrierParameter returns the index of the struct parameter that the function called by an invocation reads the
+// transaction from, or false if it is passed the transaction like any other function.
+func (m *InstrumentationManager) carrierParameter(inv *invocationInfo) (int, bool) {
+	if inv.decl == nil || inv.interfaceMethod != nil {
+		return 0, false
+	}
+	state, ok := m.packages[inv.packageName]
+	if !ok {
+		return 0, false
+	}
+	fn, ok := util.ObjectOf(inv.decl.Name, state.pkg).(*types.Func)
+	if !ok {
+		return 0, false
+	}
+	index, ok := m.carrierFunctions[fn]
+	return index, ok
+}
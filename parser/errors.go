@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+)
+
+// ErrorRule is a sentinel error, such as io.EOF, or an error type, such as *net/url.Error, that errors are matched against
+// before they are noticed. Errors that match a rule are not noticed, or are noticed as expected errors.
+type ErrorRule struct {
+	PackagePath string // the import path of the package that declares the error
+	Name        string // the name of the sentinel error variable or error type
+	Pointer     bool   // the rule is a pointer to the error type
+	Expected    bool   // matching errors are noticed as expected errors rather than not being noticed
+}
+
+// ParseErrorRule parses an error rule from the import path of a package and the name of an error declared in it,
+// such as io.EOF, database/sql.ErrNoRows or *net/url.Error.
+func ParseErrorRule(rule string, expected bool) (ErrorRule, error) {
+	name := strings.TrimPrefix(rule, "*")
+	slash := strings.LastIndex(name, "/")
+	dot := strings.LastIndex(name, ".")
+	if dot <= slash || dot == 0 || !token.IsIdentifier(name[dot+1:]) {
+		return ErrorRule{}, fmt.Errorf("invalid error %q: must be a package path and the name of an error declared in it, such as io.EOF or *net/url.Error", rule)
+	}
+	return ErrorRule{
+		PackagePath: name[:dot],
+		Name:        name[dot+1:],
+		Pointer:     name != rule,
+		Expected:    expected,
+	}, nil
+}
+
+// String returns the rule in the form it is parsed from.
+func (r ErrorRule) String() string {
+	if r.Pointer {
+		return "*" + r.PackagePath + "." + r.Name
+	}
+	return r.PackagePath + "." + r.Name
+}
+
+// lookupImportedObject returns the object declared with a name in the package with the given path, if it is the package
+// itself or one of the packages it imports directly or indirectly.
+func lookupImportedObject(pkg *types.Package, path, name string) types.Object {
+	visited := map[*types.Package]bool{pkg: true}
+	queue := []*types.Package{pkg}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.Path() == path {
+			return current.Scope().Lookup(name)
+		}
+		for _, imported := range current.Imports() {
+			if !visited[imported] {
+				visited[imported] = true
+				queue = append(queue, imported)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveErrorRule resolves an error rule to the sentinel error or error type that errors are matched against in a package.
+// Returns false if the error is not declared in a package that the package depends on, can not be referenced from it, or is
+// not an error.
+func resolveErrorRule(pkg *types.Package, rule ErrorRule) (codegen.ErrorRule, bool) {
+	obj := lookupImportedObject(pkg, rule.PackagePath, rule.Name)
+	if obj == nil || (obj.Pkg() != pkg && !obj.Exported()) {
+		return codegen.ErrorRule{}, false
+	}
+
+	var target dst.Expr = &dst.Ident{Name: obj.Name(), Path: obj.Pkg().Path()}
+	if obj.Pkg() == pkg {
+		target = dst.NewIdent(obj.Name())
+	}
+
+	errorType := types.Universe.Lookup("error").Type()
+	switch v := obj.(type) {
+	case *types.Var:
+		if rule.Pointer || !types.AssignableTo(v.Type(), errorType) {
+			return codegen.ErrorRule{}, false
+		}
+		return codegen.ErrorRule{Target: target, Expected: rule.Expected}, true
+	case *types.TypeName:
+		t := v.Type()
+		if rule.Pointer {
+			if types.IsInterface(t) {
+				return codegen.ErrorRule{}, false
+			}
+			t = types.NewPointer(t)
+			target = &dst.StarExpr{X: target}
+		}
+		if !types.Implements(t, errorType.Underlying().(*types.Interface)) {
+			return codegen.ErrorRule{}, false
+		}
+		return codegen.ErrorRule{Target: target, Type: true, Expected: rule.Expected}, true
+	}
+	return codegen.ErrorRule{}, false
+}
+
+// errorRules returns the error rules that can be resolved in the current package. Errors noticed in the package are checked
+// against these rules. Rules for errors that the package does not depend on are left out, since errors can not match them.
+func (m *InstrumentationManager) errorRules() []codegen.ErrorRule {
+	pkg := m.getDecoratorPackage()
+	if len(m.options.ErrorRules) == 0 || pkg == nil || pkg.Types == nil {
+		return nil
+	}
+
+	rules, ok := m.resolvedErrorRules[pkg.Types.Path()]
+	if !ok {
+		for _, rule := range m.options.ErrorRules {
+			if resolved, ok := resolveErrorRule(pkg.Types, rule); ok {
+				rules = append(rules, resolved)
+			}
+		}
+		m.resolvedErrorRules[pkg.Types.Path()] = rules
+	}
+	return rules
+}
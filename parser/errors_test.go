@@ -0,0 +1,445 @@
+package parser
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetailedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "errors assigned from a function call are noticed with their source",
+			code: `package main
+
+import "net/http"
+
+func fetch(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func main() {
+	fetch("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(url string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("fetch").End()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		nrTxn.NoticeError(newrelic.Error{
+			Message: err.Error(),
+			Class:   fmt.Sprintf("%T", err),
+			Attributes: map[string]interface{}{
+				"code.function": "fetch",
+				"error.source":  "http.Get",
+			},
+		})
+		return err
+	}
+
+	// generated by go-easy-instrumentation; returnValue0:error
+	returnValue0 := resp.Body.Close()
+	if returnValue0 != nil {
+		nrTxn.NoticeError(newrelic.Error{
+			Message: returnValue0.Error(),
+			Class:   fmt.Sprintf("%T", returnValue0),
+			Attributes: map[string]interface{}{
+				"code.function": "fetch",
+				"error.source":  "io.Closer.Close",
+			},
+		})
+	}
+
+	return returnValue0
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("fetch")
+	fetch("http://example.com", nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "errors returned by a method are noticed with the receiver type",
+			code: `package main
+
+import "os"
+
+type Store struct {
+	path string
+}
+
+func (s *Store) Load() ([]byte, error) {
+	return os.ReadFile(s.path)
+}
+
+func main() {
+	s := &Store{path: "data.json"}
+	s.Load()
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct {
+	path string
+}
+
+func (s *Store) Load(nrTxn *newrelic.Transaction) ([]byte, error) {
+	defer nrTxn.StartSegment("Load").End()
+
+	// generated by go-easy-instrumentation; returnValue0:[]byte, returnValue1:error
+	returnValue0, returnValue1 := os.ReadFile(s.path)
+	if returnValue1 != nil {
+		nrTxn.NoticeError(newrelic.Error{
+			Message: returnValue1.Error(),
+			Class:   fmt.Sprintf("%T", returnValue1),
+			Attributes: map[string]interface{}{
+				"code.function": "Store.Load",
+				"error.source":  "os.ReadFile",
+			},
+		})
+	}
+
+	return returnValue0, returnValue1
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{path: "data.json"}
+	nrTxn := NewRelicAgent.StartTransaction("Load")
+	s.Load(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, Options{DetailedErrors: true}, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func Test_errorClass(t *testing.T) {
+	pkg := types.NewPackage("net/url", "url")
+	named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Error", nil), types.NewStruct(nil, nil), nil)
+
+	assert.Equal(t, "*url.Error", errorClass(types.NewPointer(named)))
+	assert.Equal(t, "", errorClass(types.Universe.Lookup("error").Type()))
+	assert.Equal(t, "", errorClass(nil))
+}
+
+func TestErrorRules(t *testing.T) {
+	rules := []ErrorRule{
+		{PackagePath: "database/sql", Name: "ErrNoRows"},
+		{PackagePath: "net/url", Name: "Error", Pointer: true},
+		{PackagePath: "io", Name: "EOF", Expected: true},
+		{PackagePath: "example.com/missing", Name: "ErrGone"},
+	}
+	tests := []struct {
+		name    string
+		code    string
+		options Options
+		expect  string
+	}{
+		{
+			name: "errors are checked against the rules that can be resolved in the package",
+			code: `package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+)
+
+func load(db *sql.DB, id int) (string, error) {
+	var name string
+	err := db.QueryRow("SELECT name FROM items WHERE id = ?", id).Scan(&name)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func fetch(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func main() {
+	db, _ := sql.Open("mysql", "")
+	load(db, 1)
+	fetch("http://example.com")
+}
+`,
+			options: Options{ErrorRules: rules},
+			expect: `package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func load(db *sql.DB, id int, nrTxn *newrelic.Transaction) (string, error) {
+	defer nrTxn.StartSegment("load").End()
+
+	var name string
+	err := db.QueryRow("SELECT name FROM items WHERE id = ?", id).Scan(&name)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			nrTxn.NoticeExpectedError(err)
+		} else if !errors.Is(err, sql.ErrNoRows) && !errors.As(err, new(*url.Error)) {
+			nrTxn.NoticeError(err)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+func fetch(rawURL string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("fetch").End()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			nrTxn.NoticeExpectedError(err)
+		} else if !errors.Is(err, sql.ErrNoRows) && !errors.As(err, new(*url.Error)) {
+			nrTxn.NoticeError(err)
+		}
+		return err
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			nrTxn.NoticeExpectedError(err)
+		} else if !errors.Is(err, sql.ErrNoRows) && !errors.As(err, new(*url.Error)) {
+			nrTxn.NoticeError(err)
+		}
+		return err
+	}
+
+	// generated by go-easy-instrumentation; returnValue0:error
+	returnValue0 := resp.Body.Close()
+	if returnValue0 != nil {
+		if errors.Is(returnValue0, io.EOF) {
+			nrTxn.NoticeExpectedError(returnValue0)
+		} else if !errors.Is(returnValue0, sql.ErrNoRows) && !errors.As(returnValue0, new(*url.Error)) {
+			nrTxn.NoticeError(returnValue0)
+		}
+	}
+
+	return returnValue0
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	db, _ := sql.Open("mysql", "")
+	nrTxn := NewRelicAgent.StartTransaction("load")
+	load(db, 1, nrTxn)
+	nrTxn.End()
+	nrTxn = NewRelicAgent.StartTransaction("fetch")
+	fetch("http://example.com", nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "expected errors are noticed with details",
+			code: `package main
+
+import "io"
+
+func read(r io.Reader) error {
+	_, err := r.Read(make([]byte, 8))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func main() {
+	read(nil)
+}
+`,
+			options: Options{ErrorRules: rules[2:3], DetailedErrors: true},
+			expect: `package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func read(r io.Reader, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("read").End()
+
+	_, err := r.Read(make([]byte, 8))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			nrTxn.NoticeExpectedError(newrelic.Error{
+				Message: err.Error(),
+				Class:   fmt.Sprintf("%T", err),
+				Attributes: map[string]interface{}{
+					"code.function": "read",
+					"error.source":  "io.Reader.Read",
+				},
+			})
+		} else {
+			nrTxn.NoticeError(newrelic.Error{
+				Message: err.Error(),
+				Class:   fmt.Sprintf("%T", err),
+				Attributes: map[string]interface{}{
+					"code.function": "read",
+					"error.source":  "io.Reader.Read",
+				},
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("read")
+	read(nil, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, tt.options, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestParseErrorRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		expected bool
+		want     ErrorRule
+		wantErr  bool
+	}{
+		{
+			name: "sentinel error in the standard library",
+			rule: "io.EOF",
+			want: ErrorRule{PackagePath: "io", Name: "EOF"},
+		},
+		{
+			name:     "expected sentinel error in a nested package",
+			rule:     "database/sql.ErrNoRows",
+			expected: true,
+			want:     ErrorRule{PackagePath: "database/sql", Name: "ErrNoRows", Expected: true},
+		},
+		{
+			name: "pointer to an error type in a module with a dot in its path",
+			rule: "*github.com/example/app.v2/store.NotFoundError",
+			want: ErrorRule{PackagePath: "github.com/example/app.v2/store", Name: "NotFoundError", Pointer: true},
+		},
+		{
+			name:    "package without a name",
+			rule:    "net/url",
+			wantErr: true,
+		},
+		{
+			name:    "name without a package",
+			rule:    "ErrNotFound",
+			wantErr: true,
+		},
+		{
+			name:    "name that is not an identifier",
+			rule:    "io.",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseErrorRule(tt.rule, tt.expected)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.rule, got.String())
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+const (
+	transactionsDocs = "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/"
+	segmentsDocs     = "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-segments/"
+	errorsDocs       = "https://docs.newrelic.com/docs/apm/agents/go-agent/api-guides/guide-using-go-agent-api/#errors"
+	externalDocs     = "https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests"
+	toolDocs         = "https://github.com/newrelic/go-easy-instrumentation"
+)
+
+// The rules of the info and warning comments that the instrumentation adds, which identify them in reports. Calls to
+// net/http methods that can not be instrumented are commented on without Info or Warn, and are recorded as warnings.
+var (
+	goroutineInMainRule      = comment.Rule{ID: "goroutine-in-main", Integration: coreIntegrationName, Docs: transactionsDocs + "#goroutines"}
+	uncheckedErrorRule       = comment.Rule{ID: "unchecked-error", Integration: errorsIntegration, Docs: errorsDocs}
+	interfaceCallRule        = comment.Rule{ID: "interface-method-call", Integration: coreIntegrationName, Docs: segmentsDocs}
+	transactionVariantRule   = comment.Rule{ID: "transaction-variant", Integration: coreIntegrationName, Docs: transactionsDocs}
+	unassignedHttpErrorRule  = comment.Rule{ID: "unassigned-http-error", Integration: httpClientIntegration, Docs: externalDocs}
+	httpMethodRule           = comment.Rule{ID: "http-method-not-instrumented", Integration: httpClientIntegration, Docs: externalDocs}
+	routeTransactionNameRule = comment.Rule{ID: "route-transaction-name", Integration: httpServerIntegration, Docs: transactionsDocs}
+	functionLiteralRule      = comment.Rule{ID: "function-literal-segment", Integration: ginIntegration, Docs: segmentsDocs}
+	notRemovedRule           = comment.Rule{ID: "instrumentation-not-removed", Docs: toolDocs}
+)
+
+// pluginRule returns the rule of the warnings that the integration of a plugin adds.
+func pluginRule(integration string) comment.Rule {
+	return comment.Rule{ID: integration + "-warning", Integration: integration}
+}
+
+// Summary counts the instrumentation that was added to the application: the transactions that are started, the
+// segments that are created for traced functions, the errors that are noticed, and the requests and clients that
+// external calls are traced for. Instrumentation that the application already had is not counted.
+func (m *InstrumentationManager) Summary() report.Summary {
+	instrumented := m.countInstrumentation()
+	return report.Summary{
+		Transactions:  max(instrumented.Transactions-m.existing.Transactions, 0),
+		Segments:      max(instrumented.Segments-m.existing.Segments, 0),
+		Errors:        max(instrumented.Errors-m.existing.Errors, 0),
+		ExternalCalls: max(instrumented.ExternalCalls-m.existing.ExternalCalls, 0),
+	}
+}
+
+// countInstrumentation counts the instrumentation in the files of the packages. Files that are part of more than one
+// package, like the files of a package and its test variant, are counted once.
+func (m *InstrumentationManager) countInstrumentation() report.Summary {
+	summary := report.Summary{}
+	counted := map[string]bool{}
+	for _, id := range m.getSortedPackages() {
+		pkg := m.packages[id].pkg
+		for _, file := range pkg.Syntax {
+			path := pkg.Decorator.Filenames[file]
+			if counted[path] || util.IsGenerated(pkg.Decorator, file) {
+				continue
+			}
+			counted[path] = true
+			dst.Inspect(file, func(n dst.Node) bool {
+				countInstrumentation(n, &summary)
+				return true
+			})
+		}
+	}
+	return summary
+}
+
+// countInstrumentation adds a node to the count of the instrumentation if it is a call to the agent, or to one of its
+// integrations, that starts a transaction, creates a segment, notices an error, or traces external calls.
+func countInstrumentation(node dst.Node, summary *report.Summary) {
+	switch v := node.(type) {
+	case *dst.CallExpr:
+		if sel, ok := v.Fun.(*dst.SelectorExpr); ok {
+			switch sel.Sel.Name {
+			case "StartTransaction":
+				summary.Transactions++
+			case "StartSegment":
+				summary.Segments++
+			case "NoticeError", "NoticeExpectedError":
+				summary.Errors++
+			}
+		}
+		// http.NewRequestWithContext(newrelic.NewContext(ctx, nrTxn), ...)
+		if isFunctionCall(v, codegen.HttpImportPath, "NewRequestWithContext") && len(v.Args) > 0 && isNewRelicCall(v.Args[0], "NewContext") {
+			summary.ExternalCalls++
+		}
+	case *dst.Ident:
+		// functions are counted whether they are called, like nrgin.Middleware(NewRelicAgent), or passed, like
+		// nrgrpc.UnaryClientInterceptor
+		switch {
+		case v.Path == codegen.NewRelicAgentImportPath:
+			switch v.Name {
+			case "WrapHandle", "WrapHandleFunc":
+				summary.Transactions++
+			case "StartExternalSegment", "RequestWithTransactionContext", "NewRoundTripper":
+				summary.ExternalCalls++
+			}
+		case strings.HasPrefix(v.Path, integrationsImportPathPrefix):
+			switch {
+			case v.Name == "Middleware" || strings.HasSuffix(v.Name, "ServerInterceptor"):
+				summary.Transactions++
+			case strings.HasSuffix(v.Name, "ClientInterceptor"):
+				summary.ExternalCalls++
+			}
+		}
+	}
+}
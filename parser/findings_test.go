@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect report.Summary
+	}{
+		{
+			name: "counts the instrumentation that is added",
+			code: `package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+func work() error {
+	return errors.New("failed")
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	err := work()
+	if err != nil {
+		panic(err)
+	}
+	resp, err := http.DefaultClient.Get("https://example.com")
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+}
+`,
+			// the transaction of main and of the handler, the segment of work, its error in main, and the default client
+			expect: report.Summary{Transactions: 2, Segments: 1, Errors: 1, ExternalCalls: 1},
+		},
+		{
+			name: "does not count the instrumentation the application already had",
+			code: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("work").End()
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("main")
+	work(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			steps := []func() error{
+				manager.DetectDependencyIntegrations,
+				manager.TracePackageCalls,
+				manager.ScanApplication,
+				manager.InstrumentApplication,
+			}
+			for _, step := range steps {
+				if err := step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assert.Equal(t, tt.expect, manager.Summary())
+		})
+	}
+}
+
+func TestFindingsAreReported(t *testing.T) {
+	defer panicRecovery(t)
+	id, err := pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	defer cleanTestApp(t, testDir)
+
+	code := `package main
+
+func work() {}
+
+func main() {
+	go work()
+}
+`
+	if err := comment.EnableReport(testDir); err != nil {
+		t.Fatal(err)
+	}
+	manager := testInstrumentationManager(t, code, testDir)
+	steps := []func() error{
+		manager.DetectDependencyIntegrations,
+		manager.TracePackageCalls,
+		manager.ScanApplication,
+		manager.InstrumentApplication,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect := []comment.Finding{
+		{
+			Level:       comment.LevelInfo,
+			File:        "app.go",
+			Line:        6,
+			Column:      2,
+			Integration: coreIntegrationName,
+			RuleID:      goroutineInMainRule.ID,
+			Docs:        goroutineInMainRule.Docs,
+			Message:     "go-easy-instrumentation doesn't support tracing goroutines in a main method; please instrument manually.",
+			Details:     []string{goroutineInMainRule.Docs},
+		},
+	}
+	assert.Equal(t, expect, comment.Findings())
+}
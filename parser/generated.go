@@ -0,0 +1,471 @@
+package parser
+
+import (
+	"go/token"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+const (
+	// agentInitErrorVariable is the variable that codegen.InitializeAgent assigns the error of the agent to.
+	agentInitErrorVariable = "agentInitError"
+	// integrationsImportPathPrefix is the import path of the packages of the New Relic agent integrations.
+	integrationsImportPathPrefix = "github.com/newrelic/go-agent/v3/integrations/"
+	// wrappedHandlerPrefix is the prefix of the variables that the handlers wrapped by InstrumentSlogHandler are assigned to.
+	wrappedHandlerPrefix = "NR"
+)
+
+// generatedCode recognizes the code that this tool generated in a package. The instrumentation skips code that it
+// already generated, so that instrumenting an application twice changes it only once, and RemoveInstrumentation
+// removes it. Generated code is recognized by its shape, and by the variables it declares: the agent that is assigned
+// along with agentInitError, and the nrTxn transaction.
+type generatedCode struct {
+	instrumented bool            // whether the package uses the generated variables, so that generated code without them is recognized
+	agents       map[string]bool // the variables that the agent is assigned to
+}
+
+// newGeneratedCode finds the generated variables of a package. It must be called before the package is changed.
+func newGeneratedCode(pkg *decorator.Package) *generatedCode {
+	g := &generatedCode{agents: map[string]bool{}}
+	for _, file := range pkg.Syntax {
+		dst.Inspect(file, func(n dst.Node) bool {
+			switch v := n.(type) {
+			case *dst.Ident:
+				g.instrumented = g.instrumented || (v.Path == "" && v.Name == codegen.DefaultTransactionVariable)
+			case *dst.AssignStmt:
+				if agent, ok := agentInitialization(v); ok {
+					g.agents[agent] = true
+					g.instrumented = true
+				}
+			}
+			return true
+		})
+	}
+	return g
+}
+
+// isStatement returns true if a statement was generated by this tool, and can be removed without changing
+// the rest of the code.
+func (g *generatedCode) isStatement(stmt dst.Stmt) bool {
+	switch s := stmt.(type) {
+	case *dst.AssignStmt:
+		return g.isAssignment(s)
+	case *dst.IfStmt:
+		// if agentInitError != nil { panic(agentInitError) }
+		return s.Init == nil && isNotNil(s.Cond, agentInitErrorVariable)
+	case *dst.ExprStmt:
+		call, ok := s.X.(*dst.CallExpr)
+		if !ok {
+			return false
+		}
+		// NewRelicAgent.Shutdown(5 * time.Second), nrTxn.End(), nrTxn.NoticeError(err) and externalSegment.End()
+		if g.isAgentMethodCall(call, "Shutdown") || isTransactionMethodCall(call, "End", "NoticeError", "NoticeExpectedError") || isMethodCall(call, externalSegmentVariable, "End") {
+			return true
+		}
+		// router.Use(nrgochi.Middleware(NewRelicAgent))
+		_, isMethod := call.Fun.(*dst.SelectorExpr)
+		return isMethod && len(call.Args) == 1 && g.isIntegrationArgument(call.Args[0])
+	case *dst.DeferStmt:
+		// defer nrTxn.StartSegment("name").End(), defer nrTxn.End(), and the recovery of panics
+		if isTransactionMethodCall(s.Call, "End") || isPanicRecovery(s.Call) {
+			return true
+		}
+		return isSegment(s)
+	}
+	return false
+}
+
+// isAssignment returns true if an assignment was generated by this tool.
+func (g *generatedCode) isAssignment(assign *dst.AssignStmt) bool {
+	if _, ok := agentInitialization(assign); ok {
+		return true
+	}
+	if len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return false
+	}
+
+	lhs, rhs := assign.Lhs[0], assign.Rhs[0]
+	switch {
+	case isTransaction(lhs):
+		// nrTxn := newrelic.FromContext(ctx), nrTxn := NewRelicAgent.StartTransaction("name"),
+		// nrTxn := nrTxn.NewGoroutine(), and the transactions of integrations, like nrTxn := nrgin.Transaction(c).
+		// Transactions are also started with the agent of applications that were already instrumented.
+		return isNewRelicCall(rhs, "FromContext") || isStartTransaction(rhs) ||
+			isTransactionMethodCall(rhs, "NewGoroutine") || isIntegrationCall(rhs)
+	case isIdent(lhs, externalSegmentVariable):
+		// externalSegment := newrelic.StartExternalSegment(nrTxn, req)
+		return isNewRelicCall(rhs, "StartExternalSegment")
+	case isSelectorOf(lhs, externalSegmentVariable, "Response"):
+		// externalSegment.Response = resp
+		return true
+	case isNewRelicCall(rhs, "NewRoundTripper"):
+		// client.Transport = newrelic.NewRoundTripper(client.Transport)
+		args := rhs.(*dst.CallExpr).Args
+		return g.instrumented && len(args) == 1 && util.AssertExpressionEqual(lhs, args[0])
+	case isNewRelicCall(rhs, "RequestWithTransactionContext"):
+		// req = newrelic.RequestWithTransactionContext(req, nrTxn)
+		args := rhs.(*dst.CallExpr).Args
+		return len(args) == 2 && util.AssertExpressionEqual(lhs, args[0]) && isTransaction(args[1])
+	case isIntegrationCall(rhs):
+		// NRhandler := nrslog.WrapHandler(NewRelicAgent, handler)
+		_, _, ok := wrappedHandler(assign)
+		return ok
+	}
+	return false
+}
+
+// wrappedHandler returns the variable that a slog handler wrapped by InstrumentSlogHandler is assigned to, and the
+// variable of the handler that it wraps:
+//
+//	NRhandler := nrslog.WrapHandler(NewRelicAgent, handler)
+func wrappedHandler(assign *dst.AssignStmt) (string, string, bool) {
+	if len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || !isIntegrationCall(assign.Rhs[0]) {
+		return "", "", false
+	}
+	wrapped, ok := assign.Lhs[0].(*dst.Ident)
+	args := assign.Rhs[0].(*dst.CallExpr).Args
+	if !ok || len(args) == 0 {
+		return "", "", false
+	}
+	handler, ok := args[len(args)-1].(*dst.Ident)
+	if !ok || wrapped.Name != wrappedHandlerPrefix+handler.Name {
+		return "", "", false
+	}
+	return wrapped.Name, handler.Name, true
+}
+
+// isWrappedHandler returns true if a statement wraps a slog handler like InstrumentSlogHandler does.
+func isWrappedHandler(stmt dst.Stmt, handler string) bool {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok {
+		return false
+	}
+	_, wrapped, ok := wrappedHandler(assign)
+	return ok && wrapped == handler
+}
+
+// isIntegrationArgument returns true if an argument adds an integration that uses a generated variable, like
+// nrgochi.Middleware(NewRelicAgent), or an option that adds one, like grpc.UnaryInterceptor(nrgrpc.UnaryServerInterceptor(NewRelicAgent)).
+// Integrations that do not take arguments, like nrgrpc.UnaryClientInterceptor, are also recognized.
+func (g *generatedCode) isIntegrationArgument(arg dst.Expr) bool {
+	if ident, ok := arg.(*dst.Ident); ok {
+		return strings.HasPrefix(ident.Path, integrationsImportPathPrefix)
+	}
+
+	call, ok := arg.(*dst.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+	if isIntegrationCall(call) {
+		return slices.ContainsFunc(call.Args, func(arg dst.Expr) bool {
+			return isTransaction(arg) || g.isAgent(arg)
+		})
+	}
+	return !slices.ContainsFunc(call.Args, func(arg dst.Expr) bool { return !g.isIntegrationArgument(arg) })
+}
+
+// isAgent returns true if an expression is a generated agent variable.
+func (g *generatedCode) isAgent(expr dst.Expr) bool {
+	ident, ok := expr.(*dst.Ident)
+	return ok && ident.Path == "" && g.agents[ident.Name]
+}
+
+// isAgentMethodCall returns true if an expression is a call to a method of a generated agent variable.
+func (g *generatedCode) isAgentMethodCall(expr dst.Expr, method string) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == method && g.isAgent(sel.X)
+}
+
+// isTracedFunction returns true if a function was already traced by TraceFunction: its body starts by declaring the
+// generated transaction, or by starting a segment.
+func isTracedFunction(body *dst.BlockStmt) bool {
+	if body == nil || len(body.List) == 0 {
+		return false
+	}
+
+	switch stmt := body.List[0].(type) {
+	case *dst.AssignStmt:
+		// nrTxn := newrelic.FromContext(ctx), or the transaction carried by a struct, like nrTxn := job.Txn
+		return stmt.Tok == token.DEFINE && len(stmt.Lhs) == 1 && isIdent(stmt.Lhs[0], codegen.DefaultTransactionVariable)
+	case *dst.DeferStmt:
+		return isSegment(stmt)
+	}
+	return false
+}
+
+// isSegment returns true if a statement is a segment created by codegen.DeferSegment:
+//
+//	defer nrTxn.StartSegment("name").End()
+func isSegment(stmt *dst.DeferStmt) bool {
+	sel, ok := stmt.Call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != "End" {
+		return false
+	}
+	start, ok := sel.X.(*dst.CallExpr)
+	return ok && isTransactionMethodCall(start, "StartSegment")
+}
+
+// passesTransaction returns true if the generated transaction is already passed to a call, as an argument, in a
+// context, or in a struct that carries it.
+func passesTransaction(call *dst.CallExpr) bool {
+	return slices.ContainsFunc(call.Args, func(arg dst.Expr) bool {
+		return references(arg, codegen.DefaultTransactionVariable)
+	})
+}
+
+// isTracedGoroutine returns true if a goroutine was already traced, because it is passed the transaction, or starts a
+// transaction of its own like the goroutines generated by traceMainGoroutine.
+func isTracedGoroutine(stmt *dst.GoStmt) bool {
+	return passesTransaction(stmt.Call) || slices.ContainsFunc(stmt.Call.Args, isStartTransaction)
+}
+
+// isExternalCallInstrumented returns true if a call to http.Do was already instrumented by ExternalHttpCall, which
+// adds the transaction to the request, or starts an external segment in the statement before the call.
+func isExternalCallInstrumented(call *dst.CallExpr, previous dst.Stmt) bool {
+	if len(call.Args) > 0 && isNewRelicCall(call.Args[0], "RequestWithTransactionContext") {
+		return true
+	}
+	assign, ok := previous.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return false
+	}
+	return isNewRelicCall(assign.Rhs[0], "StartExternalSegment") || isNewRelicCall(assign.Rhs[0], "RequestWithTransactionContext")
+}
+
+// isMiddleware returns true if a statement adds the middleware of an agent integration to a router, like the
+// middleware added by codegen.NrGinMiddleware:
+//
+//	router.Use(nrgin.Middleware(NewRelicAgent))
+func isMiddleware(stmt dst.Stmt, router string) bool {
+	expr, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*dst.CallExpr)
+	return ok && isSelectorOf(call.Fun, router, "Use") && len(call.Args) == 1 && isIntegrationCall(call.Args[0])
+}
+
+// usesIntegration returns true if a call is already passed the options of an agent integration, like the interceptors
+// added to grpc.NewServer by codegen.NrGrpcUnaryServerInterceptor.
+func usesIntegration(call *dst.CallExpr) bool {
+	used := false
+	for _, arg := range call.Args {
+		dst.Inspect(arg, func(n dst.Node) bool {
+			ident, ok := n.(*dst.Ident)
+			used = used || (ok && strings.HasPrefix(ident.Path, integrationsImportPathPrefix))
+			return !used
+		})
+	}
+	return used
+}
+
+// noticesError returns true if the body of an if statement already notices an error with a transaction, like the
+// checks generated by codegen.NoticeError and codegen.NoticeErrorWithRules.
+func noticesError(body *dst.BlockStmt, transaction dst.Expr) bool {
+	if body == nil || len(body.List) == 0 {
+		return false
+	}
+	noticed := false
+	dst.Inspect(body.List[0], func(n dst.Node) bool {
+		if call, ok := n.(*dst.CallExpr); ok {
+			sel, ok := call.Fun.(*dst.SelectorExpr)
+			noticed = ok && (sel.Sel.Name == "NoticeError" || sel.Sel.Name == "NoticeExpectedError") && util.AssertExpressionEqual(sel.X, transaction)
+		}
+		return !noticed
+	})
+	return noticed
+}
+
+// isStartTransaction returns true if an expression starts a transaction with an agent.
+func isStartTransaction(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == "StartTransaction"
+}
+
+// agentInitialization returns the variable that the agent is assigned to by the statement generated by
+// codegen.InitializeAgent:
+//
+//	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+func agentInitialization(assign *dst.AssignStmt) (string, bool) {
+	if len(assign.Lhs) != 2 || len(assign.Rhs) != 1 || !isIdent(assign.Lhs[1], agentInitErrorVariable) || !isNewRelicCall(assign.Rhs[0], "NewApplication") {
+		return "", false
+	}
+	agent, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok {
+		return "", false
+	}
+	return agent.Name, true
+}
+
+// isTransactionParameter returns true if a field is a transaction parameter created by codegen.NewTransactionParameter.
+func isTransactionParameter(field *dst.Field) bool {
+	if len(field.Names) != 1 || field.Names[0].Name != codegen.DefaultTransactionVariable {
+		return false
+	}
+	star, ok := field.Type.(*dst.StarExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := star.X.(*dst.Ident)
+	return ok && ident.Name == "Transaction" && ident.Path == codegen.NewRelicAgentImportPath
+}
+
+// isTransaction returns true if an expression is the generated transaction, or a copy of it for a goroutine.
+func isTransaction(expr dst.Expr) bool {
+	return isIdent(expr, codegen.DefaultTransactionVariable) || isTransactionMethodCall(expr, "NewGoroutine")
+}
+
+// isTransactionMethodCall returns true if an expression is a call to one of the methods of the generated transaction.
+func isTransactionMethodCall(expr dst.Expr, methods ...string) bool {
+	for _, method := range methods {
+		if isMethodCall(expr, codegen.DefaultTransactionVariable, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMethodCall returns true if an expression is a call to a method of a variable.
+func isMethodCall(expr dst.Expr, variable, method string) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	return isSelectorOf(call.Fun, variable, method)
+}
+
+// isSelectorOf returns true if an expression selects a field or method of a variable.
+func isSelectorOf(expr dst.Expr, variable, name string) bool {
+	sel, ok := expr.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == name && isIdent(sel.X, variable)
+}
+
+// isIdent returns true if an expression is a local identifier with a name.
+func isIdent(expr dst.Expr, name string) bool {
+	ident, ok := expr.(*dst.Ident)
+	return ok && ident.Path == "" && ident.Name == name
+}
+
+// isNewRelicCall returns true if an expression is a call to a function of the newrelic package.
+func isNewRelicCall(expr dst.Expr, name string) bool {
+	return isFunctionCall(expr, codegen.NewRelicAgentImportPath, name)
+}
+
+// isFunctionCall returns true if an expression is a call to a function of a package.
+func isFunctionCall(expr dst.Expr, path, name string) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && ident.Path == path && ident.Name == name
+}
+
+// isIntegrationCall returns true if an expression is a call to a function of one of the agent integrations.
+func isIntegrationCall(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && strings.HasPrefix(ident.Path, integrationsImportPathPrefix)
+}
+
+// isNotNil returns true if an expression checks that a variable is not nil.
+func isNotNil(expr dst.Expr, variable string) bool {
+	binary, ok := expr.(*dst.BinaryExpr)
+	return ok && binary.Op == token.NEQ && isIdent(binary.X, variable) && isIdent(binary.Y, "nil")
+}
+
+// isPanicRecovery returns true if a deferred call is the recovery generated by codegen.DeferRecoverNoticePanic.
+func isPanicRecovery(call *dst.CallExpr) bool {
+	lit, ok := call.Fun.(*dst.FuncLit)
+	if !ok || len(call.Args) != 0 || len(lit.Body.List) != 1 {
+		return false
+	}
+	ifStmt, ok := lit.Body.List[0].(*dst.IfStmt)
+	if !ok || len(ifStmt.Body.List) != 2 {
+		return false
+	}
+	init, ok := ifStmt.Init.(*dst.AssignStmt)
+	if !ok || len(init.Rhs) != 1 {
+		return false
+	}
+	recovered, ok := init.Rhs[0].(*dst.CallExpr)
+	if !ok || !isIdent(recovered.Fun, "recover") {
+		return false
+	}
+	notice, ok := ifStmt.Body.List[0].(*dst.ExprStmt)
+	if !ok || !isTransactionMethodCall(notice.X, "NoticeError") {
+		return false
+	}
+	// recovered panics that are handled by the application are only noticed
+	repanic, ok := ifStmt.Body.List[1].(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	panicCall, ok := repanic.X.(*dst.CallExpr)
+	return ok && isIdent(panicCall.Fun, "panic")
+}
+
+// previousStatement returns the statement before the statement at the cursor in a block, or nil if there is none.
+func previousStatement(c *dstutil.Cursor) dst.Stmt {
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 1 || c.Index() > len(block.List) {
+		return nil
+	}
+	return block.List[c.Index()-1]
+}
+
+// nextStatement returns the statement after the statement at the cursor in a block, or nil if there is none.
+func nextStatement(c *dstutil.Cursor) dst.Stmt {
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 0 || c.Index()+1 >= len(block.List) {
+		return nil
+	}
+	return block.List[c.Index()+1]
+}
+
+// isSameStatement returns true if two simple statements are equivalent. Only expression, assignment and defer
+// statements are compared; any other statement is never considered the same.
+func isSameStatement(a, b dst.Stmt) bool {
+	switch a := a.(type) {
+	case *dst.ExprStmt:
+		b, ok := b.(*dst.ExprStmt)
+		return ok && util.AssertExpressionEqual(a.X, b.X)
+	case *dst.DeferStmt:
+		b, ok := b.(*dst.DeferStmt)
+		return ok && util.AssertExpressionEqual(a.Call, b.Call)
+	case *dst.AssignStmt:
+		b, ok := b.(*dst.AssignStmt)
+		if !ok || a.Tok != b.Tok || len(a.Lhs) != len(b.Lhs) || len(a.Rhs) != len(b.Rhs) {
+			return false
+		}
+		for i := range a.Lhs {
+			if !util.AssertExpressionEqual(a.Lhs[i], b.Lhs[i]) {
+				return false
+			}
+		}
+		for i := range a.Rhs {
+			if !util.AssertExpressionEqual(a.Rhs[i], b.Rhs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
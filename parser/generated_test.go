@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+)
+
+// TestGeneratedCodeIsRecognized checks that the code generated for each kind of instrumentation is recognized by the
+// detector that keeps it from being generated again.
+func TestGeneratedCodeIsRecognized(t *testing.T) {
+	agent := dst.NewIdent("NewRelicAgent")
+	txn := dst.NewIdent(codegen.DefaultTransactionVariable)
+	generated := &generatedCode{instrumented: true, agents: map[string]bool{"NewRelicAgent": true}}
+	isStatement := func(stmt dst.Stmt) bool { return generated.isStatement(stmt) }
+
+	initialization := codegen.InitializeAgent("", "NewRelicAgent")
+	ginMiddleware, _ := codegen.NrGinMiddleware("router", agent)
+	chiMiddleware, _ := codegen.NrChiMiddleware("router", agent)
+	slogHandler, _ := codegen.SlogHandlerWrapper("handler", wrappedHandlerPrefix+"handler")
+	noticeError := codegen.IfErrorNotNilNoticeError(dst.NewIdent("err"), txn, nil)
+	goroutine := codegen.TransactionGoroutine(agent, "work", &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("work")}})
+	client := &dst.AssignStmt{
+		Lhs: []dst.Expr{dst.NewIdent("client")},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{codegen.InstrumentedHttpClient()},
+	}
+	dial := &dst.CallExpr{
+		Fun:  &dst.Ident{Name: "Dial", Path: codegen.GrpcImportPath},
+		Args: []dst.Expr{&dst.BasicLit{Kind: token.STRING, Value: `"localhost:8080"`}},
+	}
+	dial.Args = append(dial.Args, codegen.NrGrpcUnaryClientInterceptor(dial))
+	transactionParameter := codegen.NewTransactionParameter(codegen.DefaultTransactionVariable)
+	segmentFunction := &dst.BlockStmt{List: []dst.Stmt{codegen.DeferSegment("work", txn)}}
+	handlerFunction := &dst.BlockStmt{List: []dst.Stmt{codegen.TxnFromContext(codegen.DefaultTransactionVariable, codegen.HttpRequestContext("r"))}}
+	request := &dst.CallExpr{
+		Fun:  &dst.SelectorExpr{X: dst.NewIdent("client"), Sel: dst.NewIdent("Do")},
+		Args: []dst.Expr{codegen.RequestWithTransactionContext(dst.NewIdent("req"), txn)},
+	}
+
+	tests := []struct {
+		name     string
+		detected bool
+	}{
+		{name: "agent initialization", detected: isStatement(initialization[0])},
+		{name: "agent initialization error check", detected: isStatement(initialization[1])},
+		{name: "agent shutdown", detected: isStatement(codegen.ShutdownAgent("NewRelicAgent"))},
+		{name: "transaction start", detected: isStatement(codegen.StartTransaction("NewRelicAgent", codegen.DefaultTransactionVariable, "work", false))},
+		{name: "transaction end", detected: isStatement(codegen.EndTransaction(codegen.DefaultTransactionVariable))},
+		{name: "transaction from a request", detected: isStatement(codegen.TxnFromContext(codegen.DefaultTransactionVariable, codegen.HttpRequestContext("r")))},
+		{name: "transaction for a goroutine", detected: isStatement(codegen.AssignTxnNewGoroutine(codegen.DefaultTransactionVariable, txn))},
+		{name: "segment", detected: isStatement(codegen.DeferSegment("work", txn))},
+		{name: "panic recovery", detected: isStatement(codegen.DeferRecoverNoticePanic(txn))},
+		{name: "noticed error", detected: isStatement(codegen.NoticeError(dst.NewIdent("err"), txn, nil))},
+		{name: "external segment start", detected: isStatement(codegen.StartExternalSegment(dst.NewIdent("req"), txn, externalSegmentVariable, nil))},
+		{name: "external segment response", detected: isStatement(codegen.CaptureHttpResponse(externalSegmentVariable, dst.NewIdent("resp")))},
+		{name: "external segment end", detected: isStatement(codegen.EndExternalSegment(externalSegmentVariable, nil))},
+		{name: "round tripper", detected: isStatement(codegen.RoundTripper(dst.NewIdent("client"), dst.None))},
+		{name: "request with transaction", detected: isStatement(codegen.WrapRequestContext(dst.NewIdent("req"), txn, nil))},
+		{name: "gin middleware", detected: isStatement(ginMiddleware) && isMiddleware(ginMiddleware, "router")},
+		{name: "chi middleware", detected: isStatement(chiMiddleware) && isMiddleware(chiMiddleware, "router")},
+		{name: "slog handler", detected: isStatement(slogHandler) && isWrappedHandler(slogHandler, "handler")},
+		{name: "error check", detected: noticesError(noticeError.Body, txn)},
+		{name: "goroutine with a transaction", detected: isTracedGoroutine(goroutine)},
+		{name: "instrumented http client", detected: hasRoundTripperTransport(client)},
+		{name: "grpc interceptor", detected: usesIntegration(dial)},
+		{name: "transaction parameter", detected: isTransactionParameter(transactionParameter)},
+		{name: "function with a segment", detected: isTracedFunction(segmentFunction)},
+		{name: "handler with a transaction", detected: isTracedFunction(handlerFunction)},
+		{name: "call passed a transaction", detected: passesTransaction(&dst.CallExpr{Fun: dst.NewIdent("work"), Args: []dst.Expr{txn}})},
+		{name: "external call", detected: isExternalCallInstrumented(request, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.detected {
+				t.Errorf("the generated %s was not recognized", tt.name)
+			}
+		})
+	}
+}
+
+func TestIsSameStatement(t *testing.T) {
+	call := func(name string) dst.Stmt {
+		return &dst.ExprStmt{X: &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent("db"), Sel: dst.NewIdent(name)}}}
+	}
+	assign := func(tok token.Token) dst.Stmt {
+		return &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("x")}, Tok: tok, Rhs: []dst.Expr{dst.NewIdent("y")}}
+	}
+	tests := []struct {
+		name string
+		a, b dst.Stmt
+		want bool
+	}{
+		{name: "same call", a: call("Close"), b: call("Close"), want: true},
+		{name: "different call", a: call("Close"), b: call("Ping"), want: false},
+		{name: "same assignment", a: assign(token.DEFINE), b: assign(token.DEFINE), want: true},
+		{name: "different assignment", a: assign(token.DEFINE), b: assign(token.ASSIGN), want: false},
+		{name: "different statements", a: call("Close"), b: assign(token.DEFINE), want: false},
+		{name: "no statement", a: call("Close"), b: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSameStatement(tt.a, tt.b); got != tt.want {
+				t.Errorf("isSameStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
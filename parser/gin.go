@@ -79,7 +79,7 @@ func defineTxnFromGinCtx(body *dst.BlockStmt, txnVariable string, ctxName string
 func InstrumentGinMiddleware(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
 	// Check if any return true for ginMiddlewareCall
 	routerName := ginMiddlewareCall(stmt)
-	if routerName == "" {
+	if routerName == "" || isMiddleware(nextStatement(c), routerName) {
 		return false
 	}
 	// Append at the current stmt location
@@ -115,7 +115,7 @@ func InstrumentGinFunction(manager *InstrumentationManager, c *dstutil.Cursor) {
 
 	case *dst.FuncLit:
 		ctxName := getGinContextFromHandler(v.Type, manager.getDecoratorPackage())
-		if ctxName == "" {
+		if ctxName == "" || isTracedFunction(v.Body) {
 			return
 		}
 
@@ -125,6 +125,6 @@ func InstrumentGinFunction(manager *InstrumentationManager, c *dstutil.Cursor) {
 		tc := tracestate.FunctionBody(codegen.DefaultTransactionVariable).FuncLiteralDeclaration(manager.getDecoratorPackage(), funcLit)
 		tc.CreateSegment(funcLit)
 		defineTxnFromGinCtx(funcLit.Body, txnName, ctxName)
-		comment.Warn(manager.getDecoratorPackage(), c.Parent(), c.Node(), "function literal segments will be named \"function literal\" by default", "declare a function instead to improve segment name generation")
+		comment.Warn(manager.getDecoratorPackage(), functionLiteralRule, c.Parent(), c.Node(), "function literal segments will be named \"function literal\" by default", "declare a function instead to improve segment name generation")
 	}
 }
@@ -112,7 +112,7 @@ func getChiHTTPHandlerRouteName(callExpr *dst.CallExpr) (string, *dst.FuncLit) {
 // instrument the routes registered to the router.
 func InstrumentChiMiddleware(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
 	routerName := getChiRouterName(stmt)
-	if routerName == "" {
+	if routerName == "" || isMiddleware(nextStatement(c), routerName) {
 		return false
 	}
 
@@ -135,7 +135,7 @@ func InstrumentChiRouterLiteral(manager *InstrumentationManager, stmt dst.Stmt,
 
 	routeName, fnLit := getChiHTTPHandlerRouteName(callExpr)
 	routeName, err := strconv.Unquote(routeName)
-	if routeName == "" || fnLit == nil || err != nil {
+	if routeName == "" || fnLit == nil || err != nil || isTracedFunction(fnLit.Body) {
 		return false
 	}
 
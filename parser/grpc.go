@@ -171,7 +171,7 @@ func InstrumentGrpcServerMethod(manager *InstrumentationManager, c *dstutil.Curs
 // This function does not need any tracing context to work, nor will it produce any tracing context
 func InstrumentGrpcDial(manager *InstrumentationManager, c *dstutil.Cursor) {
 	currentNode := c.Node()
-	if callExpr, ok := grpcDialCall(currentNode); ok {
+	if callExpr, ok := grpcDialCall(currentNode); ok && !usesIntegration(callExpr) {
 		comment.Debug(manager.getDecoratorPackage(), currentNode, "Injecting gRPC client interceptors into grpc.Dial")
 		callExpr.Args = append(callExpr.Args, codegen.NrGrpcUnaryClientInterceptor(callExpr))
 		callExpr.Args = append(callExpr.Args, codegen.NrGrpcStreamClientInterceptor(callExpr))
@@ -186,7 +186,7 @@ func InstrumentGrpcDial(manager *InstrumentationManager, c *dstutil.Cursor) {
 func InstrumentGrpcServer(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
 	// determine if this is a gRPC server initialization
 	callExpr, ok := grpcNewServerCall(stmt)
-	if !ok {
+	if !ok || usesIntegration(callExpr) {
 		return false
 	}
 
@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	httputilImportPath      = "net/http/httputil"
+	reverseProxyType        = "*net/http/httputil.ReverseProxy"
+	newSingleHostProxy      = "NewSingleHostReverseProxy"
+	reverseProxyStruct      = "ReverseProxy"
+	reverseProxyServeMethod = "ServeHTTP"
+)
+
+// isReverseProxyDefinition returns true if the statement assigns a newly created reverse proxy to a single variable:
+//
+//	proxy := httputil.NewSingleHostReverseProxy(target)
+//	proxy := &httputil.ReverseProxy{Director: director}
+func isReverseProxyDefinition(stmt *dst.AssignStmt) bool {
+	if len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+		return false
+	}
+
+	switch v := stmt.Rhs[0].(type) {
+	case *dst.CallExpr:
+		ident, ok := v.Fun.(*dst.Ident)
+		return ok && ident.Name == newSingleHostProxy && ident.Path == httputilImportPath
+	case *dst.UnaryExpr:
+		lit, ok := v.X.(*dst.CompositeLit)
+		if !ok || v.Op != token.AND {
+			return false
+		}
+		ident, ok := lit.Type.(*dst.Ident)
+		return ok && ident.Name == reverseProxyStruct && ident.Path == httputilImportPath
+	}
+	return false
+}
+
+// reverseProxyTransportInstrumented returns true if any statement in the block after index wraps the
+// transport of the proxy with a New Relic round tripper.
+func reverseProxyTransportInstrumented(block *dst.BlockStmt, index int, proxy dst.Expr) bool {
+	for _, stmt := range block.List[index+1:] {
+		assign, ok := stmt.(*dst.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			continue
+		}
+
+		sel, ok := assign.Lhs[0].(*dst.SelectorExpr)
+		if !ok || sel.Sel.Name != "Transport" || !util.AssertExpressionEqual(sel.X, proxy) {
+			continue
+		}
+
+		call, ok := assign.Rhs[0].(*dst.CallExpr)
+		if !ok {
+			continue
+		}
+
+		ident, ok := call.Fun.(*dst.Ident)
+		if ok && ident.Name == "NewRoundTripper" && ident.Path == codegen.NewRelicAgentImportPath {
+			return true
+		}
+	}
+	return false
+}
+
+// InstrumentReverseProxy wraps the transport of a net/http/httputil.ReverseProxy with a New Relic round tripper
+// so that proxied requests are recorded as external segments and carry distributed tracing headers. Any existing
+// transport is preserved and wrapped.
+//
+// The reverse proxy clones the incoming request, including its context, before passing it to the Director. As long
+// as the incoming request context contains the transaction, the round tripper will find it on the outbound request,
+// so the Director does not need to be modified.
+func InstrumentReverseProxy(manager *InstrumentationManager, c *dstutil.Cursor) {
+	stmt, ok := c.Node().(*dst.AssignStmt)
+	if !ok || c.Index() < 0 || !isReverseProxyDefinition(stmt) {
+		return
+	}
+
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || reverseProxyTransportInstrumented(block, c.Index(), stmt.Lhs[0]) {
+		return
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Injecting New Relic round tripper into reverse proxy: %s", util.WriteExpr(stmt.Lhs[0], manager.getDecoratorPackage())))
+	c.InsertAfter(codegen.RoundTripper(stmt.Lhs[0], stmt.Decs.After))
+	stmt.Decs.After = dst.None
+	manager.addImport(codegen.NewRelicAgentImportPath)
+}
+
+// getReverseProxyServeHTTPCall returns the call to ServeHTTP on a reverse proxy made by the statement, if any.
+func getReverseProxyServeHTTPCall(manager *InstrumentationManager, stmt dst.Stmt) (*dst.CallExpr, bool) {
+	exprStmt, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return nil, false
+	}
+
+	call, ok := exprStmt.X.(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return nil, false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != reverseProxyServeMethod {
+		return nil, false
+	}
+
+	t := util.TypeOf(sel.X, manager.getDecoratorPackage())
+	if t == nil || t.String() != reverseProxyType {
+		return nil, false
+	}
+
+	return call, true
+}
+
+// ReverseProxyServeHTTP adds the transaction of a traced function to the context of a request that is passed
+// to a reverse proxy. Incoming requests do not always carry the transaction in their context, for example when
+// the transaction was created by a framework middleware, so it is added to make sure the proxied request
+// is traced:
+//
+//	proxy.ServeHTTP(w, newrelic.RequestWithTransactionContext(r, nrTxn))
+func ReverseProxyServeHTTP(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if tracing.IsMain() {
+		return false
+	}
+
+	call, ok := getReverseProxyServeHTTPCall(manager, stmt)
+	if !ok {
+		return false
+	}
+
+	request, ok := call.Args[1].(*dst.CallExpr)
+	if ok {
+		if ident, ok := request.Fun.(*dst.Ident); ok && ident.Name == "RequestWithTransactionContext" && ident.Path == codegen.NewRelicAgentImportPath {
+			return false
+		}
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, "Injecting transaction context into reverse proxy request")
+	call.Args[1] = codegen.RequestWithTransactionContext(call.Args[1], tracing.TransactionVariable())
+	manager.addImport(codegen.NewRelicAgentImportPath)
+	return true
+}
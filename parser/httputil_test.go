@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentReverseProxy(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "single host reverse proxy",
+			code: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+func main() {
+	target, _ := url.Parse("http://localhost:8080")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	target, _ := url.Parse("http://localhost:8080")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newrelic.NewRoundTripper(proxy.Transport)
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+		},
+		{
+			name: "reverse proxy literal with custom transport",
+			code: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+)
+
+func main() {
+	proxy := &httputil.ReverseProxy{
+		Director:  func(r *http.Request) { r.URL.Host = "localhost:8080" },
+		Transport: &http.Transport{MaxIdleConns: 10},
+	}
+
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	proxy := &httputil.ReverseProxy{
+		Director:  func(r *http.Request) { r.URL.Host = "localhost:8080" },
+		Transport: &http.Transport{MaxIdleConns: 10},
+	}
+	proxy.Transport = newrelic.NewRoundTripper(proxy.Transport)
+
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+		},
+		{
+			name: "reverse proxy already instrumented",
+			code: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	target, _ := url.Parse("http://localhost:8080")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newrelic.NewRoundTripper(proxy.Transport)
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	target, _ := url.Parse("http://localhost:8080")
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newrelic.NewRoundTripper(proxy.Transport)
+	http.ListenAndServe(":8000", proxy)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentReverseProxy)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestReverseProxyServeHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "add transaction to proxied request",
+			code: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+)
+
+func forward(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	proxy.ServeHTTP(w, r)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func forward(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	proxy.ServeHTTP(w, newrelic.RequestWithTransactionContext(r, txn))
+}
+`,
+		},
+		{
+			name: "ignore other ServeHTTP methods",
+			code: `package main
+
+import (
+	"net/http"
+)
+
+func forward(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	handler.ServeHTTP(w, r)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+)
+
+func forward(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	handler.ServeHTTP(w, r)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatefulTracingFunction(t, tt.code, ReverseProxyServeHTTP, true)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dave/dst/decorator"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+// idempotencyTestApps are the end to end test applications that are made of a single file, and only import the standard
+// library and the agent.
+var idempotencyTestApps = []string{
+	"errors/main.go",
+	"http-mux-app/server.go",
+	"semi-instrumented/existing-app/main.go",
+	"semi-instrumented/existing-errors/main.go",
+	"semi-instrumented/existing-transactions/main.go",
+	"semi-instrumented/existing-web-app/main.go",
+}
+
+// instrumentTestApp instruments the application in a test directory, and returns the contents of its file.
+func instrumentTestApp(t *testing.T, testAppDir string, pkgs []*decorator.Package, options Options) string {
+	diffFile := filepath.Join(testAppDir, "new-relic-instrumentation.diff")
+	manager := NewInstrumentationManager(pkgs, Config{AgentVariableName: "NewRelicAgent", Options: options}, diffFile, testAppDir)
+	steps := []func() error{
+		manager.DetectDependencyIntegrations,
+		manager.TracePackageCalls,
+		manager.ScanApplication,
+		manager.InstrumentApplication,
+		manager.ResolveUnitTests,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.WriteFiles(nil, func(string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(testAppDir, "app.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+// testInstrumentationIsIdempotent instruments an application twice, and checks that the second time changes nothing.
+// Applications that do not compile are skipped, but the instrumented application must compile.
+func testInstrumentationIsIdempotent(t *testing.T, code string, options Options) {
+	defer panicRecovery(t)
+	id, err := pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testAppDir := fmt.Sprintf("tmp_%s", id)
+	defer cleanTestApp(t, testAppDir)
+
+	pkgs, err := createTestApp(t, testAppDir, "app.go", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			t.Skipf("the application does not compile: %v", pkg.Errors)
+		}
+	}
+	instrumented := instrumentTestApp(t, testAppDir, pkgs, options)
+
+	pkgs, err = decorator.Load(&packages.Config{Dir: testAppDir, Mode: packages.LoadSyntax})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			t.Fatalf("the instrumented application does not compile: %v\n\n%s", pkg.Errors, instrumented)
+		}
+	}
+	assert.Equal(t, instrumented, instrumentTestApp(t, testAppDir, pkgs, options), "instrumenting the application again changed it")
+}
+
+func TestInstrumentationIsIdempotent(t *testing.T) {
+	options := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name: "default options",
+		},
+		{
+			name: "transactions passed as parameters",
+			options: Options{
+				CapturePanics:       true,
+				DetailedErrors:      true,
+				RecordPathValues:    true,
+				RewriteHttpMethods:  true,
+				TraceMainGoroutines: true,
+				SegmentNames:        SegmentNameQualified,
+			},
+		},
+		{
+			name: "transactions passed in contexts",
+			options: Options{
+				Propagation:         PropagateContext,
+				CapturePanics:       true,
+				DetailedErrors:      true,
+				RecordPathValues:    true,
+				RewriteHttpMethods:  true,
+				TraceMainGoroutines: true,
+				SegmentNames:        SegmentNameMethod,
+			},
+		},
+	}
+	for _, app := range idempotencyTestApps {
+		code, err := os.ReadFile(filepath.Join("..", "end-to-end-tests", app))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opt := range options {
+			t.Run(fmt.Sprintf("%s with %s", app, opt.name), func(t *testing.T) {
+				testInstrumentationIsIdempotent(t, string(code), opt.options)
+			})
+		}
+	}
+}
+
+// FuzzInstrumentationIsIdempotent checks that instrumenting any application twice changes it only once. The corpus is
+// seeded with the end to end test applications; inputs that are not a valid main package are skipped.
+func FuzzInstrumentationIsIdempotent(f *testing.F) {
+	for i, app := range idempotencyTestApps {
+		code, err := os.ReadFile(filepath.Join("..", "end-to-end-tests", app))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(code), i%2 == 1)
+	}
+
+	f.Fuzz(func(t *testing.T, code string, propagateContext bool) {
+		file, err := parser.ParseFile(token.NewFileSet(), "app.go", code, parser.SkipObjectResolution)
+		if err != nil || file.Name.Name != "main" {
+			t.Skip("not a main package")
+		}
+		if _, err := decorator.Parse(code); err != nil {
+			t.Skip("not supported by the decorator")
+		}
+
+		options := Options{DetailedErrors: true, CapturePanics: true}
+		if propagateContext {
+			options.Propagation = PropagateContext
+		}
+		testInstrumentationIsIdempotent(t, code, options)
+	})
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// coreIntegrationName is the name of the integration that starts transactions in main.
+	coreIntegrationName = "core"
+	// httpClientIntegration is the name of the integration that traces the requests of http clients.
+	httpClientIntegration = "http-client"
+	// errorsIntegration is the name of the integration that notices errors on transactions.
+	errorsIntegration = "errors"
+	// httpServerIntegration is the name of the integration that starts transactions for http handlers.
+	httpServerIntegration = "http-server"
+	// ginIntegration is the name of the integration that instruments gin routers.
+	ginIntegration = "gin"
+)
+
+// integration is a named set of tracing functions that instrument a library, or a part of the standard library.
+type integration struct {
+	name               string
+	library            string // the import path of the library that is instrumented
+	description        string // what the integration instruments
+	preinstrumentation []PreInstrumentationTracingFunction
+	stateless          []StatelessTracingFunction
+	stateful           []StatefulTracingFunction
+	dependency         []FactDiscoveryFunction
+}
+
+// coreIntegration contains the tracing functions that start transactions in main and find existing transactions.
+// It is always loaded, since the other integrations instrument the functions that transactions are passed to.
+var coreIntegration = integration{
+	name:               coreIntegrationName,
+	library:            "github.com/newrelic/go-agent/v3/newrelic",
+	description:        "starts transactions in main and passes them to the functions it calls",
+	preinstrumentation: []PreInstrumentationTracingFunction{DetectTransactions},
+	stateless:          []StatelessTracingFunction{InstrumentMain},
+}
+
+// integrations are the integrations that can be enabled and disabled by name, in the order they are loaded.
+var integrations = []integration{
+	{
+		name:               httpClientIntegration,
+		library:            "net/http",
+		description:        "creates external segments for requests sent by http clients",
+		preinstrumentation: []PreInstrumentationTracingFunction{DetectDefaultHttpClient},
+		stateless:          []StatelessTracingFunction{InstrumentHttpClient, InstrumentPackageHttpClient, CannotInstrumentHttpMethod},
+		stateful:           []StatefulTracingFunction{HttpRequestWithTransactionContext, ExternalHttpCall},
+	},
+	{
+		name:        "reverse-proxy",
+		library:     "net/http/httputil",
+		description: "creates external segments for requests forwarded by reverse proxies",
+		stateless:   []StatelessTracingFunction{InstrumentReverseProxy},
+		stateful:    []StatefulTracingFunction{ReverseProxyServeHTTP},
+	},
+	{
+		name:               errorsIntegration,
+		library:            "errors",
+		description:        "notices errors returned to traced functions, and panics recovered in them",
+		preinstrumentation: []PreInstrumentationTracingFunction{DetectErrors},
+		stateful:           []StatefulTracingFunction{NoticeAsyncWaitError},
+	},
+	{
+		name:               httpServerIntegration,
+		library:            "net/http",
+		description:        "starts a transaction for each request handled by an http server",
+		preinstrumentation: []PreInstrumentationTracingFunction{DetectWrappedRoutes},
+		stateless:          []StatelessTracingFunction{InstrumentHandleFunction},
+		stateful:           []StatefulTracingFunction{WrapNestedHandleFunction},
+	},
+	{
+		name:        "grpc",
+		library:     "google.golang.org/grpc",
+		description: "adds interceptors to grpc clients and servers",
+		stateless:   []StatelessTracingFunction{InstrumentGrpcDial, InstrumentGrpcServerMethod},
+		stateful:    []StatefulTracingFunction{InstrumentGrpcServer},
+		dependency:  []FactDiscoveryFunction{FindGrpcServerObject},
+	},
+	{
+		name:        ginIntegration,
+		library:     "github.com/gin-gonic/gin",
+		description: "adds middleware to gin routers, and passes transactions to their handlers",
+		stateless:   []StatelessTracingFunction{InstrumentGinFunction},
+		stateful:    []StatefulTracingFunction{InstrumentGinMiddleware},
+	},
+	{
+		name:        "chi",
+		library:     "github.com/go-chi/chi/v5",
+		description: "adds middleware to chi routers",
+		stateful:    []StatefulTracingFunction{InstrumentChiMiddleware, InstrumentChiRouterLiteral},
+	},
+	{
+		name:        "slog",
+		library:     "log/slog",
+		description: "wraps slog handlers to add linking metadata to logs",
+		stateless:   []StatelessTracingFunction{InstrumentSlogHandler},
+	},
+}
+
+// IntegrationInfo describes an integration that can be enabled or disabled by name.
+type IntegrationInfo struct {
+	Name        string
+	Library     string
+	Description string
+}
+
+// Integrations returns the integrations that can be enabled or disabled by name, including the integrations
+// registered by plugins, in the order they are loaded.
+func Integrations() []IntegrationInfo {
+	all := allIntegrations()
+	infos := make([]IntegrationInfo, 0, len(all))
+	for _, i := range all {
+		infos = append(infos, IntegrationInfo{Name: i.name, Library: i.library, Description: i.description})
+	}
+	return infos
+}
+
+// validateIntegrations returns an error if any of the integrations named in the options does not exist, or if a
+// plugin registered an integration with the name of another integration.
+func validateIntegrations(options Options, all []integration) error {
+	names := map[string]bool{coreIntegration.name: true}
+	for _, i := range all {
+		if names[i.name] {
+			return fmt.Errorf("integration %q is registered more than once", i.name)
+		}
+		names[i.name] = true
+	}
+
+	for _, name := range slices.Concat(options.Integrations, options.DisabledIntegrations) {
+		if !slices.ContainsFunc(all, func(i integration) bool { return i.name == name }) {
+			return fmt.Errorf("unknown integration %q: run list-integrations to see the integrations that can be selected", name)
+		}
+	}
+	return nil
+}
+
+// integrationEnabled returns true if the integration with the given name is loaded according to the options.
+func (m *InstrumentationManager) integrationEnabled(name string) bool {
+	if len(m.options.Integrations) > 0 && !slices.Contains(m.options.Integrations, name) {
+		return false
+	}
+	return !slices.Contains(m.options.DisabledIntegrations, name)
+}
+
+// loadIntegration loads the tracing functions of an integration.
+func (m *InstrumentationManager) loadIntegration(i integration) {
+	m.loadPreInstrumentationTracingFunctions(i.preinstrumentation...)
+	m.loadStatelessTracingFunctions(i.stateless...)
+	m.loadStatefulTracingFunctions(i.stateful...)
+	m.loadDependencyScans(i.dependency...)
+}
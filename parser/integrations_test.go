@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDependencyIntegrations(t *testing.T) {
+	tests := []struct {
+		name           string
+		options        Options
+		wantStateless  int
+		wantStateful   int
+		wantDependency int
+		wantErr        bool
+	}{
+		{
+			name:           "all integrations are loaded by default",
+			wantStateless:  10,
+			wantStateful:   9,
+			wantDependency: 1,
+		},
+		{
+			name:           "only enabled integrations are loaded along with the core integration",
+			options:        Options{Integrations: []string{"grpc", "http-client"}},
+			wantStateless:  6,
+			wantStateful:   3,
+			wantDependency: 1,
+		},
+		{
+			name:           "disabled integrations are not loaded",
+			options:        Options{DisabledIntegrations: []string{"grpc", "slog"}, RewriteHttpMethods: true},
+			wantStateless:  7,
+			wantStateful:   9,
+			wantDependency: 0,
+		},
+		{
+			name:    "unknown integrations are an error",
+			options: Options{DisabledIntegrations: []string{"logrus"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewInstrumentationManager(nil, Config{Options: tt.options}, "", "")
+			err := m.DetectDependencyIntegrations()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, m.tracingFunctions.stateless, tt.wantStateless)
+			assert.Len(t, m.tracingFunctions.stateful, tt.wantStateful)
+			assert.Len(t, m.tracingFunctions.dependency, tt.wantDependency)
+		})
+	}
+}
+
+func TestIntegrations(t *testing.T) {
+	infos := Integrations()
+	assert.Len(t, infos, len(integrations))
+	for _, info := range infos {
+		assert.NotEmpty(t, info.Name)
+		assert.NotEmpty(t, info.Library, info.Name)
+		assert.NotEmpty(t, info.Description, info.Name)
+	}
+}
+
+func TestDisabledErrorsIntegration(t *testing.T) {
+	code := `package main
+
+import "os"
+
+func load() ([]byte, error) {
+	data, err := os.ReadFile("data.json")
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func main() {
+	load()
+}
+`
+	expect := `package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func load(nrTxn *newrelic.Transaction) ([]byte, error) {
+	defer nrTxn.StartSegment("load").End()
+
+	data, err := os.ReadFile("data.json")
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("load")
+	load(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	defer panicRecovery(t)
+	got := testStatelessTracingFunctionWithOptions(t, code, Options{DisabledIntegrations: []string{errorsIntegration}}, InstrumentMain)
+	assert.Equal(t, expect, got)
+}
@@ -3,17 +3,22 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"go/types"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/decorator/resolver/gopackages"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/errorcache"
 	"github.com/newrelic/go-easy-instrumentation/parser/facts"
@@ -38,10 +43,133 @@ type tracingFunctions struct {
 	preinstrumentation []PreInstrumentationTracingFunction
 }
 
+// PropagationStrategy controls how a transaction is passed to functions that do not accept a context or transaction.
+type PropagationStrategy string
+
+const (
+	// PropagateTransaction adds a *newrelic.Transaction as the last parameter of functions.
+	PropagateTransaction PropagationStrategy = "transaction"
+
+	// PropagateContext adds a context.Context as the first parameter of functions, and passes the transaction in it.
+	PropagateContext PropagationStrategy = "context"
+)
+
+// ParsePropagationStrategy returns the propagation strategy with the given name.
+func ParsePropagationStrategy(name string) (PropagationStrategy, error) {
+	switch strategy := PropagationStrategy(name); strategy {
+	case PropagateTransaction, PropagateContext:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid propagation strategy %q: must be %q or %q", name, PropagateTransaction, PropagateContext)
+}
+
+// SegmentNaming controls how the segments created for traced functions are named.
+type SegmentNaming string
+
+const (
+	// SegmentNameFunction names segments after the function, such as "Load".
+	SegmentNameFunction SegmentNaming = "function"
+
+	// SegmentNameMethod names segments after the function, and the receiver type of methods, such as "Store.Load".
+	SegmentNameMethod SegmentNaming = "method"
+
+	// SegmentNameQualified names segments after the function and the package it is declared in, such as "store.Store.Load".
+	SegmentNameQualified SegmentNaming = "qualified"
+)
+
+// ParseSegmentNaming returns the segment naming style with the given name.
+func ParseSegmentNaming(name string) (SegmentNaming, error) {
+	switch naming := SegmentNaming(name); naming {
+	case SegmentNameFunction, SegmentNameMethod, SegmentNameQualified:
+		return naming, nil
+	}
+	return "", fmt.Errorf("invalid segment naming %q: must be %q, %q or %q", name, SegmentNameFunction, SegmentNameMethod, SegmentNameQualified)
+}
+
+// Options controls optional instrumentation behavior that is disabled by default.
+type Options struct {
+	// RecordPathValues records the values of net/http ServeMux pattern wildcards read by r.PathValue() as transaction attributes.
+	RecordPathValues bool
+
+	// RewriteHttpMethods rewrites calls to http.Get, http.Head, http.Post and http.PostForm in traced functions
+	// into requests sent by an instrumented http client.
+	RewriteHttpMethods bool
+
+	// TraceMainGoroutines starts a background transaction for each goroutine launched from main that calls
+	// a function defined in the application.
+	TraceMainGoroutines bool
+
+	// CallGraph builds a call graph of the application with SSA and variable type analysis, and uses it to decide
+	// which functions are traced, including functions called through function values such as callbacks.
+	CallGraph bool
+
+	// PreserveExportedAPIs keeps the signatures of exported functions in packages that other modules can import unchanged.
+	// A variant of these functions that accepts a transaction is created instead.
+	PreserveExportedAPIs bool
+
+	// Propagation is how a transaction is passed to functions that do not accept a context or transaction.
+	// Transactions are passed as a parameter when this is not set.
+	Propagation PropagationStrategy
+
+	// CapturePanics configures the agent to record panics when a transaction is ended, and recovers panics in the functions
+	// that transactions started in main are passed to and in functions run in a new goroutine, so that they are noticed on
+	// the transaction before the program exits.
+	CapturePanics bool
+
+	// DetailedErrors notices errors as a newrelic.Error with a class derived from the type of the error, and attributes
+	// for the function that noticed it and the function call that returned it, rather than noticing the error as it is.
+	DetailedErrors bool
+
+	// ErrorRules are sentinel errors and error types that are not noticed, or noticed as expected errors. Errors are checked
+	// against the rules that can be resolved in the package they are noticed in.
+	ErrorRules []ErrorRule
+
+	// Integrations are the names of the integrations that are loaded. All integrations are loaded when this is empty.
+	Integrations []string
+
+	// DisabledIntegrations are the names of the integrations that are not loaded.
+	DisabledIntegrations []string
+
+	// Rules are declarative integrations read from rule files. Each rule is loaded as an integration named after it.
+	Rules []rules.Rule
+
+	// TraceFunctions are patterns of the functions that are traced when they are called with a transaction. Other functions
+	// are not traced when this is not empty. Patterns are matched with path.Match against the name of a function, such as
+	// "Store.Load", and against its name qualified by the path of its package, such as "example.com/app/store.Store.Load".
+	TraceFunctions []string
+
+	// SkipFunctions are patterns of the functions that are not traced when they are called with a transaction. They are
+	// matched like TraceFunctions, and take precedence over them.
+	SkipFunctions []string
+
+	// SegmentNames is how the segments created for traced functions are named. Segments are named after the function
+	// when this is not set.
+	SegmentNames SegmentNaming
+}
+
+// Config is the configuration of the instrumentation of an application, loaded from its configuration file and the
+// command line flags.
+type Config struct {
+	// AppName is the name the application reports to New Relic as. It is read from the environment when it is empty.
+	AppName string
+
+	// AgentVariableName is the name of the variable that the agent application is assigned to in main.
+	AgentVariableName string
+
+	// ExcludePackages are patterns of the packages that are not instrumented. Patterns are matched with path.Match
+	// against the import path of a package, or against its directory relative to the application if they start with "./".
+	// Patterns that end in "/..." match a package and all the packages below it.
+	ExcludePackages []string
+
+	// Options controls optional instrumentation behavior.
+	Options Options
+}
+
 // InstrumentationManager maintains state relevant to tracing across all files, packages and functions.
 type InstrumentationManager struct {
 	appName           string
 	agentVariableName string
+	options           Options
 	userAppPath       string // path to the user's application as provided by the user
 	diffFile          string
 	currentPackage    string
@@ -51,6 +179,30 @@ type InstrumentationManager struct {
 	errorCache        errorcache.ErrorCache             // stores error handling status for functions
 	transactionCache  transactioncache.TransactionCache // stores transaction status for functions
 	setupFunc         *dst.FuncDecl
+
+	httpClientInits map[*dst.File]*dst.FuncDecl // init functions that wrap the transports of the package level http clients of each file
+
+	defaultHttpClientUsed         bool // http.DefaultClient is used by the application
+	defaultHttpClientInstrumented bool // the transport of http.DefaultClient is already wrapped by a New Relic round tripper
+	mainDeclared                  bool // the application declares the main function that http.DefaultClient is instrumented in
+
+	requestsWithTransaction map[types.Object]bool // http requests that were created with a transaction in their context
+
+	handlerRegistrationCounts map[handlerRegistration]int // the number of times each handler is registered, counted once per run
+
+	interfaceImplementations map[*types.Func]*interfaceImplementations // implementations of interface methods that have been called
+	interfaceCallsWarned     map[*dst.CallExpr]bool                    // interface method calls that a warning has been added to
+
+	callGraph *callGraph // call graph of the application, only built when Options.CallGraph is set
+
+	carrierFunctions    map[*types.Func]int             // functions that read the transaction from a struct parameter, and the index of that parameter
+	transactionVariants map[*dst.FuncDecl]*dst.FuncDecl // variants that accept a transaction of functions whose signature can not be changed
+	variantOriginals    map[*dst.FuncDecl]*dst.FuncDecl // the functions that each transaction variant was created for
+	knownInterfaces     []*types.TypeName               // interfaces that types declared in this application may implement
+
+	resolvedErrorRules map[string][]codegen.ErrorRule // error rules resolved in each package, by package path
+
+	existing report.Summary // the instrumentation that the application already had, which is not counted by Summary
 }
 
 // PackageManager contains state relevant to tracing within a single package.
@@ -58,19 +210,29 @@ type packageState struct {
 	pkg          *decorator.Package             // the package being instrumented
 	tracedFuncs  map[string]*tracedFunctionDecl // maintains state of tracing for functions within the package
 	importsAdded map[string]bool                // tracks imports added to the package
+	generated    *generatedCode                 // recognizes the code generated in the package by a previous run
+	httpClient   string                         // the instrumented http client declared in the package, if any
 }
 
 // NewInstrumentationManager initializes an InstrumentationManager cache for a given package.
-func NewInstrumentationManager(pkgs []*decorator.Package, appName, agentVariableName, diffFile, userAppPath string) *InstrumentationManager {
+func NewInstrumentationManager(pkgs []*decorator.Package, config Config, diffFile, userAppPath string) *InstrumentationManager {
 	manager := &InstrumentationManager{
-		userAppPath:       userAppPath,
-		diffFile:          diffFile,
-		appName:           appName,
-		agentVariableName: agentVariableName,
-		packages:          map[string]*packageState{},
-		facts:             facts.NewKeeper(),
-		errorCache:        errorcache.ErrorCache{},
-		transactionCache:  *transactioncache.NewTransactionCache(),
+		userAppPath:             userAppPath,
+		diffFile:                diffFile,
+		appName:                 config.AppName,
+		agentVariableName:       config.AgentVariableName,
+		options:                 config.Options,
+		packages:                map[string]*packageState{},
+		facts:                   facts.NewKeeper(),
+		errorCache:              errorcache.ErrorCache{},
+		transactionCache:        *transactioncache.NewTransactionCache(),
+		requestsWithTransaction: map[types.Object]bool{},
+
+		interfaceImplementations: map[*types.Func]*interfaceImplementations{},
+		interfaceCallsWarned:     map[*dst.CallExpr]bool{},
+		transactionVariants:      map[*dst.FuncDecl]*dst.FuncDecl{},
+		variantOriginals:         map[*dst.FuncDecl]*dst.FuncDecl{},
+		resolvedErrorRules:       map[string][]codegen.ErrorRule{},
 		tracingFunctions: tracingFunctions{
 			stateless:          []StatelessTracingFunction{},
 			stateful:           []StatefulTracingFunction{},
@@ -80,22 +242,52 @@ func NewInstrumentationManager(pkgs []*decorator.Package, appName, agentVariable
 	}
 
 	for _, pkg := range pkgs {
+		if isExcludedPackage(pkg, config.ExcludePackages, userAppPath) {
+			continue
+		}
 		manager.packages[pkg.ID] = &packageState{
 			pkg:          pkg,
 			tracedFuncs:  map[string]*tracedFunctionDecl{},
 			importsAdded: map[string]bool{},
+			generated:    newGeneratedCode(pkg),
 		}
 	}
+	manager.existing = manager.countInstrumentation()
 
 	return manager
 }
 
-// DetectDependencyIntegrations
+// SetOptions sets the optional instrumentation behavior of the manager. It must be called before DetectDependencyIntegrations.
+func (m *InstrumentationManager) SetOptions(options Options) {
+	m.options = options
+}
+
+// DetectDependencyIntegrations loads the tracing functions of the integrations that are enabled by the options.
 func (m *InstrumentationManager) DetectDependencyIntegrations() error {
-	m.loadPreInstrumentationTracingFunctions(DetectTransactions, DetectErrors, DetectWrappedRoutes)
-	m.loadStatelessTracingFunctions(InstrumentMain, InstrumentHandleFunction, InstrumentHttpClient, CannotInstrumentHttpMethod, InstrumentGrpcDial, InstrumentGinFunction, InstrumentGrpcServerMethod, InstrumentSlogHandler)
-	m.loadStatefulTracingFunctions(ExternalHttpCall, WrapNestedHandleFunction, InstrumentGrpcServer, InstrumentGinMiddleware, InstrumentChiMiddleware, InstrumentChiRouterLiteral)
-	m.loadDependencyScans(FindGrpcServerObject)
+	all := allIntegrations()
+	for _, rule := range m.options.Rules {
+		i, err := ruleIntegration(rule)
+		if err != nil {
+			return err
+		}
+		all = append(all, i)
+	}
+	if err := validateIntegrations(m.options, all); err != nil {
+		return err
+	}
+
+	m.loadIntegration(coreIntegration)
+	for _, i := range all {
+		if m.integrationEnabled(i.name) {
+			m.loadIntegration(i)
+		}
+	}
+	if m.options.RecordPathValues && m.integrationEnabled("http-server") {
+		m.loadStatefulTracingFunctions(RecordPathValueAttributes)
+	}
+	if m.options.RewriteHttpMethods && m.integrationEnabled("http-client") {
+		m.loadStatefulTracingFunctions(RewriteHttpMethodCall)
+	}
 	return nil
 }
 
@@ -164,6 +356,16 @@ func (m *InstrumentationManager) getDecoratorPackage() *decorator.Package {
 	return state.pkg
 }
 
+// generatedCode returns the recognizer of the code that was generated in the current package by a previous run.
+func (m *InstrumentationManager) generatedCode() *generatedCode {
+	state, ok := m.packages[m.currentPackage]
+	if !ok {
+		return &generatedCode{agents: map[string]bool{}}
+	}
+
+	return state.generated
+}
+
 // Returns the string name of the current package
 func (m *InstrumentationManager) getPackageName() string {
 	return m.currentPackage
@@ -177,9 +379,10 @@ func (m *InstrumentationManager) createFunctionDeclaration(decl *dst.FuncDecl) {
 		return
 	}
 
-	_, ok = state.tracedFuncs[decl.Name.Name]
+	key := functionKey(decl)
+	_, ok = state.tracedFuncs[key]
 	if !ok {
-		state.tracedFuncs[decl.Name.Name] = &tracedFunctionDecl{
+		state.tracedFuncs[key] = &tracedFunctionDecl{
 			body: decl,
 		}
 	}
@@ -189,7 +392,7 @@ func (m *InstrumentationManager) createFunctionDeclaration(decl *dst.FuncDecl) {
 func (m *InstrumentationManager) updateFunctionDeclaration(decl *dst.FuncDecl) {
 	state, ok := m.packages[m.currentPackage]
 	if ok {
-		t, ok := state.tracedFuncs[decl.Name.Name]
+		t, ok := state.tracedFuncs[functionKey(decl)]
 		if ok {
 			t.body = decl
 			t.traced = true
@@ -202,6 +405,13 @@ type invocationInfo struct {
 	packageName  string
 	call         *dst.CallExpr
 	decl         *dst.FuncDecl
+
+	// implementations are the invocations of each implementation of an interface method declared in this application.
+	// They are only set for calls to interface methods, which do not have a declaration.
+	implementations []*invocationInfo
+	// interfaceMethod is the declaration of an interface method that needs a transaction parameter added to it,
+	// along with its implementations, in order to pass a transaction through the call.
+	interfaceMethod *interfaceMethodField
 }
 
 func resolvePath(identPath, currentPackage, forTest string) string {
@@ -298,8 +508,25 @@ func (m *InstrumentationManager) findInvocationInfo(node dst.Node, state *traces
 						call:         call,
 						decl:         pkg.tracedFuncs[fun.Name].body,
 					})
+				} else if _, isVar := util.ObjectOf(fun, m.getDecoratorPackage()).(*types.Var); isVar {
+					// Function values are resolved by the call graph
+					if inv := m.getDynamicInvocationInfo(call); inv != nil {
+						invInfo = append(invInfo, inv)
+					}
 				}
 			case *dst.SelectorExpr:
+				// Methods are resolved by the type of their receiver, and function values stored in fields by the call graph
+				if selection := util.Selection(fun, m.getDecoratorPackage()); selection != nil {
+					inv := m.getMethodInvocationInfo(selection, call, node)
+					if selection.Kind() == types.FieldVal {
+						inv = m.getDynamicInvocationInfo(call)
+					}
+					if inv != nil {
+						invInfo = append(invInfo, inv)
+					}
+					return true
+				}
+
 				// Handle selector expressions like `f().g().x()`
 				pkgName := util.PackagePath(fun.Sel, m.getDecoratorPackage())
 				path := resolvePath(pkgName, m.getPackageName(), "")
@@ -322,7 +549,65 @@ func (m *InstrumentationManager) findInvocationInfo(node dst.Node, state *traces
 		return true
 	})
 
-	return invInfo
+	return slices.DeleteFunc(invInfo, func(inv *invocationInfo) bool {
+		return !m.isTracingAllowed(inv)
+	})
+}
+
+// isTracingAllowed returns false if the function invoked is skipped, or is not one of the functions that tracing is limited to.
+// Calls to interface methods are always allowed, since every implementation must accept the transaction passed to the method.
+func (m *InstrumentationManager) isTracingAllowed(inv *invocationInfo) bool {
+	if inv.decl == nil || (len(m.options.TraceFunctions) == 0 && len(m.options.SkipFunctions) == 0) {
+		return true
+	}
+
+	key := functionKey(inv.decl)
+	names := []string{key}
+	if state, ok := m.packages[inv.packageName]; ok {
+		names = append(names, state.pkg.PkgPath+"."+key)
+	}
+	if matchesAnyPattern(m.options.SkipFunctions, names...) {
+		return false
+	}
+	return len(m.options.TraceFunctions) == 0 || matchesAnyPattern(m.options.TraceFunctions, names...)
+}
+
+// matchesAnyPattern returns true if any of the names matches any of the patterns with path.Match.
+func matchesAnyPattern(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isExcludedPackage returns true if a package matches any of the patterns of excluded packages. Patterns that start with
+// "./" are matched against the directory of the package relative to the application.
+func isExcludedPackage(pkg *decorator.Package, patterns []string, userAppPath string) bool {
+	relativeDir := ""
+	if rel, err := filepath.Rel(userAppPath, pkg.Dir); pkg.Dir != "" && err == nil {
+		relativeDir = path.Join(".", filepath.ToSlash(rel))
+		if relativeDir != "." {
+			relativeDir = "./" + relativeDir
+		}
+	}
+
+	for _, pattern := range patterns {
+		name := pkg.PkgPath
+		if strings.HasPrefix(pattern, "./") {
+			name = relativeDir
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/..."); ok && (name == prefix || strings.HasPrefix(name, prefix+"/")) {
+			return true
+		}
+		if matchesAnyPattern([]string{pattern}, name) {
+			return true
+		}
+	}
+	return false
 }
 
 // IsTracingComplete returns true if a function has all the tracing it needs added to it.
@@ -333,9 +618,13 @@ func (m *InstrumentationManager) shouldInstrumentFunction(inv *invocationInfo) b
 
 	state, ok := m.packages[inv.packageName]
 	if ok {
-		v, ok := state.tracedFuncs[inv.functionName]
+		key := inv.functionName
+		if inv.decl != nil {
+			key = functionKey(inv.decl)
+		}
+		v, ok := state.tracedFuncs[key]
 		if ok {
-			return !v.traced
+			return !v.traced && (m.callGraph == nil || m.callGraph.isReachable(functionID{packageName: inv.packageName, key: key}))
 		}
 	}
 
@@ -358,6 +647,59 @@ func (m *InstrumentationManager) getSortedPackages() []string {
 // This allows the caller (e.g., the CLI UI) to receive granular progress updates
 // containing the name of the file currently being processed.
 func (m *InstrumentationManager) WriteDiff(onProgress func(string)) error {
+	return m.restoreFiles(func(path, name string, original, modified []byte) error {
+		if onProgress != nil {
+			onProgress(fmt.Sprintf("Writing diff for %s", name))
+		}
+
+		f, err := os.OpenFile(m.diffFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		patch := godiffpatch.GeneratePatch(name, string(original), string(modified))
+		_, err = f.WriteString(patch)
+		return err
+	})
+}
+
+// WriteFiles writes the changes made to the files of the application in place. Files that were not changed are not
+// written. beforeWrite is called with the path of each file before it is overwritten, so that the caller can back it up,
+// and onProgress is invoked before writing each file, like it is for WriteDiff.
+func (m *InstrumentationManager) WriteFiles(onProgress func(string), beforeWrite func(path string) error) error {
+	// the files of a package are also part of its test variant, and are only written the first time they are restored
+	written := map[string]bool{}
+	return m.restoreFiles(func(path, name string, original, modified []byte) error {
+		if written[path] || bytes.Equal(original, modified) {
+			return nil
+		}
+		if onProgress != nil {
+			onProgress(fmt.Sprintf("Writing %s", name))
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if beforeWrite != nil {
+			if err := beforeWrite(path); err != nil {
+				return err
+			}
+		}
+		written[path] = true
+		return os.WriteFile(path, modified, info.Mode().Perm())
+	})
+}
+
+// restoreFiles restores the syntax trees of the files of the application, other than generated files, into source code.
+// write is called for each file with its path, its path relative to the application, and its original and modified contents.
+func (m *InstrumentationManager) restoreFiles(write func(path, name string, original, modified []byte) error) error {
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return err
+	}
+
 	pkgs := m.getSortedPackages()
 	for _, pkg := range pkgs {
 		state := m.packages[pkg]
@@ -373,37 +715,18 @@ func (m *InstrumentationManager) WriteDiff(onProgress func(string)) error {
 				return err
 			}
 
-			// what this file will be named in the diff file
-			var diffFileName string
-
-			absAppPath, err := filepath.Abs(m.userAppPath)
+			// what this file is named in the diff file and progress updates
+			name, err := filepath.Rel(absAppPath, path)
 			if err != nil {
 				return err
 			}
-			diffFileName, err = filepath.Rel(absAppPath, path)
-			if err != nil {
-				return err
-			}
-
-			if onProgress != nil {
-				onProgress(fmt.Sprintf("Writing diff for %s", diffFileName))
-			}
 
 			modifiedFile := bytes.NewBuffer([]byte{})
 			if err := r.Fprint(modifiedFile, file); err != nil {
 				return err
 			}
 
-			f, err := os.OpenFile(m.diffFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				f.Close()
-				return err
-			}
-
-			defer f.Close()
-
-			patch := godiffpatch.GeneratePatch(diffFileName, string(originalFile), modifiedFile.String())
-			if _, err := f.WriteString(patch); err != nil {
+			if err := write(path, name, originalFile, modifiedFile.Bytes()); err != nil {
 				return err
 			}
 		}
@@ -453,8 +776,12 @@ func (m *InstrumentationManager) AddRequiredModules() error {
 }
 
 func (m *InstrumentationManager) TracePackageCalls() error {
-	return tracePackageFunctionCalls(m, m.tracingFunctions.dependency...)
-
+	err := tracePackageFunctionCalls(m, m.tracingFunctions.dependency...)
+	m.carrierFunctions = findCarrierFunctions(m)
+	if m.options.CallGraph {
+		m.callGraph = newCallGraph(m)
+	}
+	return err
 }
 
 // ScanApplication scans the existing Go application without adding instrumentation to the source code.
@@ -554,6 +881,20 @@ func instrumentPackages(manager *InstrumentationManager, instrumentationFunction
 					})
 				}
 			}
+
+			// package level declarations are visited with the file as their parent so that
+			// declarations can be added to the file
+			dstutil.Apply(file, func(c *dstutil.Cursor) bool {
+				switch c.Node().(type) {
+				case *dst.File:
+					return true
+				case *dst.GenDecl:
+					for _, instFunc := range instrumentationFunctions {
+						instFunc(manager, c)
+					}
+				}
+				return false
+			}, nil)
 		}
 	}
 	return nil
@@ -571,8 +912,9 @@ func scanPackages(manager *InstrumentationManager, instrumentationFunctions ...P
 		manager.setPackage(pkgName)
 		for _, file := range pkgState.pkg.Syntax {
 			for _, decl := range file.Decls {
-				if fn, isFn := decl.(*dst.FuncDecl); isFn {
-					dstutil.Apply(fn, nil, func(c *dstutil.Cursor) bool {
+				switch decl.(type) {
+				case *dst.FuncDecl, *dst.GenDecl:
+					dstutil.Apply(decl, nil, func(c *dstutil.Cursor) bool {
 						for _, instFunc := range instrumentationFunctions {
 							instFunc(manager, c)
 						}
@@ -606,7 +948,7 @@ func (m *InstrumentationManager) ResolveUnitTests() error {
 
 				// pointers to decls from the package being tested are coppied in test packages
 				// and will modify the original decl if incorrectly modified by this function
-				if m.isDefinedInPackage(fn.Name.Name, pkg.ForTest) {
+				if m.isDefinedInPackage(functionKey(fn), pkg.ForTest) {
 					continue
 				}
 
@@ -617,22 +959,28 @@ func (m *InstrumentationManager) ResolveUnitTests() error {
 					case *dst.CallExpr:
 						inv := m.getInvocationInfoFromCall(call, pkg.ForTest)
 						if inv != nil && inv.decl != nil && inv.decl.Type.Params.List != nil && len(inv.decl.Type.Params.List) > 0 {
-							// if the function declaration has a transaction as its last parameter, the test needs to be updated to do the same
-							star, ok := inv.decl.Type.Params.List[len(inv.decl.Type.Params.List)-1].Type.(*dst.StarExpr)
-							if !ok {
-								return true
-							}
-
 							// guard agains duplicate parameters being added
 							numParams := inv.decl.Type.Params.NumFields()
 							if len(call.Args) == numParams {
 								return true
 							}
 
-							txnIdent, ok := star.X.(*dst.Ident)
-							if ok && txnIdent.Name == "Transaction" && txnIdent.Path == codegen.NewRelicAgentImportPath {
-								// we have a match, now we need to update the call to include a transaction
-								inv.call.Args = append(inv.call.Args, &dst.Ident{Name: "nil"})
+							// if the function declaration has a transaction as its last parameter, the test needs to be updated to do the same
+							params := inv.decl.Type.Params.List
+							star, ok := params[len(params)-1].Type.(*dst.StarExpr)
+							if ok {
+								txnIdent, ok := star.X.(*dst.Ident)
+								if ok && txnIdent.Name == "Transaction" && txnIdent.Path == codegen.NewRelicAgentImportPath {
+									// we have a match, now we need to update the call to include a transaction
+									inv.call.Args = append(inv.call.Args, &dst.Ident{Name: "nil"})
+								}
+								return true
+							}
+
+							// if a context was added as the first parameter of the function declaration, the test needs to pass one
+							ctxIdent, ok := params[0].Type.(*dst.Ident)
+							if ok && ctxIdent.Name == "Context" && ctxIdent.Path == "context" {
+								inv.call.Args = append([]dst.Expr{codegen.BackgroundContext()}, inv.call.Args...)
 							}
 						}
 					}
@@ -1,13 +1,19 @@
 package parser
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
 )
 
 func Test_AddImport(t *testing.T) {
@@ -570,3 +576,214 @@ func Test_GetInvocationInfoFromCall(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceFunctionPatterns(t *testing.T) {
+	code := `package main
+
+type Store struct{}
+
+func (s *Store) Load() {}
+
+func (s *Store) Validate() {}
+
+func process() {}
+
+func main() {
+	s := &Store{}
+	s.Load()
+	s.Validate()
+	process()
+}
+`
+	tests := []struct {
+		name    string
+		options Options
+		expect  string
+	}{
+		{
+			name:    "skipped functions are not traced",
+			options: Options{SkipFunctions: []string{"*.Validate", "process"}},
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct{}
+
+func (s *Store) Load(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("Load").End()
+
+}
+
+func (s *Store) Validate() {}
+
+func process() {}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{}
+	nrTxn := NewRelicAgent.StartTransaction("Load")
+	s.Load(nrTxn)
+	nrTxn.End()
+	s.Validate()
+	process()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:    "only allowed functions are traced, unless they are skipped",
+			options: Options{TraceFunctions: []string{"Store.*"}, SkipFunctions: []string{"github.com/newrelic/go-easy-instrumentation/parser/tmp_*.Store.Load"}},
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct{}
+
+func (s *Store) Load() {}
+
+func (s *Store) Validate(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("Validate").End()
+
+}
+
+func process() {}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{}
+	s.Load()
+	nrTxn := NewRelicAgent.StartTransaction("Validate")
+	s.Validate(nrTxn)
+	nrTxn.End()
+	process()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, code, tt.options, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func Test_isExcludedPackage(t *testing.T) {
+	pkg := &decorator.Package{Package: &packages.Package{PkgPath: "example.com/app/internal/generated/api"}, Dir: "/src/app/internal/generated/api"}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     bool
+	}{
+		{name: "no patterns", want: false},
+		{name: "import path", patterns: []string{"example.com/app/internal/generated/api"}, want: true},
+		{name: "import path and the packages below it", patterns: []string{"example.com/app/internal/..."}, want: true},
+		{name: "import path with a wildcard", patterns: []string{"example.com/app/internal/*/api"}, want: true},
+		{name: "relative directory and the packages below it", patterns: []string{"./internal/generated/..."}, want: true},
+		{name: "relative directory", patterns: []string{"./internal/generated/api"}, want: true},
+		{name: "all packages", patterns: []string{"./..."}, want: true},
+		{name: "other packages", patterns: []string{"./cmd/...", "example.com/app/internal/gen"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isExcludedPackage(pkg, tt.patterns, "/src/app"))
+		})
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        string
+		expectWrite bool
+	}{
+		{
+			name: "writes instrumented files",
+			code: `package main
+
+func main() {
+	println("hello world")
+}
+`,
+			expectWrite: true,
+		},
+		{
+			name: "does not write files that are not instrumented",
+			code: `package main
+
+func main() {
+	println("hello world")
+}
+`,
+			expectWrite: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			if tt.expectWrite {
+				manager.tracingFunctions.stateless = append(manager.tracingFunctions.stateless, InstrumentMain)
+			}
+			if err := manager.TracePackageCalls(); err != nil {
+				t.Fatal(err)
+			}
+			if err := manager.InstrumentApplication(); err != nil {
+				t.Fatal(err)
+			}
+
+			backedUp := []string{}
+			err = manager.WriteFiles(nil, func(path string) error {
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				assert.Equal(t, tt.code, string(contents), "files must be backed up before they are written")
+				backedUp = append(backedUp, filepath.Base(path))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := os.ReadFile(filepath.Join(testDir, "app.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectWrite {
+				assert.Equal(t, []string{"app.go"}, backedUp)
+				assert.True(t, strings.Contains(string(contents), "NewRelicAgent.Shutdown"), "the instrumented file was not written:\n%s", contents)
+			} else {
+				assert.Empty(t, backedUp)
+				assert.Equal(t, tt.code, string(contents))
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	transactionType = "*" + codegen.NewRelicAgentImportPath + ".Transaction"
+)
+
+// interfaceMethodField is the declaration of a method in an interface type declared in this application.
+type interfaceMethodField struct {
+	field       *dst.Field
+	packageName string
+}
+
+// interfaceImplementations caches the implementations of an interface method.
+type interfaceImplementations struct {
+	implementations []*invocationInfo // implementations declared in this application
+	external        []string          // names of types outside of this application that implement the interface
+}
+
+// functionKey returns the key that a function declaration is stored by in the traced functions of its package.
+// Methods are stored by the name of their receiver type and their name, such as "Server.Handle", so that they
+// do not collide with functions, or methods of other types, that have the same name.
+func functionKey(decl *dst.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	return methodKey(receiverTypeName(decl.Recv.List[0].Type), decl.Name.Name)
+}
+
+func methodKey(typeName, methodName string) string {
+	return typeName + "." + methodName
+}
+
+// receiverTypeName returns the name of the type of a method receiver, ignoring pointers and type parameters.
+func receiverTypeName(expr dst.Expr) string {
+	switch v := expr.(type) {
+	case *dst.StarExpr:
+		return receiverTypeName(v.X)
+	case *dst.ParenExpr:
+		return receiverTypeName(v.X)
+	case *dst.IndexExpr:
+		return receiverTypeName(v.X)
+	case *dst.IndexListExpr:
+		return receiverTypeName(v.X)
+	case *dst.Ident:
+		return v.Name
+	}
+	return ""
+}
+
+// namedReceiver returns the named type of the receiver of a method, ignoring pointers.
+func namedReceiver(method *types.Func) *types.Named {
+	sig, ok := method.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return nil
+	}
+
+	t := sig.Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// acceptsTransaction returns true if a function has a parameter that a transaction can be passed through
+// without changing its signature.
+func acceptsTransaction(method *types.Func) bool {
+	sig, ok := method.Type().(*types.Signature)
+	if !ok {
+		return false
+	}
+
+	for i := 0; i < sig.Params().Len(); i++ {
+		switch sig.Params().At(i).Type().String() {
+		case contextType, transactionType:
+			return true
+		}
+	}
+	return false
+}
+
+// getMethodInvocationInfo returns the invocation info for a method call, resolved by the type of its receiver.
+// Calls to interface methods return an invocation for each implementation of the method declared in this application.
+// If the method is not declared in this application, nil is returned.
+func (m *InstrumentationManager) getMethodInvocationInfo(selection *types.Selection, call *dst.CallExpr, node dst.Node) *invocationInfo {
+	method, ok := selection.Obj().(*types.Func)
+	if !ok || selection.Kind() == types.FieldVal {
+		return nil
+	}
+
+	iface, ok := selection.Recv().Underlying().(*types.Interface)
+	if ok && selection.Kind() == types.MethodVal {
+		name := types.TypeString(selection.Recv(), types.RelativeTo(m.getDecoratorPackage().Types)) + "." + method.Name()
+		return m.getInterfaceInvocationInfo(name, method, iface, call, node)
+	}
+
+	inv := m.getDeclaredMethod(method)
+	if inv == nil {
+		return nil
+	}
+	inv.call = call
+	return inv
+}
+
+// getDeclaredMethod returns the invocation info of a method declared in this application without a call.
+func (m *InstrumentationManager) getDeclaredMethod(method *types.Func) *invocationInfo {
+	named := namedReceiver(method)
+	if named == nil || method.Pkg() == nil {
+		return nil
+	}
+
+	path := method.Pkg().Path()
+	key := methodKey(named.Obj().Name(), method.Name())
+	pkg, ok := m.packages[path]
+	if !ok || pkg.tracedFuncs[key] == nil {
+		return nil
+	}
+
+	return &invocationInfo{
+		functionName: method.Name(),
+		packageName:  path,
+		decl:         pkg.tracedFuncs[key].body,
+	}
+}
+
+// getInterfaceInvocationInfo returns the invocation info for a call to an interface method. The transaction is passed
+// through each implementation of the method that is declared in this application.
+//
+// If the method does not accept a context or transaction, a transaction parameter must be added to the interface method
+// and all of its implementations. This can only be done when the interface is declared in this application, is not
+// implemented by any types outside of it, and none of their signatures must be preserved. Otherwise, a warning is added
+// and the call is not traced.
+func (m *InstrumentationManager) getInterfaceInvocationInfo(name string, method *types.Func, iface *types.Interface, call *dst.CallExpr, node dst.Node) *invocationInfo {
+	impls := m.findImplementations(method, iface)
+	if len(impls.implementations) == 0 {
+		return nil
+	}
+
+	var field *interfaceMethodField
+	if !acceptsTransaction(method) {
+		field = m.findInterfaceMethodField(method)
+		if field == nil || len(impls.external) > 0 {
+			m.warnInterfaceCall(node, call, fmt.Sprintf("the transaction can not be passed to implementations of %s because its signature can not be changed", name), impls.external)
+			return nil
+		}
+		if reason := m.interfaceSignatureConstraint(method, impls.implementations); reason != "" {
+			m.warnInterfaceCall(node, call, fmt.Sprintf("the transaction can not be passed to implementations of %s because %s", name, reason), nil)
+			return nil
+		}
+	} else if len(impls.external) > 0 {
+		m.warnInterfaceCall(node, call, fmt.Sprintf("implementations of %s declared outside of this application will not be traced", name), impls.external)
+	}
+
+	inv := &invocationInfo{
+		functionName:    method.Name(),
+		packageName:     method.Pkg().Path(),
+		call:            call,
+		interfaceMethod: field,
+	}
+	for _, impl := range impls.implementations {
+		implInv := *impl
+		implInv.call = call
+		inv.implementations = append(inv.implementations, &implInv)
+	}
+	return inv
+}
+
+// warnInterfaceCall adds a warning about an interface method call to the statement it is made in once.
+func (m *InstrumentationManager) warnInterfaceCall(node dst.Node, call *dst.CallExpr, message string, external []string) {
+	if m.interfaceCallsWarned[call] {
+		return
+	}
+	m.interfaceCallsWarned[call] = true
+
+	additionalInfo := []string{}
+	if len(external) > 0 {
+		additionalInfo = append(additionalInfo, fmt.Sprintf("implemented outside of this application by: %s", strings.Join(external, ", ")))
+	}
+
+	stmt, ok := node.(dst.Stmt)
+	if !ok {
+		comment.Debug(m.getDecoratorPackage(), call, message, additionalInfo...)
+		return
+	}
+	comment.Warn(m.getDecoratorPackage(), interfaceCallRule, stmt, call, message, additionalInfo...)
+}
+
+// implementedMethod returns the method of t, or a pointer to t, that implements the interface method.
+func implementedMethod(t types.Type, iface *types.Interface, method *types.Func) *types.Func {
+	if types.IsInterface(t) {
+		return nil
+	}
+
+	for _, recv := range []types.Type{t, types.NewPointer(t)} {
+		if types.Implements(recv, iface) {
+			obj, _, _ := types.LookupFieldOrMethod(recv, true, method.Pkg(), method.Name())
+			fn, _ := obj.(*types.Func)
+			return fn
+		}
+	}
+	return nil
+}
+
+// findImplementations finds the package level types that implement an interface method. Types declared in
+// this application are returned as invocations of their method declarations, and types declared in any package
+// imported by this application are returned by name. Types declared in test packages are ignored.
+func (m *InstrumentationManager) findImplementations(method *types.Func, iface *types.Interface) *interfaceImplementations {
+	if impls, ok := m.interfaceImplementations[method]; ok {
+		return impls
+	}
+
+	impls := &interfaceImplementations{}
+	declared := map[string]bool{}
+	visited := map[*types.Package]bool{}
+	imported := []*types.Package{}
+
+	for _, path := range m.getSortedPackages() {
+		state := m.packages[path]
+		if util.IsTestPackage(state.pkg) || state.pkg.Types == nil {
+			continue
+		}
+		visited[state.pkg.Types] = true
+		imported = append(imported, state.pkg.Types.Imports()...)
+
+		scope := state.pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() {
+				continue
+			}
+
+			fn := implementedMethod(typeName.Type(), iface, method)
+			if fn == nil {
+				continue
+			}
+
+			inv := m.getDeclaredMethod(fn)
+			if inv != nil && !declared[inv.packageName+"."+functionKey(inv.decl)] {
+				declared[inv.packageName+"."+functionKey(inv.decl)] = true
+				impls.implementations = append(impls.implementations, inv)
+			}
+		}
+	}
+
+	for len(imported) > 0 {
+		pkg := imported[0]
+		imported = imported[1:]
+		if visited[pkg] {
+			continue
+		}
+		visited[pkg] = true
+		imported = append(imported, pkg.Imports()...)
+
+		if _, ok := m.packages[pkg.Path()]; ok {
+			continue
+		}
+
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if ok && typeName.Exported() && !typeName.IsAlias() && implementedMethod(typeName.Type(), iface, method) != nil {
+				impls.external = append(impls.external, pkg.Path()+"."+typeName.Name())
+			}
+		}
+	}
+
+	slices.Sort(impls.external)
+	m.interfaceImplementations[method] = impls
+	return impls
+}
+
+// findInterfaceMethodField returns the declaration of an interface method if it is declared in this application.
+func (m *InstrumentationManager) findInterfaceMethodField(method *types.Func) *interfaceMethodField {
+	if method.Pkg() == nil {
+		return nil
+	}
+
+	state, ok := m.packages[method.Pkg().Path()]
+	if !ok {
+		return nil
+	}
+
+	var field *dst.Field
+	for _, file := range state.pkg.Syntax {
+		dst.Inspect(file, func(n dst.Node) bool {
+			ifaceType, ok := n.(*dst.InterfaceType)
+			if !ok || field != nil {
+				return field == nil
+			}
+
+			for _, f := range ifaceType.Methods.List {
+				if len(f.Names) != 1 || f.Names[0].Name != method.Name() {
+					continue
+				}
+				astIdent, ok := state.pkg.Decorator.Ast.Nodes[f.Names[0]].(*ast.Ident)
+				if ok && astIdent.Pos() == method.Pos() {
+					field = f
+				}
+			}
+			return false
+		})
+		if field != nil {
+			return &interfaceMethodField{field: field, packageName: method.Pkg().Path()}
+		}
+	}
+	return nil
+}
+
+// addTransactionParameter adds a transaction parameter to the interface method if it does not already have one.
+// When transactions are propagated with context, a context parameter is added as its first parameter instead.
+func (m *InstrumentationManager) addTransactionParameter(method *interfaceMethodField) {
+	funcType, ok := method.field.Type.(*dst.FuncType)
+	if !ok {
+		return
+	}
+
+	for _, param := range funcType.Params.List {
+		if ident, ok := param.Type.(*dst.Ident); ok && ident.Name == "Context" && ident.Path == "context" {
+			return
+		}
+		star, ok := param.Type.(*dst.StarExpr)
+		if !ok {
+			continue
+		}
+		ident, ok := star.X.(*dst.Ident)
+		if ok && ident.Name == "Transaction" && ident.Path == codegen.NewRelicAgentImportPath {
+			return
+		}
+	}
+
+	param := codegen.NewTransactionParameter(codegen.DefaultTransactionVariable)
+	if m.options.Propagation == PropagateContext {
+		param = codegen.NewContextParameter(codegen.DefaultContextParameter)
+	}
+	// parameter names must be used for all parameters or none of them
+	if len(funcType.Params.List) > 0 && len(funcType.Params.List[0].Names) == 0 {
+		param.Names = nil
+	}
+	if m.options.Propagation == PropagateContext {
+		funcType.Params.List = append([]*dst.Field{param}, funcType.Params.List...)
+	} else {
+		funcType.Params.List = append(funcType.Params.List, param)
+	}
+
+	rootPkg := m.currentPackage
+	m.setPackage(method.packageName)
+	m.addImport(codegen.NewRelicAgentImportPath)
+	m.setPackage(rootPkg)
+}
+
+// traceInvocation traces the declarations invoked by a function call with the state that was created when tracing
+// was added to the call. Calls to interface methods trace each implementation of the method declared in this
+// application with their own copy of the state.
+//
+// Returns true if any declarations were traced.
+func (m *InstrumentationManager) traceInvocation(inv *invocationInfo, state *tracestate.State) bool {
+	invocations := []*invocationInfo{inv}
+	if inv.decl == nil {
+		invocations = inv.implementations
+	}
+
+	if inv.interfaceMethod != nil {
+		m.addTransactionParameter(inv.interfaceMethod)
+
+		// every implementation has to accept the transaction, even if the call graph can not reach it
+		for _, invocation := range inv.implementations {
+			m.addCallGraphEntryPoint(invocation.packageName, invocation.decl)
+		}
+	}
+
+	traced := false
+	rootPkg := m.currentPackage
+	for _, invocation := range invocations {
+		if !m.shouldInstrumentFunction(invocation) {
+			// functions that were already traced for another call must recover the panics that this call does not record
+			m.recoverPanicsOfCaller(invocation, state)
+			continue
+		}
+
+		invocationState := state
+		if len(invocations) > 1 {
+			invocationState = state.Clone()
+		}
+
+		m.setPackage(invocation.packageName)
+		comment.Debug(m.getDecoratorPackage(), invocation.decl, fmt.Sprintf("Tracing function: %s", functionKey(invocation.decl)))
+		traceFunction(m, invocation.decl, invocationState)
+		m.setPackage(rootPkg)
+		traced = true
+	}
+	return traced
+}
@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_functionKey(t *testing.T) {
+	tests := []struct {
+		name string
+		decl *dst.FuncDecl
+		want string
+	}{
+		{
+			name: "function",
+			decl: &dst.FuncDecl{Name: dst.NewIdent("run")},
+			want: "run",
+		},
+		{
+			name: "method with pointer receiver",
+			decl: &dst.FuncDecl{
+				Name: dst.NewIdent("Run"),
+				Recv: &dst.FieldList{List: []*dst.Field{{Type: &dst.StarExpr{X: dst.NewIdent("Server")}}}},
+			},
+			want: "Server.Run",
+		},
+		{
+			name: "method of generic type",
+			decl: &dst.FuncDecl{
+				Name: dst.NewIdent("Push"),
+				Recv: &dst.FieldList{List: []*dst.Field{{Type: &dst.StarExpr{X: &dst.IndexExpr{X: dst.NewIdent("Stack"), Index: dst.NewIdent("T")}}}}},
+			},
+			want: "Stack.Push",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, functionKey(tt.decl))
+		})
+	}
+}
+
+func TestTraceMethodCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "methods with the same name on different receivers",
+			code: `package main
+
+import "errors"
+
+type fetcher struct{}
+
+func (f fetcher) Run() error {
+	return errors.New("fetch failed")
+}
+
+type printer struct{}
+
+func (p *printer) Run() {
+	println("print")
+}
+
+func main() {
+	f := fetcher{}
+	f.Run()
+	p := &printer{}
+	p.Run()
+}
+`,
+			expect: `package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type fetcher struct{}
+
+func (f fetcher) Run(nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("Run").End()
+
+	// generated by go-easy-instrumentation; returnValue0:error
+	returnValue0 := errors.New("fetch failed")
+	if returnValue0 != nil {
+		nrTxn.NoticeError(returnValue0)
+	}
+
+	return returnValue0
+}
+
+type printer struct{}
+
+func (p *printer) Run(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("Run").End()
+
+	println("print")
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	f := fetcher{}
+	nrTxn := NewRelicAgent.StartTransaction("Run")
+	f.Run(nrTxn)
+	nrTxn.End()
+	p := &printer{}
+	nrTxn = NewRelicAgent.StartTransaction("Run")
+	p.Run(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "interface implemented in the application",
+			code: `package main
+
+type Service interface {
+	Process(int) error
+}
+
+type adder struct{ total int }
+
+func (a *adder) Process(n int) error {
+	a.total += n
+	return nil
+}
+
+type logger struct{}
+
+func (logger) Process(n int) error {
+	println(n)
+	return nil
+}
+
+func run(svc Service) {
+	svc.Process(1)
+}
+
+func main() {
+	run(&adder{})
+	run(logger{})
+}
+`,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Service interface {
+	Process(int, *newrelic.Transaction) error
+}
+
+type adder struct{ total int }
+
+func (a *adder) Process(n int, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("Process").End()
+
+	a.total += n
+	return nil
+}
+
+type logger struct{}
+
+func (logger) Process(n int, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("Process").End()
+
+	println(n)
+	return nil
+}
+
+func run(svc Service, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("run").End()
+
+	svc.Process(1, nrTxn)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(&adder{}, nrTxn)
+	nrTxn.End()
+	nrTxn = NewRelicAgent.StartTransaction("run")
+	run(logger{}, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "interface method that accepts a context",
+			code: `package main
+
+import "context"
+
+type Service interface {
+	Process(ctx context.Context, n int) error
+}
+
+type adder struct{ total int }
+
+func (a *adder) Process(ctx context.Context, n int) error {
+	a.total += n
+	return nil
+}
+
+func run(ctx context.Context, svc Service) {
+	svc.Process(ctx, 1)
+}
+
+func main() {
+	run(context.Background(), &adder{})
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Service interface {
+	Process(ctx context.Context, n int) error
+}
+
+type adder struct{ total int }
+
+func (a *adder) Process(ctx context.Context, n int) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("Process").End()
+
+	a.total += n
+	return nil
+}
+
+func run(ctx context.Context, svc Service) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("run").End()
+
+	svc.Process(ctx, 1)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(newrelic.NewContext(context.Background(), nrTxn), &adder{})
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestTraceInterfaceWithExternalImplementations(t *testing.T) {
+	code := `package main
+
+import "strings"
+
+type writer interface {
+	Write(p []byte) (int, error)
+}
+
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func write(w writer) {
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	write(logWriter{})
+	write(&strings.Builder{})
+}
+`
+	defer panicRecovery(t)
+	got := testStatelessTracingFunction(t, code, InstrumentMain)
+	assert.Contains(t, got, "\t// NR WARN: the transaction can not be passed to implementations of writer.Write because its signature can not be changed\n")
+	assert.Contains(t, got, "\t// implemented outside of this application by: strings.Builder\n")
+	assert.Contains(t, got, "func (logWriter) Write(p []byte) (int, error) {\n\treturn len(p), nil\n}")
+	assert.Contains(t, got, "\tw.Write([]byte(\"hello\"))\n")
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -12,6 +16,7 @@ import (
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate/traceobject"
 )
 
 const (
@@ -28,8 +33,84 @@ const (
 
 	// default net/http client variable
 	httpDefaultClientVariable = "DefaultClient"
+
+	// the variable that external segments around calls made with the default client are assigned to
+	externalSegmentVariable = "externalSegment"
+
+	// the package level variable of the instrumented client that rewritten net/http method calls are sent with
+	httpClientVariable = "nrHttpClient"
+
+	// method of *http.Request that returns the value of a ServeMux pattern wildcard
+	httpPathValue = "PathValue"
+
+	// functions that create http requests
+	httpNewRequest            = "NewRequest"
+	httpNewRequestWithContext = "NewRequestWithContext"
 )
 
+// serveMuxPattern is a parsed net/http.ServeMux routing pattern. Since Go 1.22, patterns
+// take the form "[METHOD ][HOST]/[PATH]", where the path may contain wildcards like
+// "{id}" or "{path...}".
+//
+// See: https://pkg.go.dev/net/http#hdr-Patterns-ServeMux
+type serveMuxPattern struct {
+	method    string
+	host      string
+	path      string
+	wildcards []string
+}
+
+// parseServeMuxPattern parses a ServeMux routing pattern. It returns false if the pattern
+// is not valid.
+func parseServeMuxPattern(pattern string) (*serveMuxPattern, bool) {
+	p := &serveMuxPattern{}
+	rest := strings.TrimSpace(pattern)
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		p.method = rest[:i]
+		rest = strings.TrimLeft(rest[i:], " \t")
+		if p.method == "" || strings.ToUpper(p.method) != p.method {
+			return nil, false
+		}
+	}
+
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return nil, false
+	}
+	p.host = rest[:slash]
+	p.path = rest[slash:]
+
+	for _, segment := range strings.Split(p.path, "/") {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+		if !strings.HasSuffix(segment, "}") {
+			return nil, false
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(segment[1:len(segment)-1], "..."), "$")
+		if name != "" {
+			p.wildcards = append(p.wildcards, name)
+		}
+	}
+
+	return p, true
+}
+
+// transactionName returns the name a transaction for requests matching this pattern should have.
+func (p *serveMuxPattern) transactionName() string {
+	if p.method == "" {
+		return p.host + p.path
+	}
+	return p.method + " " + p.host + p.path
+}
+
+// needsTransactionName returns true when the name newrelic.WrapHandle gives a transaction
+// for this pattern would be incorrect. WrapHandle names transactions "<request method> <pattern>",
+// which duplicates the method of patterns that already contain one.
+func (p *serveMuxPattern) needsTransactionName() bool {
+	return p.method != ""
+}
+
 // RouterHasMiddleware detects already existing net/http routers and marks them within the scope of the given transaction.
 // It returns true if the function name matches within a wrapped HandleFunc, false otherwise.
 // TO:DO -- Can this be extended to ALL routing libraries?
@@ -302,18 +383,8 @@ func isTransportInstrumented(stmt dst.Stmt, clientVarName string) bool {
 // clientTransportAlreadyInstrumented checks if the client's Transport is already set to newrelic.NewRoundTripper
 // In the statements within the block
 func clientTransportAlreadyInstrumented(c *dstutil.Cursor, clientVarName string) bool {
-	parent := c.Parent()
-
-	var blockStmt *dst.BlockStmt
-	for parent != nil {
-		if block, ok := parent.(*dst.BlockStmt); ok {
-			blockStmt = block
-			break
-		}
-		parent = c.Parent()
-	}
-
-	if blockStmt == nil {
+	blockStmt, ok := c.Parent().(*dst.BlockStmt)
+	if !ok {
 		return false
 	}
 	// Get current statements index
@@ -349,6 +420,34 @@ func isNetHttpClientDefinition(stmt *dst.AssignStmt) bool {
 	return false
 }
 
+// hasRoundTripperTransport returns true if the http client created by a statement matched by isNetHttpClientDefinition
+// already has a New Relic round tripper as its transport, like the clients created by codegen.InstrumentedHttpClient:
+//
+//	client := &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+func hasRoundTripperTransport(stmt *dst.AssignStmt) bool {
+	return isInstrumentedHttpClient(stmt.Rhs[0])
+}
+
+// isInstrumentedHttpClient returns true if the expression creates an http client with a New Relic round tripper
+// as its transport.
+func isInstrumentedHttpClient(expr dst.Expr) bool {
+	unary, ok := expr.(*dst.UnaryExpr)
+	if !ok || unary.Op != token.AND {
+		return false
+	}
+	lit, ok := unary.X.(*dst.CompositeLit)
+	if !ok {
+		return false
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*dst.KeyValueExpr)
+		if ok && isIdent(kv.Key, "Transport") && isNewRelicCall(kv.Value, "NewRoundTripper") {
+			return true
+		}
+	}
+	return false
+}
+
 // StatelessTracingFunctions
 //////////////////////////////////////////////
 
@@ -376,13 +475,132 @@ func InstrumentHandleFunction(manager *InstrumentationManager, c *dstutil.Cursor
 func InstrumentHttpClient(manager *InstrumentationManager, c *dstutil.Cursor) {
 	n := c.Node()
 	stmt, ok := n.(*dst.AssignStmt)
-	if ok && isNetHttpClientDefinition(stmt) && c.Index() >= 0 && n.Decorations() != nil {
+	if !ok || !isNetHttpClientDefinition(stmt) || hasRoundTripperTransport(stmt) {
+		return
+	}
+	if client, ok := stmt.Lhs[0].(*dst.Ident); ok && clientTransportAlreadyInstrumented(c, client.Name) {
+		return
+	}
+	if c.Index() >= 0 && n.Decorations() != nil {
 		c.InsertAfter(codegen.RoundTripper(stmt.Lhs[0], n.Decorations().After)) // add roundtripper to transports
 		stmt.Decs.After = dst.None
 		manager.addImport(codegen.NewRelicAgentImportPath)
 	}
 }
 
+// isNetHttpClientType returns true if the type is an http.Client or a pointer to one.
+func isNetHttpClientType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	switch t.String() {
+	case "*net/http.Client", "net/http.Client":
+		return true
+	}
+	return false
+}
+
+// packageInstrumentsClientTransport returns true if any function in the package wraps the transport of the named
+// client with a New Relic round tripper.
+func packageInstrumentsClientTransport(pkg *decorator.Package, clientVarName string) bool {
+	instrumented := false
+	for _, file := range pkg.Syntax {
+		dst.Inspect(file, func(n dst.Node) bool {
+			stmt, ok := n.(dst.Stmt)
+			if ok && isTransportInstrumented(stmt, clientVarName) {
+				instrumented = true
+			}
+			return !instrumented
+		})
+	}
+	return instrumented
+}
+
+// InstrumentPackageHttpClient wraps the transport of http clients declared as package level variables
+// with a New Relic round tripper. Any existing transport is preserved and wrapped. This is done once in
+// an init function that is added after the first client declared in each file:
+//
+//	var client = &http.Client{Timeout: 10 * time.Second}
+//
+//	func init() {
+//		client.Transport = newrelic.NewRoundTripper(client.Transport)
+//	}
+func InstrumentPackageHttpClient(manager *InstrumentationManager, c *dstutil.Cursor) {
+	decl, ok := c.Node().(*dst.GenDecl)
+	if !ok || decl.Tok != token.VAR {
+		return
+	}
+
+	file, ok := c.Parent().(*dst.File)
+	if !ok {
+		return
+	}
+
+	pkg := manager.getDecoratorPackage()
+	stmts := []dst.Stmt{}
+	for _, spec := range decl.Specs {
+		valueSpec, ok := spec.(*dst.ValueSpec)
+		if !ok {
+			continue
+		}
+
+		for i, name := range valueSpec.Names {
+			if name.Name == "_" || !isNetHttpClientType(util.TypeOf(name, pkg)) || packageInstrumentsClientTransport(pkg, name.Name) {
+				continue
+			}
+			// the transport of http.DefaultClient is wrapped in main
+			if len(valueSpec.Values) == len(valueSpec.Names) && (isInstrumentedHttpClient(valueSpec.Values[i]) || isDefaultHttpClient(valueSpec.Values[i])) {
+				continue
+			}
+
+			comment.Debug(pkg, decl, fmt.Sprintf("Injecting New Relic round tripper into package level http client: %s", name.Name))
+			stmts = append(stmts, codegen.RoundTripper(dst.NewIdent(name.Name), dst.NewLine))
+		}
+	}
+
+	if len(stmts) == 0 {
+		return
+	}
+	if manager.httpClientInits == nil {
+		manager.httpClientInits = map[*dst.File]*dst.FuncDecl{}
+	}
+	if init, ok := manager.httpClientInits[file]; ok {
+		init.Body.List = append(init.Body.List, stmts...)
+	} else {
+		init := codegen.InitFunction(stmts...)
+		manager.httpClientInits[file] = init
+		c.InsertAfter(init)
+	}
+	manager.addImport(codegen.NewRelicAgentImportPath)
+}
+
+// isDefaultHttpClient returns true if the expression is http.DefaultClient.
+func isDefaultHttpClient(expr dst.Expr) bool {
+	ident, ok := expr.(*dst.Ident)
+	return ok && ident.Name == httpDefaultClientVariable && ident.Path == codegen.HttpImportPath
+}
+
+// defaultHttpClientWrapped returns true if the transport of http.DefaultClient is wrapped with a New Relic round
+// tripper, either by the application or by the instrumentation of its main function.
+func (m *InstrumentationManager) defaultHttpClientWrapped() bool {
+	return m.defaultHttpClientInstrumented || (m.defaultHttpClientUsed && m.mainDeclared)
+}
+
+// instrumentDefaultHttpClient wraps the transport of http.DefaultClient with a New Relic round tripper in the main
+// function at the index given if the application uses it, and has not already instrumented it.
+func instrumentDefaultHttpClient(manager *InstrumentationManager, mainDecl *dst.FuncDecl, index int) {
+	if !manager.defaultHttpClientUsed || manager.defaultHttpClientInstrumented || mainDecl.Body == nil {
+		return
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), mainDecl, "Injecting New Relic round tripper into http.DefaultClient")
+	defaultClient := &dst.Ident{Name: httpDefaultClientVariable, Path: codegen.HttpImportPath}
+	mainDecl.Body.List = slices.Insert(mainDecl.Body.List, index, dst.Stmt(codegen.RoundTripper(defaultClient, dst.EmptyLine)))
+	manager.defaultHttpClientInstrumented = true
+	manager.addImport(codegen.NewRelicAgentImportPath)
+}
+
 func cannotTraceOutboundHttp(method string, decs *dst.NodeDecs) []string {
 	comment := []string{
 		fmt.Sprintf("// the \"http.%s()\" net/http method can not be instrumented and its outbound traffic can not be traced", method),
@@ -435,9 +653,11 @@ func CannotInstrumentHttpMethod(manager *InstrumentationManager, c *dstutil.Curs
 	n := c.Node()
 	funcName, ok := isNetHttpMethodCannotInstrument(n)
 	if ok {
-		if decl := n.Decorations(); decl != nil {
+		if decl := n.Decorations(); decl != nil && !comment.HasComment(decl, cannotTraceOutboundHttp(funcName, nil)) {
 			decl.Start.Prepend(cannotTraceOutboundHttp(funcName, n.Decorations())...)
 		}
+		comment.Record(manager.getDecoratorPackage(), httpMethodRule, comment.LevelWarning, n,
+			fmt.Sprintf("the \"http.%s()\" net/http method can not be instrumented and its outbound traffic can not be traced", funcName))
 	}
 }
 
@@ -471,6 +691,88 @@ func getHttpResponseVariable(manager *InstrumentationManager, stmt dst.Stmt) dst
 	return expression
 }
 
+// requestHasTransaction returns true if the request is a variable that was created with a transaction in its context.
+func (m *InstrumentationManager) requestHasTransaction(request dst.Expr) bool {
+	ident, ok := request.(*dst.Ident)
+	if !ok {
+		return false
+	}
+
+	obj := util.ObjectOf(ident, m.getDecoratorPackage())
+	return obj != nil && m.requestsWithTransaction[obj]
+}
+
+// isNewRelicContext returns true if the expression is a call to newrelic.NewContext.
+func isNewRelicContext(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && ident.Name == "NewContext" && ident.Path == codegen.NewRelicAgentImportPath
+}
+
+// HttpRequestWithTransactionContext makes sure that http requests created in traced functions carry the transaction in
+// their context, so that clients with a New Relic round tripper can create external segments and add distributed tracing
+// headers to them no matter how the request is passed to the client:
+//
+//	req, err := http.NewRequest("GET", url, nil)
+//
+// becomes
+//
+//	req, err := http.NewRequestWithContext(newrelic.NewContext(ctx, nrTxn), "GET", url, nil)
+//
+// The context is derived from the one returned by traceobject.RequestContext, so requests created in a handler now inherit the
+// cancellation and deadline of the incoming request. Calls to http.NewRequestWithContext get the transaction added
+// to the context they are passed. The requests are remembered so that ExternalHttpCall does not need to add the
+// transaction again when they are sent.
+func HttpRequestWithTransactionContext(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if tracing.IsMain() {
+		return false
+	}
+
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 || len(assign.Lhs) == 0 {
+		return false
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Path != codegen.HttpImportPath {
+		return false
+	}
+
+	pkg := manager.getDecoratorPackage()
+	switch {
+	case ident.Name == httpNewRequest && len(call.Args) == 3:
+		comment.Debug(pkg, stmt, "Converting http.NewRequest to http.NewRequestWithContext with a transaction")
+		ctx := codegen.NewContextExpression(traceobject.RequestContext(stmt, pkg), tracing.TransactionVariable())
+		ident.Name = httpNewRequestWithContext
+		call.Args = append([]dst.Expr{ctx}, call.Args...)
+	case ident.Name == httpNewRequestWithContext && len(call.Args) == 4:
+		if !isNewRelicContext(call.Args[0]) {
+			comment.Debug(pkg, stmt, "Adding a transaction to the context of http.NewRequestWithContext")
+			call.Args[0] = codegen.NewContextExpression(call.Args[0], tracing.TransactionVariable())
+		}
+	default:
+		return false
+	}
+
+	if request, ok := assign.Lhs[0].(*dst.Ident); ok {
+		if obj := util.ObjectOf(request, pkg); obj != nil && manager.requestsWithTransaction != nil {
+			manager.requestsWithTransaction[obj] = true
+		}
+	}
+
+	manager.addImport(codegen.NewRelicAgentImportPath)
+	return true
+}
+
 // ExternalHttpCall finds and instruments external net/http calls to the method http.Do.
 // It returns true if a modification was made
 func ExternalHttpCall(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
@@ -492,12 +794,20 @@ func ExternalHttpCall(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil
 		return true
 	})
 	if call != nil && c.Index() >= 0 {
+		if isExternalCallInstrumented(call, previousStatement(c)) {
+			comment.Debug(manager.getDecoratorPackage(), stmt, "HTTP call was already instrumented")
+			return false
+		}
+
 		clientVar := getNetHttpClientVariableName(call, pkg)
 		requestObject := call.Args[0]
-		if clientVar == httpDefaultClientVariable {
+
+		// when the transport of the default client is wrapped with a round tripper, the transaction only needs to
+		// be added to the request context
+		if clientVar == httpDefaultClientVariable && !manager.defaultHttpClientWrapped() {
 			// create external segment to wrap calls made with default client
 			comment.Debug(manager.getDecoratorPackage(), stmt, "Wrapping default HTTP client call with external segment")
-			segmentName := "externalSegment"
+			segmentName := externalSegmentVariable
 			c.InsertBefore(codegen.StartExternalSegment(requestObject, tracing.TransactionVariable(), segmentName, stmt.Decorations()))
 			c.InsertAfter(codegen.EndExternalSegment(segmentName, stmt.Decorations()))
 			responseVar := getHttpResponseVariable(manager, stmt)
@@ -506,18 +816,418 @@ func ExternalHttpCall(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil
 				c.InsertAfter(codegen.CaptureHttpResponse(segmentName, responseVar))
 			}
 			return true
+		} else if manager.requestHasTransaction(requestObject) {
+			comment.Debug(manager.getDecoratorPackage(), stmt, "HTTP request was created with a transaction in its context")
+			return false
 		} else {
 			comment.Debug(manager.getDecoratorPackage(), stmt, "Injecting transaction context into HTTP request")
-			c.InsertBefore(codegen.WrapRequestContext(requestObject, tracing.TransactionVariable(), stmt.Decorations()))
+			switch requestObject.(type) {
+			case *dst.Ident, *dst.SelectorExpr:
+				c.InsertBefore(codegen.WrapRequestContext(requestObject, tracing.TransactionVariable(), stmt.Decorations()))
+			default:
+				// requests returned by a function call can not be assigned to
+				call.Args[0] = codegen.RequestWithTransactionContext(requestObject, tracing.TransactionVariable())
+			}
+			manager.addImport(codegen.NewRelicAgentImportPath)
+			return true
+		}
+	}
+	return false
+}
+
+// newHttpMethodRequest returns the method, url and body expressions needed to create an *http.Request
+// equivalent to a call to one of the net/http package level methods, along with the content type that
+// method would set on the request. The content type will be nil if the method does not set one.
+func newHttpMethodRequest(funcName string, args []dst.Expr) (string, dst.Expr, dst.Expr, dst.Expr, bool) {
+	noBody := dst.NewIdent("nil")
+	switch funcName {
+	case httpGet:
+		if len(args) == 1 {
+			return "GET", args[0], noBody, nil, true
+		}
+	case httpHead:
+		if len(args) == 1 {
+			return "HEAD", args[0], noBody, nil, true
+		}
+	case httpPost:
+		if len(args) == 3 {
+			return "POST", args[0], args[2], args[1], true
+		}
+	case httpPostForm:
+		if len(args) == 2 {
+			contentType := &dst.BasicLit{Kind: token.STRING, Value: `"application/x-www-form-urlencoded"`}
+			return "POST", args[0], codegen.FormBody(args[1]), contentType, true
+		}
+	}
+	return "", nil, nil, nil, false
+}
+
+// isNewVariable returns true if the expression is an identifier that is declared by the statement it is in.
+func isNewVariable(expr dst.Expr, pkg *decorator.Package) bool {
+	ident, ok := expr.(*dst.Ident)
+	if !ok || ident.Name == "_" || pkg == nil || pkg.Decorator == nil {
+		return false
+	}
+
+	astIdent, ok := pkg.Decorator.Ast.Nodes[ident].(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	return pkg.TypesInfo.Defs[astIdent] != nil
+}
+
+// unusedVariableName returns a variable name based on name that is not used anywhere within nodes. If stmt is not nil,
+// the name also must not refer to a declaration in scope at the position of stmt, so that it can be declared there
+// without shadowing a variable or parameter.
+func unusedVariableName(pkg *decorator.Package, stmt dst.Stmt, name string, nodes ...dst.Node) string {
+	used := map[string]bool{}
+	for _, node := range nodes {
+		dst.Inspect(node, func(n dst.Node) bool {
+			if ident, ok := n.(*dst.Ident); ok {
+				used[ident.Name] = true
+			}
+			return true
+		})
+	}
+
+	candidate := name
+	for i := 2; used[candidate] || (stmt != nil && util.IsDeclared(stmt, pkg, candidate)); i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	return candidate
+}
+
+// instrumentedHttpClient returns the name of a package level http client with a New Relic round tripper that the
+// requests rewritten by RewriteHttpMethodCall in the current package share. The client is declared after the imports
+// of the file that contains node the first time it is needed:
+//
+//	var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+func (m *InstrumentationManager) instrumentedHttpClient(node dst.Node) (string, bool) {
+	state, ok := m.packages[m.currentPackage]
+	if !ok {
+		return "", false
+	}
+	if state.httpClient != "" {
+		return state.httpClient, true
+	}
+
+	var file *dst.File
+	for _, f := range state.pkg.Syntax {
+		dst.Inspect(f, func(n dst.Node) bool {
+			if n == node {
+				file = f
+			}
+			return file == nil
+		})
+		if file != nil {
+			break
+		}
+	}
+	if file == nil {
+		return "", false
+	}
+
+	files := make([]dst.Node, len(state.pkg.Syntax))
+	for i, f := range state.pkg.Syntax {
+		files[i] = f
+	}
+	name := unusedVariableName(state.pkg, nil, httpClientVariable, files...)
+
+	index := 0
+	for i, decl := range file.Decls {
+		if gen, ok := decl.(*dst.GenDecl); ok && gen.Tok == token.IMPORT {
+			index = i + 1
+		}
+	}
+	// the declarations of the file may be iterated over while it is instrumented, so they are copied rather than shifted
+	file.Decls = slices.Concat(file.Decls[:index], []dst.Decl{codegen.InstrumentedHttpClientDeclaration(name)}, file.Decls[index:])
+	state.httpClient = name
+	return name, true
+}
+
+// removeCannotTraceOutboundHttpComment removes the warning left by CannotInstrumentHttpMethod from a node's decorations.
+func removeCannotTraceOutboundHttpComment(method string, decs *dst.NodeDecs) {
+	warning := cannotTraceOutboundHttp(method, nil)
+	existing := decs.Start.All()
+	if len(existing) < len(warning) || !slices.Equal(existing[:len(warning)], warning) {
+		return
+	}
+
+	existing = existing[len(warning):]
+	if len(existing) > 0 && existing[0] == "//" {
+		existing = existing[1:]
+	}
+	decs.Start.Replace(existing...)
+}
+
+// RewriteHttpMethodCall rewrites calls to http.Get, http.Head, http.Post and http.PostForm in traced functions
+// into a request created with http.NewRequestWithContext that is sent by a client with a New Relic round tripper.
+// This allows external segments to be created and distributed tracing headers to be added to these requests.
+//
+// The call must be assigned to a response and an error variable. The rewritten code only sends the request if it
+// was created successfully, so any error handling that follows the original call is preserved:
+//
+//	var resp *http.Response
+//	req, err := http.NewRequestWithContext(newrelic.NewContext(r.Context(), nrTxn), http.MethodGet, url, nil)
+//	if err == nil {
+//		resp, err = nrHttpClient.Do(req)
+//	}
+//
+// The context of the request is derived from the context in scope, such as the context of the incoming request in a
+// handler, as described by traceobject.RequestContext. The requests of a package are sent by one client, which is
+// declared by instrumentedHttpClient.
+func RewriteHttpMethodCall(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if c.Index() < 0 || tracing.IsMain() {
+		return false
+	}
+
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return false
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Path != codegen.HttpImportPath {
+		return false
+	}
+
+	method, url, body, contentType, ok := newHttpMethodRequest(ident.Name, call.Args)
+	if !ok {
+		return false
+	}
+
+	response := assign.Lhs[0]
+	errVar, ok := assign.Lhs[1].(*dst.Ident)
+	if !ok || errVar.Name == "_" {
+		comment.Info(manager.getDecoratorPackage(), unassignedHttpErrorRule, stmt, stmt, fmt.Sprintf("the error returned by http.%s() must be assigned to a variable for this call to be instrumented", ident.Name))
+		return false
+	}
+
+	pkg := manager.getDecoratorPackage()
+	newResponse := assign.Tok == token.DEFINE && isNewVariable(response, pkg)
+	newErr := assign.Tok == token.DEFINE && isNewVariable(errVar, pkg)
+	client, ok := manager.instrumentedHttpClient(stmt)
+	if !ok {
+		return false
+	}
+	requestVar := dst.NewIdent(unusedVariableName(pkg, stmt, "req", c.Parent()))
+	ctx := codegen.NewContextExpression(traceobject.RequestContext(stmt, pkg), tracing.TransactionVariable())
+
+	comment.Debug(pkg, stmt, fmt.Sprintf("Rewriting http.%s() into an instrumented http client request", ident.Name))
+	removeCannotTraceOutboundHttpComment(ident.Name, stmt.Decorations())
+
+	newRequest := &dst.AssignStmt{
+		Lhs: []dst.Expr{requestVar, dst.Clone(errVar).(dst.Expr)},
+		Tok: token.ASSIGN,
+		Rhs: []dst.Expr{codegen.NewRequestWithContext(ctx, method, url, body)},
+	}
+	if newErr {
+		newRequest.Tok = token.DEFINE
+	}
+
+	var declarations []dst.Stmt
+	if newResponse {
+		declarations = append(declarations, codegen.DeclareVariable(response.(*dst.Ident).Name, &dst.StarExpr{X: &dst.Ident{Name: "Response", Path: codegen.HttpImportPath}}))
+	}
+	if !newErr {
+		declarations = append(declarations, codegen.DeclareVariable(requestVar.Name, &dst.StarExpr{X: &dst.Ident{Name: "Request", Path: codegen.HttpImportPath}}))
+	}
+
+	sendRequest := []dst.Stmt{}
+	if contentType != nil {
+		sendRequest = append(sendRequest, codegen.SetRequestHeader(requestVar, "Content-Type", contentType))
+	}
+	sendRequest = append(sendRequest,
+		&dst.AssignStmt{
+			Lhs: []dst.Expr{dst.Clone(response).(dst.Expr), dst.Clone(errVar).(dst.Expr)},
+			Tok: token.ASSIGN,
+			Rhs: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.SelectorExpr{
+						X:   dst.NewIdent(client),
+						Sel: dst.NewIdent(httpDo),
+					},
+					Args: []dst.Expr{dst.Clone(requestVar).(dst.Expr)},
+				},
+			},
+		},
+	)
+
+	sendIfCreated := &dst.IfStmt{
+		Cond: &dst.BinaryExpr{
+			X:  dst.Clone(errVar).(dst.Expr),
+			Op: token.EQL,
+			Y:  dst.NewIdent("nil"),
+		},
+		Body: &dst.BlockStmt{List: sendRequest},
+	}
+
+	// move the decorations of the original statement to the start and end of the rewritten code
+	first := dst.Stmt(newRequest)
+	if len(declarations) > 0 {
+		first = declarations[0]
+	}
+	codegen.WrapStatements(first, stmt, sendIfCreated)
+
+	for _, decl := range declarations {
+		c.InsertBefore(decl)
+	}
+	c.Replace(newRequest)
+	c.InsertAfter(sendIfCreated)
+	manager.addImport(codegen.NewRelicAgentImportPath)
+	return true
+}
+
+// getHandleFunctionPattern returns the parsed ServeMux pattern passed to a HandleFunc or Handle call
+// if it is a string literal.
+func getHandleFunctionPattern(call *dst.CallExpr) (*serveMuxPattern, bool) {
+	if len(call.Args) != 2 {
+		return nil, false
+	}
+
+	lit, ok := call.Args[0].(*dst.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil, false
+	}
+
+	pattern, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil, false
+	}
+
+	return parseServeMuxPattern(pattern)
+}
+
+// nameHandlerTransaction sets the name of the transaction created for a handler registered with a
+// ServeMux pattern. The handler may be a function literal, or a function declared in this application.
+// Returns true if the handler was modified.
+func nameHandlerTransaction(manager *InstrumentationManager, stmt dst.Stmt, handler dst.Expr, pattern *serveMuxPattern) bool {
+	name := pattern.transactionName()
+	comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Naming transactions for ServeMux pattern: %s", name))
+	switch v := handler.(type) {
+	case *dst.FuncLit:
+		ok, reqArgName := getHTTPRequestArgNameLit(v)
+		if ok && !hasTransactionName(v.Body) {
+			codegen.PrependStatementToFunctionLit(v, codegen.SetTransactionName(codegen.TxnFromContextExpression(codegen.HttpRequestContext(reqArgName)), name))
 			manager.addImport(codegen.NewRelicAgentImportPath)
 			return true
 		}
+	case *dst.Ident:
+		// handlers declared in other packages can not have the import added to their file
+		// a declaration shared by several routes can only be named at one of them
+		pkg, ok := manager.packages[manager.getPackageName()]
+		if ok && v.Path == "" && pkg.tracedFuncs[v.Name] != nil && manager.handlerRegistrations(v.Name) == 1 {
+			decl := pkg.tracedFuncs[v.Name].body
+			ok, reqArgName := getHTTPRequestArgNameDecl(decl)
+			if ok && !hasTransactionName(decl.Body) {
+				codegen.PrependStatementToFunctionDecl(decl, codegen.SetTransactionName(codegen.TxnFromContextExpression(codegen.HttpRequestContext(reqArgName)), name))
+				manager.addImport(codegen.NewRelicAgentImportPath)
+				return true
+			}
+		}
 	}
+
+	comment.Info(manager.getDecoratorPackage(), routeTransactionNameRule, stmt, stmt,
+		fmt.Sprintf("transactions for the route \"%s\" will be named \"%s %s\"", name, pattern.method, name),
+		fmt.Sprintf("call SetName(\"%s\") on the transaction in this handler to name it after the route pattern", name),
+	)
 	return false
 }
 
+// handlerRegistration identifies a handler declared in a package. Handlers referred to from the package they are declared
+// in are identified by the ID of the package, and handlers referred to from other packages by its path.
+type handlerRegistration struct {
+	pkg       string
+	name      string
+	qualified bool
+}
+
+// handlerRegistrations returns the number of times a handler declared in the current package is registered with
+// Handle or HandleFunc, before or after the registration is wrapped, in any package of the application.
+// The registrations are counted once, the first time this is called.
+func (m *InstrumentationManager) handlerRegistrations(name string) int {
+	if m.handlerRegistrationCounts == nil {
+		m.handlerRegistrationCounts = m.countHandlerRegistrations()
+	}
+	current := m.getDecoratorPackage()
+	return m.handlerRegistrationCounts[handlerRegistration{pkg: current.ID, name: name}] +
+		m.handlerRegistrationCounts[handlerRegistration{pkg: current.PkgPath, name: name, qualified: true}]
+}
+
+// countHandlerRegistrations counts the handlers registered with Handle or HandleFunc in every package of the application.
+func (m *InstrumentationManager) countHandlerRegistrations() map[handlerRegistration]int {
+	counts := map[handlerRegistration]int{}
+	for _, state := range m.packages {
+		for _, file := range state.pkg.Syntax {
+			dst.Inspect(file, func(n dst.Node) bool {
+				call, ok := n.(*dst.CallExpr)
+				if !ok {
+					return true
+				}
+				method := getNetHttpMethod(call, state.pkg)
+				if method != httpHandleFunc && method != httpMuxHandle {
+					return true
+				}
+
+				var handler dst.Expr
+				switch len(call.Args) {
+				case 2:
+					handler = call.Args[1]
+				case 1:
+					// mux.HandleFunc(newrelic.WrapHandleFunc(app, pattern, handler))
+					wrap, ok := call.Args[0].(*dst.CallExpr)
+					if ok && len(wrap.Args) == 3 {
+						handler = wrap.Args[2]
+					}
+				}
+
+				ident, ok := handler.(*dst.Ident)
+				if !ok {
+					return true
+				}
+				if ident.Path == "" {
+					counts[handlerRegistration{pkg: state.pkg.ID, name: ident.Name}]++
+				} else {
+					counts[handlerRegistration{pkg: ident.Path, name: ident.Name, qualified: true}]++
+				}
+				return true
+			})
+		}
+	}
+	return counts
+}
+
+// hasTransactionName returns true if the first statement in the block sets the name of a transaction.
+func hasTransactionName(body *dst.BlockStmt) bool {
+	if body == nil || len(body.List) == 0 {
+		return false
+	}
+
+	expr, ok := body.List[0].(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+
+	call, ok := expr.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == "SetName"
+}
+
 // WrapHandleFunction is a function that wraps net/http.HandeFunc() declarations inside of functions
 // that are being traced by a transaction.
+//
+// Routes registered with a ServeMux pattern that contains a method, such as "GET /items/{id}", also
+// get their transaction named after the pattern.
 func WrapNestedHandleFunction(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
 	wasModified := false
 	pkg := manager.getDecoratorPackage()
@@ -531,6 +1241,11 @@ func WrapNestedHandleFunction(manager *InstrumentationManager, stmt dst.Stmt, c
 			switch funcName {
 			case httpHandleFunc:
 				if len(callExpr.Args) == 2 {
+					pattern, ok := getHandleFunctionPattern(callExpr)
+					if ok && pattern.needsTransactionName() {
+						nameHandlerTransaction(manager, stmt, callExpr.Args[1], pattern)
+					}
+
 					// Instrument handle funcs
 					comment.Debug(manager.getDecoratorPackage(), stmt, "Wrapping http.HandleFunc with newrelic.WrapHandleFunc")
 					codegen.WrapHttpHandleFunc(tracing.AgentVariable(), callExpr)
@@ -541,6 +1256,11 @@ func WrapNestedHandleFunction(manager *InstrumentationManager, stmt dst.Stmt, c
 				}
 			case httpMuxHandle:
 				if len(callExpr.Args) == 2 {
+					pattern, ok := getHandleFunctionPattern(callExpr)
+					if ok && pattern.needsTransactionName() {
+						nameHandlerTransaction(manager, stmt, callExpr.Args[1], pattern)
+					}
+
 					// Instrument handle funcs
 					comment.Debug(manager.getDecoratorPackage(), stmt, "Wrapping http.Handle with newrelic.WrapHandle")
 					codegen.WrapHttpHandle(tracing.AgentVariable(), callExpr)
@@ -556,10 +1276,95 @@ func WrapNestedHandleFunction(manager *InstrumentationManager, stmt dst.Stmt, c
 	return wasModified
 }
 
+// getPathValueKey returns the wildcard name passed to a call of (*http.Request).PathValue.
+func getPathValueKey(call *dst.CallExpr, pkg *decorator.Package) (string, bool) {
+	if len(call.Args) != 1 {
+		return "", false
+	}
+
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != httpPathValue {
+		return "", false
+	}
+
+	recvType := util.TypeOf(sel.X, pkg)
+	if recvType == nil || recvType.String() != "*net/http.Request" {
+		return "", false
+	}
+
+	lit, ok := call.Args[0].(*dst.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+
+	key, err := strconv.Unquote(lit.Value)
+	if err != nil || key == "" {
+		return "", false
+	}
+
+	return key, true
+}
+
+// RecordPathValueAttributes records the values of ServeMux pattern wildcards as transaction attributes
+// when they are read by a traced function with `v := r.PathValue("key")`.
+//
+// This is disabled by default because path values can contain sensitive or high cardinality data.
+func RecordPathValueAttributes(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if c.Index() < 0 || tracing.IsMain() {
+		return false
+	}
+
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return false
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	ident, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok || ident.Name == "_" {
+		return false
+	}
+
+	key, ok := getPathValueKey(call, manager.getDecoratorPackage())
+	if !ok {
+		return false
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Recording path value \"%s\" as a transaction attribute", key))
+	c.InsertAfter(codegen.AddAttribute(tracing.TransactionVariable(), key, ident))
+	return true
+}
+
 ////////////////////////////
 // Pre-Instrumentation Tracing Functions
 ////////////////////////////
 
+// DetectDefaultHttpClient records whether the application uses http.DefaultClient, whether its transport
+// is already wrapped with a New Relic round tripper, and whether it has a main function to wrap it in.
+func DetectDefaultHttpClient(manager *InstrumentationManager, c *dstutil.Cursor) {
+	switch v := c.Node().(type) {
+	case *dst.FuncDecl:
+		if v.Name.Name == "main" && v.Recv == nil && v.Body != nil && manager.getDecoratorPackage().Name == "main" {
+			manager.mainDeclared = true
+		}
+	case *dst.Ident:
+		if isDefaultHttpClient(v) {
+			manager.defaultHttpClientUsed = true
+		}
+	case *dst.AssignStmt:
+		if isTransportInstrumented(v, httpDefaultClientVariable) {
+			sel := v.Lhs[0].(*dst.SelectorExpr)
+			if sel.X.(*dst.Ident).Path == codegen.HttpImportPath {
+				manager.defaultHttpClientInstrumented = true
+			}
+		}
+	}
+}
+
 func DetectWrappedRoutes(manager *InstrumentationManager, c *dstutil.Cursor) {
 	mainFunctionNode := c.Node()
 	if decl, ok := mainFunctionNode.(*dst.FuncDecl); ok {
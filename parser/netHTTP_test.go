@@ -3,13 +3,17 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"reflect"
 	"testing"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/decorator/resolver/goast"
 	"github.com/dave/dst/decorator/resolver/guess"
+	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -738,9 +742,10 @@ func main() {
 func TestExternalHttpCall(t *testing.T) {
 
 	tests := []struct {
-		name   string
-		code   string
-		expect string
+		name           string
+		code           string
+		expect         string
+		requestContext bool
 	}{
 		{
 			name: "no http do method",
@@ -848,57 +853,144 @@ func main() {
 }
 `,
 		},
+		{
+			name: "custom client do with request from function call",
+			code: `
+package main
+
+import "net/http"
+
+func main() {
+	client := &http.Client{}
+	client.Do(buildRequest())
+}
+
+func buildRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	return req
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	client := &http.Client{}
+	client.Do(newrelic.RequestWithTransactionContext(buildRequest(), txn))
+}
+
+func buildRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	return req
+}
+`,
+		},
+		{
+			name: "custom client do with request created with a transaction",
+			code: `
+package main
+
+import "net/http"
+
+func main() {
+	client := &http.Client{}
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	client.Do(req)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	client := &http.Client{}
+	req, _ := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), "GET", "http://example.com", nil)
+	client.Do(req)
+}
+`,
+			requestContext: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			defer panicRecovery(t)
-			got := testStatefulTracingFunction(t, tt.code, ExternalHttpCall, true)
+			var got string
+			if tt.requestContext {
+				got = testStatefulTracingFunction(t, tt.code, func(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+					HttpRequestWithTransactionContext(manager, stmt, c, tracing)
+					return ExternalHttpCall(manager, stmt, c, tracing)
+				}, true)
+			} else {
+				got = testStatefulTracingFunction(t, tt.code, ExternalHttpCall, true)
+			}
 			assert.Equal(t, tt.expect, got)
 		})
 	}
 }
 
-func TestWrapNestedHandleFunction(t *testing.T) {
+func TestHttpRequestWithTransactionContext(t *testing.T) {
 	tests := []struct {
 		name   string
 		code   string
 		expect string
 	}{
 		{
-			name: "trace nested handle function",
-			code: `
-package main
+			name: "new request with context parameter",
+			code: `package main
 
 import (
+	"context"
 	"net/http"
 )
 
-func main() {
-	http.HandleFunc("/", index)
+func fetch(ctx context.Context, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultClient.Do(req)
+	return err
+}
 `,
 			expect: `package main
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 )
 
-func main() { http.HandleFunc(newrelic.WrapHandleFunc(txn.Application(), "/", index)) }
+func fetch(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(newrelic.NewContext(ctx, txn), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = http.DefaultClient.Do(req)
+	return err
+}
 `,
 		},
 		{
-			name: "trace nested mux handle function",
-			code: `
-package main
+			name: "new request in handler",
+			code: `package main
 
 import (
 	"net/http"
 )
 
-func main() {
-	mux := http.NewServeMux()
-	mux.Handle("/", index)
+func handler(w http.ResponseWriter, r *http.Request) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	http.DefaultClient.Do(req)
 }
 `,
 			expect: `package main
@@ -909,70 +1001,97 @@ import (
 	"github.com/newrelic/go-agent/v3/newrelic"
 )
 
-func main() {
-	mux := http.NewServeMux()
-	mux.Handle(newrelic.WrapHandle(txn.Application(), "/", index))
+func handler(w http.ResponseWriter, r *http.Request) {
+	req, _ := http.NewRequestWithContext(newrelic.NewContext(r.Context(), txn), http.MethodGet, "http://example.com", nil)
+	http.DefaultClient.Do(req)
 }
 `,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			defer panicRecovery(t)
-			got := testStatefulTracingFunction(t, tt.code, WrapNestedHandleFunction, true)
-			assert.Equal(t, tt.expect, got)
-		})
-	}
+		{
+			name: "new request uses the nearest request in scope",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	s := r.WithContext(context.WithoutCancel(r.Context()))
+	req, _ := http.NewRequest(http.MethodGet, s.URL.String(), nil)
+	http.DefaultClient.Do(req)
 }
+`,
+			expect: `package main
 
-func TestCannotInstrumentHttpMethod(t *testing.T) {
+import (
+	"context"
+	"net/http"
 
-	tests := []struct {
-		name   string
-		code   string
-		expect string
-	}{
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	s := r.WithContext(context.WithoutCancel(r.Context()))
+	req, _ := http.NewRequestWithContext(newrelic.NewContext(s.Context(), txn), http.MethodGet, s.URL.String(), nil)
+	http.DefaultClient.Do(req)
+}
+`,
+		},
 		{
-			name: "http get",
+			name: "new request without context in scope",
 			code: `package main
 
-import "net/http"
+import (
+	"net/http"
+)
 
-func main() {
-	http.Get("http://example.com")
+func fetch(url string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	http.DefaultClient.Do(req)
 }
 `,
 			expect: `package main
 
-import "net/http"
+import (
+	"context"
+	"net/http"
 
-func main() {
-	// the "http.Get()" net/http method can not be instrumented and its outbound traffic can not be traced
-	// please see these examples of code patterns for external http calls that can be instrumented:
-	// https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests
-	http.Get("http://example.com")
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(url string) {
+	req, _ := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodGet, url, nil)
+	http.DefaultClient.Do(req)
 }
 `,
 		},
 		{
-			name: "http post",
+			name: "new request with context",
 			code: `package main
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
-func main() {
-	http.Post("http://example.com")
+func fetch(url string) {
+	req, _ := http.NewRequestWithContext(context.TODO(), http.MethodGet, url, nil)
+	http.DefaultClient.Do(req)
 }
 `,
 			expect: `package main
 
-import "net/http"
+import (
+	"context"
+	"net/http"
 
-func main() {
-	// the "http.Post()" net/http method can not be instrumented and its outbound traffic can not be traced
-	// please see these examples of code patterns for external http calls that can be instrumented:
-	// https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests
-	http.Post("http://example.com")
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(url string) {
+	req, _ := http.NewRequestWithContext(newrelic.NewContext(context.TODO(), txn), http.MethodGet, url, nil)
+	http.DefaultClient.Do(req)
 }
 `,
 		},
@@ -980,26 +1099,53 @@ func main() {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			defer panicRecovery(t)
-			got := testStatelessTracingFunction(t, tt.code, CannotInstrumentHttpMethod)
+			got := testStatefulTracingFunction(t, tt.code, HttpRequestWithTransactionContext, true)
 			assert.Equal(t, tt.expect, got)
 		})
 	}
 }
 
-func TestInstrumentHttpClient(t *testing.T) {
+func TestWrapNestedHandleFunction(t *testing.T) {
 	tests := []struct {
 		name   string
 		code   string
 		expect string
 	}{
 		{
-			name: "basic client definition",
-			code: `package main
+			name: "trace nested handle function",
+			code: `
+package main
 
-import "net/http"
+import (
+	"net/http"
+)
 
 func main() {
-	client := &http.Client{}
+	http.HandleFunc("/", index)
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() { http.HandleFunc(newrelic.WrapHandleFunc(txn.Application(), "/", index)) }
+`,
+		},
+		{
+			name: "trace nested mux handle function",
+			code: `
+package main
+
+import (
+	"net/http"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.Handle("/", index)
 }
 `,
 			expect: `package main
@@ -1011,23 +1157,25 @@ import (
 )
 
 func main() {
-	client := &http.Client{}
-	client.Transport = newrelic.NewRoundTripper(client.Transport)
+	mux := http.NewServeMux()
+	mux.Handle(newrelic.WrapHandle(txn.Application(), "/", index))
 }
 `,
 		},
 		{
-			name: "complex client definition",
-			code: `package main
+			name: "name transaction for method pattern with function literal",
+			code: `
+package main
 
-import "net/http"
+import (
+	"net/http"
+)
 
 func main() {
-	type clientInfo struct {
-		client *http.Client
-	}
-	info := clientInfo{}
-	info.client := &http.Client{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("id")))
+	})
 }
 `,
 			expect: `package main
@@ -1039,12 +1187,43 @@ import (
 )
 
 func main() {
-	type clientInfo struct {
-		client *http.Client
-	}
-	info := clientInfo{}
-	info.client := &http.Client{}
-	info.client.Transport = newrelic.NewRoundTripper(info.client.Transport)
+	mux := http.NewServeMux()
+	mux.HandleFunc(newrelic.WrapHandleFunc(txn.Application(), "GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		newrelic.FromContext(r.Context()).SetName("GET /items/{id}")
+		w.Write([]byte(r.PathValue("id")))
+	}))
+}
+`,
+		},
+		{
+			name: "pattern without method is not renamed",
+			code: `
+package main
+
+import (
+	"net/http"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("id")))
+	})
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc(newrelic.WrapHandleFunc(txn.Application(), "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("id")))
+	}))
 }
 `,
 		},
@@ -1052,12 +1231,1165 @@ func main() {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			defer panicRecovery(t)
-			got := testStatelessTracingFunction(t, tt.code, InstrumentHttpClient)
+			got := testStatefulTracingFunction(t, tt.code, WrapNestedHandleFunction, true)
 			assert.Equal(t, tt.expect, got)
 		})
 	}
 }
 
+func TestNameDeclaredHandlerTransaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "name transaction for method pattern with declared handler",
+			code: `
+package main
+
+import (
+	"net/http"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", item)
+}
+
+func item(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.PathValue("id")))
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "GET /items/{id}", item))
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+
+func item(w http.ResponseWriter, r *http.Request) {
+	newrelic.FromContext(r.Context()).SetName("GET /items/{id}")
+	w.Write([]byte(r.PathValue("id")))
+}
+`,
+		},
+		{
+			name: "handler registered for several patterns is not renamed",
+			code: `
+package main
+
+import (
+	"net/http"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", item)
+	mux.HandleFunc("GET /products/{id}", item)
+}
+
+func item(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.PathValue("id")))
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	mux := http.NewServeMux()
+	// NR INFO: transactions for the route "GET /items/{id}" will be named "GET GET /items/{id}"
+	// call SetName("GET /items/{id}") on the transaction in this handler to name it after the route pattern
+	mux.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "GET /items/{id}", item))
+	// NR INFO: transactions for the route "GET /products/{id}" will be named "GET GET /products/{id}"
+	// call SetName("GET /products/{id}") on the transaction in this handler to name it after the route pattern
+	mux.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "GET /products/{id}", item))
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+
+func item(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.PathValue("id")))
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentMain, WrapNestedHandleFunction)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func Test_parseServeMuxPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    *serveMuxPattern
+		wantOk  bool
+		txnName string
+	}{
+		{
+			name:    "path only",
+			pattern: "/",
+			want:    &serveMuxPattern{path: "/"},
+			wantOk:  true,
+			txnName: "/",
+		},
+		{
+			name:    "method and wildcard",
+			pattern: "GET /items/{id}",
+			want:    &serveMuxPattern{method: "GET", path: "/items/{id}", wildcards: []string{"id"}},
+			wantOk:  true,
+			txnName: "GET /items/{id}",
+		},
+		{
+			name:    "method, host and remainder wildcard",
+			pattern: "POST  example.com/files/{dir}/{path...}",
+			want:    &serveMuxPattern{method: "POST", host: "example.com", path: "/files/{dir}/{path...}", wildcards: []string{"dir", "path"}},
+			wantOk:  true,
+			txnName: "POST example.com/files/{dir}/{path...}",
+		},
+		{
+			name:    "end of path wildcard",
+			pattern: "GET /posts/{$}",
+			want:    &serveMuxPattern{method: "GET", path: "/posts/{$}"},
+			wantOk:  true,
+			txnName: "GET /posts/{$}",
+		},
+		{
+			name:    "missing path",
+			pattern: "GET",
+			wantOk:  false,
+		},
+		{
+			name:    "lowercase method",
+			pattern: "get /items",
+			wantOk:  false,
+		},
+		{
+			name:    "unterminated wildcard",
+			pattern: "/items/{id",
+			wantOk:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseServeMuxPattern(tt.pattern)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+			if ok {
+				assert.Equal(t, tt.txnName, got.transactionName())
+			}
+		})
+	}
+}
+
+func TestRecordPathValueAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "record path value",
+			code: `package main
+
+import "net/http"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Write([]byte(id))
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	txn.AddAttribute("id", id)
+	w.Write([]byte(id))
+}
+`,
+		},
+		{
+			name: "ignore discarded path value",
+			code: `package main
+
+import "net/http"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	_ = r.PathValue("id")
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	_ = r.PathValue("id")
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatefulTracingFunction(t, tt.code, RecordPathValueAttributes, true)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestRewriteHttpMethodCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "rewrite http get",
+			code: `package main
+
+import "net/http"
+
+func fetch(url string) error {
+	// fetch the url
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func fetch(url string) error {
+	// fetch the url
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodGet, url, nil)
+	if err == nil {
+		resp, err = nrHttpClient.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+`,
+		},
+		{
+			name: "rewrite http post with existing variables",
+			code: `package main
+
+import (
+	"io"
+	"net/http"
+)
+
+func send(url string, body io.Reader) (err error) {
+	var resp *http.Response
+	req := "unused"
+	resp, err = http.Post(url, "application/json", body)
+	if err != nil {
+		return err
+	}
+	_ = req
+	return resp.Body.Close()
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func send(url string, body io.Reader) (err error) {
+	var resp *http.Response
+	req := "unused"
+	var req2 *http.Request
+	req2, err = http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodPost, url, body)
+	if err == nil {
+		req2.Header.Set("Content-Type", "application/json")
+		resp, err = nrHttpClient.Do(req2)
+	}
+	if err != nil {
+		return err
+	}
+	_ = req
+	return resp.Body.Close()
+}
+`,
+		},
+		{
+			name: "rewrite http post form",
+			code: `package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+func submit(data url.Values) error {
+	_, err := http.PostForm("http://example.com", data)
+	return err
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func submit(data url.Values) error {
+	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodPost, "http://example.com", strings.NewReader(data.Encode()))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		_, err = nrHttpClient.Do(req)
+	}
+	return err
+}
+`,
+		},
+		{
+			name: "request variable does not shadow a variable of an outer scope",
+			code: `package main
+
+import "net/http"
+
+func fetch(url string, req int) error {
+	for i := 0; i < req; i++ {
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func fetch(url string, req int) error {
+	for i := 0; i < req; i++ {
+		var resp *http.Response
+		req2, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodGet, url, nil)
+		if err == nil {
+			resp, err = nrHttpClient.Do(req2)
+		}
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+`,
+		},
+		{
+			name: "rewritten calls share one client",
+			code: `package main
+
+import "net/http"
+
+func fetch(a, b string) error {
+	_, err := http.Get(a)
+	if err != nil {
+		return err
+	}
+	_, err = http.Head(b)
+	return err
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func fetch(a, b string) error {
+	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodGet, a, nil)
+	if err == nil {
+		_, err = nrHttpClient.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	var req2 *http.Request
+	req2, err = http.NewRequestWithContext(newrelic.NewContext(context.Background(), txn), http.MethodHead, b, nil)
+	if err == nil {
+		_, err = nrHttpClient.Do(req2)
+	}
+	return err
+}
+`,
+		},
+		{
+			name: "rewritten requests in a handler use the context of the incoming request",
+			code: `package main
+
+import "net/http"
+
+func index(w http.ResponseWriter, r *http.Request) {
+	resp, err := http.Get("https://example.com")
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(newrelic.NewContext(r.Context(), txn), http.MethodGet, "https://example.com", nil)
+	if err == nil {
+		resp, err = nrHttpClient.Do(req)
+	}
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+`,
+		},
+		{
+			name: "ignored error is not rewritten",
+			code: `package main
+
+import "net/http"
+
+func fetch(url string) {
+	http.Head(url)
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+func fetch(url string) {
+	http.Head(url)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatefulTracingFunction(t, tt.code, RewriteHttpMethodCall, true)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestCannotInstrumentHttpMethod(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "http get",
+			code: `package main
+
+import "net/http"
+
+func main() {
+	http.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+func main() {
+	// the "http.Get()" net/http method can not be instrumented and its outbound traffic can not be traced
+	// please see these examples of code patterns for external http calls that can be instrumented:
+	// https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests
+	http.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "http post",
+			code: `package main
+
+import "net/http"
+
+func main() {
+	http.Post("http://example.com")
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+func main() {
+	// the "http.Post()" net/http method can not be instrumented and its outbound traffic can not be traced
+	// please see these examples of code patterns for external http calls that can be instrumented:
+	// https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests
+	http.Post("http://example.com")
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, CannotInstrumentHttpMethod)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentHttpClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "basic client definition",
+			code: `package main
+
+import "net/http"
+
+func main() {
+	client := &http.Client{}
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	client := &http.Client{}
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+}
+`,
+		},
+		{
+			name: "complex client definition",
+			code: `package main
+
+import "net/http"
+
+func main() {
+	type clientInfo struct {
+		client *http.Client
+	}
+	info := clientInfo{}
+	info.client := &http.Client{}
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	type clientInfo struct {
+		client *http.Client
+	}
+	info := clientInfo{}
+	info.client := &http.Client{}
+	info.client.Transport = newrelic.NewRoundTripper(info.client.Transport)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentHttpClient)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentPackageHttpClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "package level client",
+			code: `package main
+
+import (
+	"net/http"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+}
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "package level clients with custom transport",
+			code: `package main
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+var (
+	secureClient = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{}},
+	}
+	plainClient http.Client
+	timeout     = 10
+)
+
+func main() {
+	secureClient.Get("https://example.com")
+	plainClient.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var (
+	secureClient = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{}},
+	}
+	plainClient http.Client
+	timeout     = 10
+)
+
+func init() {
+	secureClient.Transport = newrelic.NewRoundTripper(secureClient.Transport)
+	plainClient.Transport = newrelic.NewRoundTripper(plainClient.Transport)
+}
+
+func main() {
+	secureClient.Get("https://example.com")
+	plainClient.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "package level clients declared separately share one init function",
+			code: `package main
+
+import (
+	"net/http"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+const retries = 3
+
+var backupClient = &http.Client{}
+
+func main() {
+	client.Get("http://example.com")
+	backupClient.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+	backupClient.Transport = newrelic.NewRoundTripper(backupClient.Transport)
+}
+
+const retries = 3
+
+var backupClient = &http.Client{}
+
+func main() {
+	client.Get("http://example.com")
+	backupClient.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "package level default client is not wrapped again",
+			code: `package main
+
+import "net/http"
+
+var client = http.DefaultClient
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import "net/http"
+
+var client = http.DefaultClient
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "package level client already instrumented",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var client = &http.Client{}
+
+func init() {
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+}
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var client = &http.Client{}
+
+func init() {
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+}
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentPackageHttpClient)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentDefaultHttpClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "default client is instrumented in main",
+			code: `package main
+
+import "net/http"
+
+func main() {
+	http.DefaultClient.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+
+	http.DefaultClient.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "default client assigned to a package level variable",
+			code: `package main
+
+import "net/http"
+
+var client = http.DefaultClient
+
+func main() {
+	client.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var client = http.DefaultClient
+
+func main() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+
+	client.Get("http://example.com")
+}
+`,
+		},
+		{
+			name: "default client already instrumented",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+	http.DefaultClient.Get("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+	http.DefaultClient.Get("http://example.com")
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			pkg := manager.getDecoratorPackage()
+			if pkg == nil {
+				t.Fatalf("Package was nil: %+v", manager.packages)
+			}
+
+			manager.loadPreInstrumentationTracingFunctions(DetectDefaultHttpClient)
+			assert.NoError(t, manager.ScanApplication())
+			assert.True(t, manager.defaultHttpClientUsed)
+
+			instrumentDefaultHttpClient(manager, pkg.Syntax[0].Decls[len(pkg.Syntax[0].Decls)-1].(*dst.FuncDecl), 0)
+
+			restorer := decorator.NewRestorerWithImports(testDir, guess.New())
+			buf := bytes.NewBuffer([]byte{})
+			assert.NoError(t, restorer.Fprint(buf, pkg.Syntax[0]))
+			assert.Equal(t, tt.expect, buf.String())
+		})
+	}
+}
+
+func TestExternalHttpCallDefaultClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "default client without instrumented transport gets external segment",
+			code: `package main
+
+import "net/http"
+
+func fetch(req *http.Request) {
+	http.DefaultClient.Do(req)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(req *http.Request) {
+	externalSegment := newrelic.StartExternalSegment(txn, req)
+	http.DefaultClient.Do(req)
+	externalSegment.End()
+}
+`,
+		},
+		{
+			name: "default client instrumented in main gets transaction in request context",
+			code: `package main
+
+import "net/http"
+
+func fetch(req *http.Request) {
+	http.DefaultClient.Do(req)
+}
+
+func main() {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	fetch(req)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(req *http.Request) {
+	req = newrelic.RequestWithTransactionContext(req, txn)
+	http.DefaultClient.Do(req)
+}
+
+func main() {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	fetch(req)
+}
+`,
+		},
+		{
+			name: "default client with instrumented transport gets transaction in request context",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(req *http.Request) {
+	http.DefaultClient.Do(req)
+}
+
+func init() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(req *http.Request) {
+	req = newrelic.RequestWithTransactionContext(req, txn)
+	http.DefaultClient.Do(req)
+}
+
+func init() {
+	http.DefaultClient.Transport = newrelic.NewRoundTripper(http.DefaultClient.Transport)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			pkg := manager.getDecoratorPackage()
+			if pkg == nil {
+				t.Fatalf("Package was nil: %+v", manager.packages)
+			}
+
+			manager.loadPreInstrumentationTracingFunctions(DetectDefaultHttpClient)
+			assert.NoError(t, manager.ScanApplication())
+
+			tracingState := tracestate.FunctionBody("txn")
+			dstutil.Apply(pkg.Syntax[0].Decls[1], nil, func(c *dstutil.Cursor) bool {
+				if stmt, ok := c.Node().(dst.Stmt); ok {
+					ExternalHttpCall(manager, stmt, c, tracingState)
+				}
+				return true
+			})
+
+			restorer := decorator.NewRestorerWithImports(testDir, guess.New())
+			buf := bytes.NewBuffer([]byte{})
+			assert.NoError(t, restorer.Fprint(buf, pkg.Syntax[0]))
+			assert.Equal(t, tt.expect, buf.String())
+		})
+	}
+}
+
+func TestPackageInstrumentsClientTransport(t *testing.T) {
+	client := `package main
+
+import "net/http"
+
+var client = &http.Client{}
+`
+	setup := `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func init() {
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+}
+`
+	pkg := &decorator.Package{}
+	dec := decorator.NewDecoratorWithImports(token.NewFileSet(), "main", goast.New())
+	for _, code := range []string{client, setup} {
+		file, err := dec.Parse(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Syntax = append(pkg.Syntax, file)
+	}
+
+	assert.True(t, packageInstrumentsClientTransport(pkg, "client"))
+	assert.False(t, packageInstrumentsClientTransport(pkg, "other"))
+}
+
 func TestInstrumentHandleFunction(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	// panicErrorClass is the class of detailed errors noticed for recovered panics.
+	panicErrorClass = "panic"
+)
+
+// isBuiltinCall returns true if an expression is a call to the builtin function with the given name.
+func isBuiltinCall(expr dst.Expr, name string, pkg *decorator.Package) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != name || ident.Path != "" {
+		return false
+	}
+	_, ok = util.ObjectOf(ident, pkg).(*types.Builtin)
+	return ok
+}
+
+// isRecoverAssignment returns the variable that the value returned by recover() is assigned to, or nil if the statement
+// does not assign it.
+func isRecoverAssignment(stmt dst.Stmt, pkg *decorator.Package) *dst.Ident {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || !isBuiltinCall(assign.Rhs[0], "recover", pkg) {
+		return nil
+	}
+	ident, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok || ident.Name == "_" {
+		return nil
+	}
+	return ident
+}
+
+// recoveredValue returns the value returned by recover() if an if statement checks that a panic was recovered:
+//
+//	if r := recover(); r != nil {
+//
+// The value may also be assigned by the statement before the if statement in the same block. Returns nil if the if
+// statement does not check a recovered value.
+func recoveredValue(ifStmt *dst.IfStmt, c *dstutil.Cursor, pkg *decorator.Package) *dst.Ident {
+	cond, ok := ifStmt.Cond.(*dst.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return nil
+	}
+	checked, ok := cond.X.(*dst.Ident)
+	if nilIdent, isIdent := cond.Y.(*dst.Ident); !ok || !isIdent || nilIdent.Name != "nil" {
+		return nil
+	}
+
+	recovered := isRecoverAssignment(ifStmt.Init, pkg)
+	if recovered == nil && ifStmt.Init == nil && c.Index() > 0 {
+		if block, ok := c.Parent().(*dst.BlockStmt); ok {
+			recovered = isRecoverAssignment(block.List[c.Index()-1], pkg)
+		}
+	}
+	if recovered == nil || recovered.Name != checked.Name {
+		return nil
+	}
+	return recovered
+}
+
+// containsBuiltinCall returns true if a node contains a call to the builtin function with the given name.
+// Calls in function literals that are not deferred are not counted, since they are not run by the node.
+func containsBuiltinCall(node dst.Node, name string, pkg *decorator.Package) bool {
+	found := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.FuncLit:
+			return false
+		case *dst.DeferStmt:
+			if lit, ok := v.Call.Fun.(*dst.FuncLit); ok {
+				found = found || containsBuiltinCall(lit.Body, name, pkg)
+				return false
+			}
+		case dst.Expr:
+			if isBuiltinCall(v, name, pkg) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// noticeRecoveredPanic notices the value of a recovered panic as an error on the transaction:
+//
+//	if r := recover(); r != nil {
+//		nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+//		log.Println("recovered", r)
+//	}
+//
+// When panics are captured, a recovered panic that is panicked again is left for the deferred end of the transaction to capture.
+// Returns true if the panic was noticed.
+func noticeRecoveredPanic(manager *InstrumentationManager, ifStmt *dst.IfStmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	pkg := manager.getDecoratorPackage()
+	recovered := recoveredValue(ifStmt, c, pkg)
+	if recovered == nil || ifStmt.Body == nil {
+		return false
+	}
+	if manager.options.CapturePanics && !tracing.RecoverPanics() && containsBuiltinCall(ifStmt.Body, "panic", pkg) {
+		return false
+	}
+
+	comment.Debug(pkg, ifStmt, "Injecting NoticeError for a recovered panic")
+	details := []codegen.ErrorDetails{}
+	if manager.options.DetailedErrors {
+		details = append(details, codegen.ErrorDetails{Class: panicErrorClass, Function: tracing.FunctionName()})
+	}
+	ifStmt.Body.List = append([]dst.Stmt{codegen.NoticeError(codegen.RecoveredError(recovered), tracing.TransactionVariable(), nil, details...)}, ifStmt.Body.List...)
+	return true
+}
+
+// recoverPanics adds a deferred recover that notices a panic on the transaction and then re-panics it to a function whose
+// transaction is not ended by a deferred End in the same goroutine, if the function does not already recover panics. This is
+// the case for functions called with a transaction started in main, and for functions run in a new goroutine:
+//
+//	func process(nrTxn *newrelic.Transaction) {
+//		defer nrTxn.StartSegment("process").End()
+//		defer func() {
+//			if r := recover(); r != nil {
+//				nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+//				panic(r)
+//			}
+//		}()
+//
+// Transactions ended by a deferred call to End record panics themselves when panics are captured. A function is only traced
+// by the first call to it that is found, so functions that are also called with a transaction that does not record its
+// panics are passed to recoverPanicsOfCaller by the later calls. Returns true if a deferred recover was added.
+func recoverPanics(manager *InstrumentationManager, node dst.Node, tracing *tracestate.State) bool {
+	pkg := manager.getDecoratorPackage()
+	if !manager.options.CapturePanics || !tracing.RecoverPanics() {
+		return false
+	}
+
+	switch fn := node.(type) {
+	case *dst.FuncDecl:
+		if fn.Body == nil || containsBuiltinCall(fn.Body, "recover", pkg) {
+			return false
+		}
+		comment.Debug(pkg, fn, fmt.Sprintf("Recovering panics in function: %s", fn.Name.Name))
+		codegen.PrependStatementToFunctionDecl(fn, codegen.DeferRecoverNoticePanic(tracing.TransactionVariable()))
+	case *dst.FuncLit:
+		if fn.Body == nil || containsBuiltinCall(fn.Body, "recover", pkg) {
+			return false
+		}
+		codegen.PrependStatementToFunctionLit(fn, codegen.DeferRecoverNoticePanic(tracing.TransactionVariable()))
+	default:
+		return false
+	}
+	return true
+}
+
+// recoverPanicsOfCaller adds a deferred recover to a function declaration that was already traced for another call, if this
+// call is made with a transaction that does not record its panics. The recover is added after the statements generated
+// when the function was traced, so that the transaction is in scope. Panics of calls made with a transaction that records
+// them may then be noticed twice, which is preferred over not noticing the panics of this call at all.
+func (m *InstrumentationManager) recoverPanicsOfCaller(inv *invocationInfo, tracing *tracestate.State) {
+	if !m.options.CapturePanics || inv.decl == nil || inv.decl.Body == nil || !tracing.RecoverPanics() {
+		return
+	}
+	state, ok := m.packages[inv.packageName]
+	if !ok || state.tracedFuncs[functionKey(inv.decl)] == nil || !state.tracedFuncs[functionKey(inv.decl)].traced {
+		return
+	}
+	if containsBuiltinCall(inv.decl.Body, "recover", state.pkg) {
+		return
+	}
+
+	index := 0
+	for index < len(inv.decl.Body.List) && state.generated.isStatement(inv.decl.Body.List[index]) {
+		index++
+	}
+	comment.Debug(state.pkg, inv.decl, fmt.Sprintf("Recovering panics in function: %s", inv.decl.Name.Name))
+	inv.decl.Body.List = slices.Insert(inv.decl.Body.List, index, dst.Stmt(codegen.DeferRecoverNoticePanic(tracing.TransactionVariable())))
+}
@@ -0,0 +1,661 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/dave/dst/dstutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapturePanics(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        string
+		options     Options
+		tracingFunc StatelessTracingFunction
+		expect      string
+	}{
+		{
+			name: "notice recovered panics",
+			code: `package main
+
+import "log"
+
+func process(id int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("recovered", r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	process(1)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			log.Println("recovered", r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "comments on the first statement of a recovered panic are kept",
+			code: `package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func process(id int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered: %v", r)
+		}
+	}()
+	log.Println(id)
+	return nil
+}
+
+func main() {
+	process(1)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) (err error) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			// NR WARN: Unchecked Error, please consult New Relic documentation on error capture
+			// https://docs.newrelic.com/docs/apm/agents/go-agent/api-guides/guide-using-go-agent-api/#errors
+			err = fmt.Errorf("recovered: %v", r)
+		}
+	}()
+	log.Println(id)
+	return nil
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "notice panics recovered before the if statement",
+			code: `package main
+
+import "log"
+
+func process(id int) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			log.Println("recovered", r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	process(1)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			log.Println("recovered", r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:    "recover panics in functions called with a transaction started in main",
+			options: Options{CapturePanics: true},
+			code: `package main
+
+import "errors"
+
+func validate(id int) {
+	if id < 0 {
+		panic(errors.New("negative id"))
+	}
+}
+
+func main() {
+	validate(1)
+}
+`,
+			expect: `package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func validate(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("validate").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	if id < 0 {
+		panic(errors.New("negative id"))
+	}
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment(), func(cfg *newrelic.Config) {
+		cfg.ErrorCollector.RecordPanics = true
+	})
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("validate")
+	validate(1, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:    "re-panicked values are noticed when the transaction is not ended by a deferred End",
+			options: Options{CapturePanics: true},
+			code: `package main
+
+import "log"
+
+func process(id int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("recovered", r)
+			panic(r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	process(1)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			log.Println("recovered", r)
+			panic(r)
+		}
+	}()
+	log.Println(id)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment(), func(cfg *newrelic.Config) {
+		cfg.ErrorCollector.RecordPanics = true
+	})
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:    "recover panics in functions run in a new goroutine",
+			options: Options{CapturePanics: true},
+			code: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func work(id int) {
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	go work(1)
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+			tracingFunc: InstrumentHandleFunction,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("async work").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	go work(1, nrTxn.NewGoroutine())
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+		},
+		{
+			name:        "re-panicked values are left for the deferred end of the transaction",
+			options:     Options{CapturePanics: true},
+			tracingFunc: InstrumentHandleFunction,
+			code: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func index(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println("recovered", err)
+			panic(err)
+		}
+	}()
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+			expect: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func index(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println("recovered", err)
+			panic(err)
+		}
+	}()
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			tracingFunc := tt.tracingFunc
+			if tracingFunc == nil {
+				tracingFunc = InstrumentMain
+			}
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, tt.options, tracingFunc)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestCapturePanicsInFunctionsCalledByHandlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "main is declared before the handler",
+			code: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func process(id int) {
+	log.Println(id)
+}
+
+func main() {
+	process(1)
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	process(2)
+	w.Write([]byte("hello"))
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	log.Println(id)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment(), func(cfg *newrelic.Config) {
+		cfg.ErrorCollector.RecordPanics = true
+	})
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	process(2, nrTxn)
+	w.Write([]byte("hello"))
+}
+`,
+		},
+		{
+			name: "the handler is declared before main",
+			code: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func process(id int) {
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	process(2)
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	process(1)
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			nrTxn.NoticeError(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	process(2, nrTxn)
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment(), func(cfg *newrelic.Config) {
+		cfg.ErrorCollector.RecordPanics = true
+	})
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(1, nrTxn)
+	nrTxn.End()
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "functions only called by handlers are not changed",
+			code: `package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func process(id int) {
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	process(2)
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+			expect: `package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func process(id int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("process").End()
+
+	log.Println(id)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	process(2, nrTxn)
+	w.Write([]byte("hello"))
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment(), func(cfg *newrelic.Config) {
+		cfg.ErrorCollector.RecordPanics = true
+	})
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	// panics in functions that are also called with a transaction started in main are not recorded by the end of
+	// that transaction, so they are recovered no matter which call is traced first
+	tracingFunc := func(manager *InstrumentationManager, c *dstutil.Cursor) {
+		InstrumentHandleFunction(manager, c)
+		InstrumentMain(manager, c)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, Options{CapturePanics: true}, tracingFunc)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
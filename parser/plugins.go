@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/newrelic/go-easy-instrumentation/plugin"
+)
+
+// pluginManager exposes the instrumentation manager to the tracing functions of plugins through the plugin.Manager interface.
+type pluginManager struct {
+	manager     *InstrumentationManager
+	integration string // the name of the integration of the plugin
+}
+
+func (p pluginManager) AddImport(path string) {
+	p.manager.addImport(path)
+}
+
+func (p pluginManager) Package() *decorator.Package {
+	return p.manager.getDecoratorPackage()
+}
+
+func (p pluginManager) TraceFunction(node dst.Node, tracing *tracestate.State) (dst.Node, bool) {
+	return TraceFunction(p.manager, node, tracing)
+}
+
+func (p pluginManager) Comment(node dst.Node, message string, additionalInfo ...string) {
+	comment.Warn(p.manager.getDecoratorPackage(), pluginRule(p.integration), node, node, message, additionalInfo...)
+}
+
+// pluginIntegration converts an integration registered by a plugin into an integration that can be loaded by the manager.
+func pluginIntegration(p plugin.Integration) integration {
+	i := integration{
+		name:        p.Name,
+		library:     p.Library,
+		description: p.Description,
+	}
+	for _, fn := range p.Stateless {
+		i.stateless = append(i.stateless, func(manager *InstrumentationManager, c *dstutil.Cursor) {
+			fn(pluginManager{manager, p.Name}, c)
+		})
+	}
+	for _, fn := range p.Stateful {
+		i.stateful = append(i.stateful, func(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+			return fn(pluginManager{manager, p.Name}, stmt, c, tracing)
+		})
+	}
+	return i
+}
+
+// allIntegrations returns the integrations of the tool followed by the integrations registered by plugins.
+func allIntegrations() []integration {
+	all := slices.Clone(integrations)
+	for _, p := range plugin.Integrations() {
+		all = append(all, pluginIntegration(p))
+	}
+	return all
+}
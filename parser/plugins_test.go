@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/newrelic/go-easy-instrumentation/plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+// commentOnCalls is a plugin stateful tracing function that comments on every call to a function named "work"
+// that is made with a transaction.
+func commentOnCalls(manager plugin.Manager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	expr, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	if ident, ok := call.Fun.(*dst.Ident); ok && ident.Name == "work" {
+		manager.Comment(stmt, "work is called in "+manager.Package().Name)
+		return true
+	}
+	return false
+}
+
+// traceHandlers is a plugin stateless tracing function that traces functions named "handler" with the transaction
+// passed to them.
+func traceHandlers(manager plugin.Manager, c *dstutil.Cursor) {
+	decl, ok := c.Node().(*dst.FuncDecl)
+	if !ok || decl.Name.Name != "handler" {
+		return
+	}
+	if _, ok := manager.TraceFunction(decl, tracestate.FunctionBody("nrTxn")); ok {
+		manager.AddImport("github.com/newrelic/go-agent/v3/newrelic")
+	}
+}
+
+func TestPluginIntegration(t *testing.T) {
+	i := pluginIntegration(plugin.Integration{
+		Name:        "work",
+		Library:     "example.com/work",
+		Description: "traces work",
+		Stateless:   []plugin.StatelessTracingFunction{traceHandlers},
+		Stateful:    []plugin.StatefulTracingFunction{commentOnCalls},
+	})
+	assert.Equal(t, "work", i.name)
+	assert.Equal(t, "example.com/work", i.library)
+	assert.Equal(t, "traces work", i.description)
+
+	tests := []struct {
+		name     string
+		code     string
+		function StatelessTracingFunction
+		expect   string
+	}{
+		{
+			name: "stateful tracing functions are applied to functions called with a transaction",
+			code: `package main
+
+func work() {}
+
+func step() {}
+
+func main() {
+	step()
+	work()
+}
+`,
+			function: InstrumentMain,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("work").End()
+
+}
+
+func step(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("step").End()
+
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("step")
+	step(nrTxn)
+	nrTxn.End()
+	nrTxn = NewRelicAgent.StartTransaction("work") // NR WARN: work is called in main
+	work(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "stateless tracing functions can trace functions",
+			code: `package main
+
+import "github.com/newrelic/go-agent/v3/newrelic"
+
+func work() {}
+
+func step() {}
+
+func handler(nrTxn *newrelic.Transaction) {
+	work()
+	step()
+}
+
+func main() {}
+`,
+			function: i.stateless[0],
+			expect: `package main
+
+import "github.com/newrelic/go-agent/v3/newrelic"
+
+func work(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("work").End()
+
+}
+
+func step(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("step").End()
+
+}
+
+func handler(nrTxn *newrelic.Transaction) {
+	// NR WARN: work is called in main
+	work(nrTxn)
+	step(nrTxn)
+}
+
+func main() {}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, tt.function, i.stateful...)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestValidateIntegrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		all     []integration
+		wantErr bool
+	}{
+		{
+			name:    "integrations registered by plugins can be selected",
+			options: Options{Integrations: []string{"work"}},
+			all:     append(allIntegrations(), integration{name: "work"}),
+		},
+		{
+			name:    "plugins can not register an integration with the name of another integration",
+			all:     append(allIntegrations(), integration{name: "gin"}),
+			wantErr: true,
+		},
+		{
+			name:    "plugins can not register an integration named core",
+			all:     append(allIntegrations(), integration{name: "core"}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIntegrations(tt.options, tt.all)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// unsupportedFrameworks are the import paths of libraries that applications commonly use to serve or send requests,
+// access data, or write logs, that no integration of this tool instruments. The Go agent has integrations for most of
+// them that can be added by hand.
+var unsupportedFrameworks = []string{
+	"database/sql",
+	"github.com/99designs/gqlgen",
+	"github.com/aws/aws-lambda-go",
+	"github.com/aws/aws-sdk-go",
+	"github.com/aws/aws-sdk-go-v2",
+	"github.com/go-redis/redis",
+	"github.com/gofiber/fiber",
+	"github.com/gorilla/mux",
+	"github.com/graph-gophers/graphql-go",
+	"github.com/graphql-go/graphql",
+	"github.com/IBM/sarama",
+	"github.com/jackc/pgx",
+	"github.com/julienschmidt/httprouter",
+	"github.com/labstack/echo",
+	"github.com/nats-io/nats.go",
+	"github.com/redis/go-redis",
+	"github.com/rs/zerolog",
+	"github.com/sirupsen/logrus",
+	"github.com/valyala/fasthttp",
+	"go.mongodb.org/mongo-driver",
+	"go.uber.org/zap",
+}
+
+// entryPointCalls are the functions that register or create the entry points of an application, by the import path of
+// their package and their name, and the kind of entry point they create.
+var entryPointCalls = []struct {
+	path, name, kind string
+}{
+	{codegen.HttpImportPath, "Handle", "http handler"},
+	{codegen.HttpImportPath, "HandleFunc", "http handler"},
+	{codegen.GrpcImportPath, "NewServer", "grpc server"},
+	{codegen.GinImportPath, "Default", "gin router"},
+	{codegen.GinImportPath, "New", "gin router"},
+	{"github.com/go-chi/chi/v5", "NewRouter", "chi router"},
+}
+
+// Readiness describes how much of an application can be instrumented, without changing it.
+type Readiness struct {
+	Frameworks         []Framework       // the libraries the application imports that integrations instrument, or that are not supported
+	EntryPoints        []EntryPoint      // where transactions can be started
+	DeclaredFunctions  int               // the number of functions and methods declared in the application
+	ReachableFunctions []Function        // the functions that a transaction is passed to, and that are traced
+	OutboundCalls      []comment.Finding // the outbound calls that can not be instrumented
+	SkippedGoroutines  []comment.Finding // the goroutines that are not traced
+}
+
+// Framework is a library that the application imports.
+type Framework struct {
+	Library      string   // the import path of the library
+	Integrations []string // the integrations that instrument it, which is empty if it is not supported
+	Disabled     bool     // whether all of its integrations are disabled
+}
+
+// Status returns whether a library is supported, supported but disabled, or unsupported.
+func (f Framework) Status() string {
+	switch {
+	case len(f.Integrations) == 0:
+		return "unsupported"
+	case f.Disabled:
+		return "disabled"
+	}
+	return "supported"
+}
+
+// EntryPoint is a place in the application where a transaction can be started, like main or an http handler.
+type EntryPoint struct {
+	Kind     string // main, http handler, grpc server, gin router or chi router
+	Name     string // the function, route or call of the entry point
+	Position string // the file relative to the application, and the line, like main.go:12
+}
+
+// Function is a function or method declared in the application.
+type Function struct {
+	Name     string // the name of the package and the function, like store.Store.Load
+	Position string // the file relative to the application, and the line, like store/store.go:40
+}
+
+// Scan finds how ready the application is to be instrumented. The facts of the application are discovered and it is
+// scanned and traced like it is by the instrumentation, but the changes are never written.
+func (m *InstrumentationManager) Scan() (*Readiness, error) {
+	if err := comment.EnableReport(m.userAppPath); err != nil {
+		return nil, err
+	}
+	for _, step := range []func() error{m.DetectDependencyIntegrations, m.TracePackageCalls, m.ScanApplication} {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+
+	// entry points are found before the dry tracing pass changes the calls that register them
+	readiness := &Readiness{EntryPoints: m.entryPoints()}
+	frameworks, err := m.frameworks()
+	if err != nil {
+		return nil, err
+	}
+	readiness.Frameworks = frameworks
+
+	if err := m.InstrumentApplication(); err != nil {
+		return nil, err
+	}
+	readiness.DeclaredFunctions, readiness.ReachableFunctions = m.reachableFunctions()
+	for _, finding := range comment.Findings() {
+		switch finding.RuleID {
+		case httpMethodRule.ID, unassignedHttpErrorRule.ID:
+			readiness.OutboundCalls = append(readiness.OutboundCalls, finding)
+		case goroutineInMainRule.ID:
+			readiness.SkippedGoroutines = append(readiness.SkippedGoroutines, finding)
+		}
+	}
+	return readiness, nil
+}
+
+// frameworks returns the libraries imported by the application that an integration instruments, or that are known to
+// not be supported, sorted by import path.
+func (m *InstrumentationManager) frameworks() ([]Framework, error) {
+	all := allIntegrations()
+	for _, rule := range m.options.Rules {
+		i, err := ruleIntegration(rule)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, i)
+	}
+
+	imported := map[string]bool{}
+	for _, state := range m.packages {
+		if util.IsTestPackage(state.pkg) {
+			continue
+		}
+		for path := range state.pkg.Imports {
+			imported[path] = true
+		}
+	}
+	// isImported returns true if a library, or one of its packages, is imported
+	isImported := func(library string) bool {
+		for path := range imported {
+			if path == library || strings.HasPrefix(path, library+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	frameworks := map[string]*Framework{}
+	for _, i := range all {
+		if i.library == "" || !isImported(i.library) {
+			continue
+		}
+		f, ok := frameworks[i.library]
+		if !ok {
+			f = &Framework{Library: i.library, Disabled: true}
+			frameworks[i.library] = f
+		}
+		f.Integrations = append(f.Integrations, i.name)
+		f.Disabled = f.Disabled && !m.integrationEnabled(i.name)
+	}
+	for _, library := range unsupportedFrameworks {
+		if _, ok := frameworks[library]; !ok && isImported(library) {
+			frameworks[library] = &Framework{Library: library}
+		}
+	}
+
+	result := make([]Framework, 0, len(frameworks))
+	for _, f := range frameworks {
+		result = append(result, *f)
+	}
+	slices.SortFunc(result, func(a, b Framework) int { return cmp.Compare(a.Library, b.Library) })
+	return result, nil
+}
+
+// entryPoints returns the main functions of the application, and the calls that register http handlers or create
+// grpc servers and routers, in the order of their position.
+func (m *InstrumentationManager) entryPoints() []EntryPoint {
+	var entryPoints []EntryPoint
+	for _, id := range m.getSortedPackages() {
+		pkg := m.packages[id].pkg
+		if util.IsTestPackage(pkg) {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			if util.IsGenerated(pkg.Decorator, file) {
+				continue
+			}
+			dst.Inspect(file, func(n dst.Node) bool {
+				switch v := n.(type) {
+				case *dst.FuncDecl:
+					if pkg.Name == "main" && v.Name.Name == "main" && v.Recv == nil {
+						entryPoints = append(entryPoints, EntryPoint{Kind: "main", Name: "main", Position: m.relativePosition(pkg, v)})
+					}
+				case *dst.CallExpr:
+					if kind, ok := entryPointKind(v, pkg); ok {
+						entryPoints = append(entryPoints, EntryPoint{Kind: kind, Name: entryPointName(v, pkg), Position: m.relativePosition(pkg, v)})
+					}
+				}
+				return true
+			})
+		}
+	}
+	return entryPoints
+}
+
+// entryPointKind returns the kind of entry point that a call registers or creates, if it does.
+func entryPointKind(call *dst.CallExpr, pkg *decorator.Package) (string, bool) {
+	for _, entryPoint := range entryPointCalls {
+		if isFunctionCall(call, entryPoint.path, entryPoint.name) {
+			return entryPoint.kind, true
+		}
+	}
+
+	// mux.Handle("/", handler) and mux.HandleFunc("/", handler)
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+		return "", false
+	}
+	t := util.TypeOf(sel.X, pkg)
+	if t == nil || strings.TrimPrefix(t.String(), "*") != codegen.HttpImportPath+".ServeMux" {
+		return "", false
+	}
+	return "http handler", true
+}
+
+// entryPointName describes an entry point by the route and the handler that is registered, or by the call that
+// creates it.
+func entryPointName(call *dst.CallExpr, pkg *decorator.Package) string {
+	if len(call.Args) != 2 {
+		return util.WriteExpr(call, pkg)
+	}
+	route := util.WriteExpr(call.Args[0], pkg)
+	if lit, ok := call.Args[0].(*dst.BasicLit); ok && lit.Kind == token.STRING {
+		if value, err := strconv.Unquote(lit.Value); err == nil {
+			route = value
+		}
+	}
+	return fmt.Sprintf("%s %s", route, util.WriteExpr(call.Args[1], pkg))
+}
+
+// reachableFunctions returns the number of functions declared in the application, and the functions that were traced,
+// or that were already traced by a previous run, sorted by name.
+func (m *InstrumentationManager) reachableFunctions() (int, []Function) {
+	declared := 0
+	var reachable []Function
+	for _, id := range m.getSortedPackages() {
+		state := m.packages[id]
+		declared += len(state.tracedFuncs)
+		for key, fn := range state.tracedFuncs {
+			if fn.traced || isTracedFunction(fn.body.Body) {
+				reachable = append(reachable, Function{Name: state.pkg.Name + "." + key, Position: m.relativePosition(state.pkg, fn.body)})
+			}
+		}
+	}
+	slices.SortFunc(reachable, func(a, b Function) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Position, b.Position))
+	})
+	return declared, reachable
+}
+
+// relativePosition returns the file of a node, relative to the application, and its line, like main.go:12.
+func (m *InstrumentationManager) relativePosition(pkg *decorator.Package, node dst.Node) string {
+	pos := util.Position(node, pkg)
+	if pos == nil || !pos.IsValid() {
+		return ""
+	}
+	name := pos.Filename
+	if appPath, err := filepath.Abs(m.userAppPath); err == nil {
+		if rel, err := filepath.Rel(appPath, pos.Filename); err == nil {
+			name = rel
+		}
+	}
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(name), pos.Line)
+}
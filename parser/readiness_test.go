@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	code := `package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+)
+
+func fetch() error {
+	resp, err := http.Get("https://example.com")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	fetch()
+}
+
+func unused() {}
+
+func background() {}
+
+func main() {
+	sql.Drivers()
+	slog.Info("starting")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", index)
+	go background()
+	http.ListenAndServe(":8080", mux)
+}
+`
+	defer panicRecovery(t)
+	id, err := pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	defer cleanTestApp(t, testDir)
+
+	manager := testInstrumentationManager(t, code, testDir)
+	manager.SetOptions(Options{DisabledIntegrations: []string{"slog"}})
+	readiness, err := manager.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []Framework{
+		{Library: "database/sql"},
+		{Library: "log/slog", Integrations: []string{"slog"}, Disabled: true},
+		{Library: "net/http", Integrations: []string{httpClientIntegration, httpServerIntegration}},
+	}, readiness.Frameworks)
+	assert.Equal(t, []EntryPoint{
+		{Kind: "main", Name: "main", Position: "app.go:25"},
+		{Kind: "http handler", Name: "/ index", Position: "app.go:29"},
+	}, readiness.EntryPoints)
+	assert.Equal(t, 5, readiness.DeclaredFunctions)
+	assert.Equal(t, []Function{
+		{Name: "main.fetch", Position: "app.go:9"},
+		{Name: "main.index", Position: "app.go:17"},
+		{Name: "main.main", Position: "app.go:25"},
+	}, readiness.ReachableFunctions)
+
+	if assert.Len(t, readiness.OutboundCalls, 1) {
+		assert.Equal(t, httpMethodRule.ID, readiness.OutboundCalls[0].RuleID)
+		assert.Equal(t, 10, readiness.OutboundCalls[0].Line)
+	}
+	if assert.Len(t, readiness.SkippedGoroutines, 1) {
+		assert.Equal(t, goroutineInMainRule.ID, readiness.SkippedGoroutines[0].RuleID)
+		assert.Equal(t, 30, readiness.SkippedGoroutines[0].Line)
+	}
+}
+
+func TestFrameworkStatus(t *testing.T) {
+	tests := []struct {
+		framework Framework
+		want      string
+	}{
+		{framework: Framework{Library: "net/http", Integrations: []string{"http-client"}}, want: "supported"},
+		{framework: Framework{Library: "net/http", Integrations: []string{"http-client"}, Disabled: true}, want: "disabled"},
+		{framework: Framework{Library: "github.com/labstack/echo"}, want: "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.framework.Status())
+		})
+	}
+}
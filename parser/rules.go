@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/decorator/resolver/goast"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+// Templates are rendered with placeholder identifiers, which are replaced by the expressions they stand for
+// each time a rule is applied.
+const (
+	ruleVariablePlaceholder    = "nrRuleVariable__"
+	ruleAgentPlaceholder       = "nrRuleAgent__"
+	ruleTransactionPlaceholder = "nrRuleTransaction__"
+	ruleCallPlaceholder        = "nrRuleCall__"
+)
+
+// ruleTemplateValues are the values that the templates of a rule can use.
+type ruleTemplateValues struct {
+	Variable    string
+	Agent       string
+	Transaction string
+	Call        string
+}
+
+// compiledRule is a rule with its templates parsed into syntax trees.
+type compiledRule struct {
+	rules.Rule
+	after []dst.Stmt
+	wrap  dst.Expr
+}
+
+// ruleIntegration compiles a rule into an integration that applies it with a generic tracing function.
+func ruleIntegration(rule rules.Rule) (integration, error) {
+	r, err := compileRule(rule)
+	if err != nil {
+		return integration{}, err
+	}
+
+	i := integration{
+		name:        rule.Name,
+		library:     rule.Match.Package,
+		description: rule.Description,
+	}
+	if rule.Scope == rules.ScopeAll {
+		i.stateless = []StatelessTracingFunction{r.instrumentAll}
+	} else {
+		i.stateful = []StatefulTracingFunction{r.instrumentTraced}
+	}
+	return i, nil
+}
+
+// compileRule parses the templates of a rule, and checks that they only use the values that are available to it.
+func compileRule(rule rules.Rule) (*compiledRule, error) {
+	r := &compiledRule{Rule: rule}
+	var nodes []dst.Node
+	if rule.Insert.After != "" {
+		stmts, err := parseRuleTemplate(rule, rule.Insert.After, "")
+		if err != nil {
+			return nil, err
+		}
+		r.after = stmts
+		for _, stmt := range stmts {
+			nodes = append(nodes, stmt)
+		}
+	} else {
+		stmts, err := parseRuleTemplate(rule, rule.Insert.Wrap, "_ = ")
+		if err != nil {
+			return nil, err
+		}
+		assign, ok := stmts[0].(*dst.AssignStmt)
+		if len(stmts) != 1 || !ok || len(assign.Rhs) != 1 {
+			return nil, fmt.Errorf("the wrap template of rule %q must be a single expression", rule.Name)
+		}
+		r.wrap = assign.Rhs[0]
+		nodes = append(nodes, r.wrap)
+	}
+
+	for _, node := range nodes {
+		if rule.Scope == rules.ScopeAll && (usesPlaceholder(node, ruleAgentPlaceholder) || usesPlaceholder(node, ruleTransactionPlaceholder)) {
+			return nil, fmt.Errorf("rule %q can not use {{.Agent}} or {{.Transaction}}, since they are only available to rules applied in traced functions", rule.Name)
+		}
+		if r.after != nil && usesPlaceholder(node, ruleCallPlaceholder) {
+			return nil, fmt.Errorf("rule %q can not use {{.Call}}, since it is only available to wrap templates", rule.Name)
+		}
+	}
+	return r, nil
+}
+
+// parseRuleTemplate renders a template of a rule, and parses it as the body of a function in a file that imports the
+// packages of the rule, so that the identifiers of those packages are resolved to their import paths.
+func parseRuleTemplate(rule rules.Rule, text, prefix string) ([]dst.Stmt, error) {
+	tmpl, err := template.New(rule.Name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the template of rule %q: %w", rule.Name, err)
+	}
+
+	src := &strings.Builder{}
+	src.WriteString("package rule\n\nimport (\n")
+	for _, path := range slices.Concat([]string{rule.Match.Package}, rule.Insert.Imports) {
+		fmt.Fprintf(src, "\t%q\n", path)
+	}
+	src.WriteString(")\n\nfunc _() {\n" + prefix)
+
+	values := ruleTemplateValues{
+		Variable:    ruleVariablePlaceholder,
+		Agent:       ruleAgentPlaceholder,
+		Transaction: ruleTransactionPlaceholder,
+		Call:        ruleCallPlaceholder,
+	}
+	body := &bytes.Buffer{}
+	if err := tmpl.Execute(body, values); err != nil {
+		return nil, fmt.Errorf("rendering the template of rule %q: %w", rule.Name, err)
+	}
+	src.Write(body.Bytes())
+	src.WriteString("\n}\n")
+
+	file, err := decorator.NewDecoratorWithImports(token.NewFileSet(), "rule", goast.New()).Parse(src.String())
+	if err != nil {
+		return nil, fmt.Errorf("the template of rule %q is not valid Go code: %w", rule.Name, err)
+	}
+	decl := file.Decls[len(file.Decls)-1].(*dst.FuncDecl)
+	if len(decl.Body.List) == 0 {
+		return nil, fmt.Errorf("the template of rule %q is empty", rule.Name)
+	}
+	return decl.Body.List, nil
+}
+
+// usesPlaceholder returns true if a placeholder identifier is used in a node.
+func usesPlaceholder(node dst.Node, placeholder string) bool {
+	used := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok && ident.Path == "" && ident.Name == placeholder {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
+
+// replacePlaceholders replaces the placeholder identifiers in a copy of a template with the expressions they stand for.
+// The expression of each placeholder is only created if the placeholder is used.
+func replacePlaceholders(node dst.Node, values map[string]func() dst.Expr) dst.Node {
+	return dstutil.Apply(dst.Clone(node), nil, func(c *dstutil.Cursor) bool {
+		ident, ok := c.Node().(*dst.Ident)
+		if !ok || ident.Path != "" {
+			return true
+		}
+		if value, ok := values[ident.Name]; ok {
+			c.Replace(value())
+		}
+		return true
+	})
+}
+
+// matchAssignment returns an assignment that is matched by the rule, and the variable that is instrumented.
+func (r *compiledRule) matchAssignment(stmt dst.Stmt) (*dst.AssignStmt, *dst.Ident, bool) {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 || r.Match.Result >= len(assign.Lhs) {
+		return nil, nil, false
+	}
+
+	switch r.Match.Assignment {
+	case rules.AssignmentDefine:
+		if assign.Tok != token.DEFINE {
+			return nil, nil, false
+		}
+	case rules.AssignmentAssign:
+		if assign.Tok != token.ASSIGN {
+			return nil, nil, false
+		}
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	if !ok || fun.Path != r.Match.Package || !slices.Contains(r.Match.Functions, fun.Name) {
+		return nil, nil, false
+	}
+
+	variable, ok := assign.Lhs[r.Match.Result].(*dst.Ident)
+	if !ok || variable.Name == "_" {
+		return nil, nil, false
+	}
+	if r.wrap != nil && len(assign.Lhs) != 1 {
+		return nil, nil, false
+	}
+	return assign, variable, true
+}
+
+// apply instruments an assignment that is matched by the rule. The tracing state is nil for rules that are applied
+// in every function.
+func (r *compiledRule) apply(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	assign, variable, ok := r.matchAssignment(stmt)
+	if !ok || (r.after != nil && c.Index() < 0) {
+		return false
+	}
+
+	values := map[string]func() dst.Expr{
+		ruleVariablePlaceholder: func() dst.Expr { return dst.NewIdent(variable.Name) },
+		ruleCallPlaceholder:     func() dst.Expr { return dst.Clone(assign.Rhs[0]).(dst.Expr) },
+	}
+	if tracing != nil {
+		values[ruleAgentPlaceholder] = tracing.AgentVariable
+		values[ruleTransactionPlaceholder] = tracing.TransactionVariable
+	}
+
+	var after []dst.Stmt
+	for _, template := range r.after {
+		after = append(after, replacePlaceholders(template, values).(dst.Stmt))
+	}
+	if len(after) > 0 && isSameStatement(after[0], nextStatement(c)) {
+		comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Rule %s was already applied to %s", r.Name, variable.Name))
+		return false
+	}
+
+	comment.Debug(manager.getDecoratorPackage(), stmt, fmt.Sprintf("Applying rule %s to %s", r.Name, variable.Name))
+	if r.wrap != nil {
+		assign.Rhs[0] = replacePlaceholders(r.wrap, values).(dst.Expr)
+	} else {
+		for i := len(after) - 1; i >= 0; i-- {
+			c.InsertAfter(after[i])
+		}
+	}
+	for _, path := range r.Insert.Imports {
+		manager.addImport(path)
+	}
+	return true
+}
+
+// instrumentTraced is the stateful tracing function of rules that are applied in traced functions.
+func (r *compiledRule) instrumentTraced(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	return r.apply(manager, stmt, c, tracing)
+}
+
+// instrumentAll is the stateless tracing function of rules that are applied in every function.
+func (r *compiledRule) instrumentAll(manager *InstrumentationManager, c *dstutil.Cursor) {
+	if stmt, ok := c.Node().(dst.Stmt); ok {
+		r.apply(manager, stmt, c, nil)
+	}
+}
@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleIntegration(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   rules.Rule
+		code   string
+		expect string
+	}{
+		{
+			name: "statements are inserted after assignments in traced functions",
+			rule: rules.Rule{
+				Name:  "log-writer",
+				Scope: rules.ScopeTraced,
+				Match: rules.Match{Package: "log", Functions: []string{"New"}, Assignment: rules.AssignmentAny},
+				Insert: rules.Insert{
+					After:   "{{.Variable}}.SetOutput(logWriter.New(os.Stdout, {{.Agent}}))",
+					Imports: []string{"os", "github.com/newrelic/go-agent/v3/integrations/logcontext-v2/logWriter"},
+				},
+			},
+			code: `package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.Print("started")
+}
+`,
+			expect: `package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/logcontext-v2/logWriter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.SetOutput(logWriter.New(os.Stdout, NewRelicAgent))
+	logger.Print("started")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "calls are wrapped in traced functions",
+			rule: rules.Rule{
+				Name:  "slog-handler",
+				Scope: rules.ScopeTraced,
+				Match: rules.Match{Package: "log/slog", Functions: []string{"NewTextHandler", "NewJSONHandler"}, Assignment: rules.AssignmentAny},
+				Insert: rules.Insert{
+					Wrap:    "nrslog.WrapHandler({{.Agent}}, {{.Call}})",
+					Imports: []string{"github.com/newrelic/go-agent/v3/integrations/logcontext-v2/nrslog"},
+				},
+			},
+			code: `package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+func main() {
+	handler := slog.NewTextHandler(os.Stdout, nil)
+	slog.New(handler).Info("started")
+}
+`,
+			expect: `package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/logcontext-v2/nrslog"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	handler := nrslog.WrapHandler(NewRelicAgent, slog.NewTextHandler(os.Stdout, nil))
+	slog.New(handler).Info("started")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "rules with the all scope are applied in every function",
+			rule: rules.Rule{
+				Name:   "sql-pool",
+				Scope:  rules.ScopeAll,
+				Match:  rules.Match{Package: "database/sql", Functions: []string{"Open"}, Assignment: rules.AssignmentDefine},
+				Insert: rules.Insert{After: "{{.Variable}}.SetMaxOpenConns(10)\n{{.Variable}}.SetMaxIdleConns(5)"},
+			},
+			code: `package main
+
+import "database/sql"
+
+func open() (*sql.DB, error) {
+	db, err := sql.Open("mysql", "localhost")
+	return db, err
+}
+
+func reopen(db *sql.DB) error {
+	var err error
+	db, err = sql.Open("mysql", "localhost")
+	return err
+}
+
+func main() {}
+`,
+			expect: `package main
+
+import "database/sql"
+
+func open() (*sql.DB, error) {
+	db, err := sql.Open("mysql", "localhost")
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	return db, err
+}
+
+func reopen(db *sql.DB) error {
+	var err error
+	db, err = sql.Open("mysql", "localhost")
+	return err
+}
+
+func main() {}
+`,
+		},
+		{
+			name: "statements that were already inserted are not inserted again",
+			rule: rules.Rule{
+				Name:   "sql-pool",
+				Scope:  rules.ScopeAll,
+				Match:  rules.Match{Package: "database/sql", Functions: []string{"Open"}, Assignment: rules.AssignmentDefine},
+				Insert: rules.Insert{After: "{{.Variable}}.SetMaxOpenConns(10)\n{{.Variable}}.SetMaxIdleConns(5)"},
+			},
+			code: `package main
+
+import "database/sql"
+
+func open() (*sql.DB, error) {
+	db, err := sql.Open("mysql", "localhost")
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	return db, err
+}
+
+func main() {}
+`,
+			expect: `package main
+
+import "database/sql"
+
+func open() (*sql.DB, error) {
+	db, err := sql.Open("mysql", "localhost")
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	return db, err
+}
+
+func main() {}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			i, err := ruleIntegration(tt.rule)
+			if err != nil {
+				t.Fatalf("ruleIntegration failed: %v", err)
+			}
+			var got string
+			if tt.rule.Scope == rules.ScopeAll {
+				got = testStatelessTracingFunction(t, tt.code, i.stateless[0])
+			} else {
+				got = testStatelessTracingFunction(t, tt.code, InstrumentMain, i.stateful...)
+			}
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestCompileRule(t *testing.T) {
+	match := rules.Match{Package: "example.com/platform/cache", Functions: []string{"Open"}, Assignment: rules.AssignmentAny}
+	tests := []struct {
+		name    string
+		rule    rules.Rule
+		wantErr bool
+	}{
+		{
+			name: "after template",
+			rule: rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{After: "nrcache.Instrument({{.Variable}}, {{.Transaction}})", Imports: []string{"example.com/platform/cache/nrcache"}}},
+		},
+		{
+			name: "wrap template",
+			rule: rules.Rule{Name: "cache", Scope: rules.ScopeAll, Match: match, Insert: rules.Insert{Wrap: "nrcache.Wrap({{.Call}})"}},
+		},
+		{
+			name:    "templates must be valid Go code",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{After: "{{.Variable}}.Use("}},
+			wantErr: true,
+		},
+		{
+			name:    "templates can only use known values",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{After: "{{.Router}}.Use()"}},
+			wantErr: true,
+		},
+		{
+			name:    "templates must be valid templates",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{After: "{{.Variable}.Use()"}},
+			wantErr: true,
+		},
+		{
+			name:    "the agent is not available to rules applied in every function",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeAll, Match: match, Insert: rules.Insert{After: "{{.Variable}}.Use({{.Agent}})"}},
+			wantErr: true,
+		},
+		{
+			name:    "the call is only available to wrap templates",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{After: "x := {{.Call}}"}},
+			wantErr: true,
+		},
+		{
+			name:    "wrap templates must be an expression",
+			rule:    rules.Rule{Name: "cache", Scope: rules.ScopeTraced, Match: match, Insert: rules.Insert{Wrap: "nrcache.Wrap({{.Call}}); x()"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileRule(tt.rule)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
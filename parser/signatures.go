@@ -0,0 +1,333 @@
+package parser
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+const (
+	// transactionVariantSuffix is appended to the name of a function to name the variant of it that accepts a transaction.
+	transactionVariantSuffix = "WithTxn"
+
+	// contextVariantSuffix is appended to the name of a function to name the variant of it that accepts a context
+	// when transactions are propagated with context.
+	contextVariantSuffix = "Ctx"
+)
+
+// useTransactionVariant redirects a call to a function whose signature can not be changed to a variant of that
+// function that accepts a transaction, creating the variant the first time it is needed. The original function keeps
+// its signature, and calls the variant with a nil transaction:
+//
+//	func (c *Config) String() string {
+//		return c.StringWithTxn(nil)
+//	}
+//
+//	func (c *Config) StringWithTxn(nrTxn *newrelic.Transaction) string {
+//		...
+//	}
+//
+// When transactions are propagated with context, the variant is named StringCtx and is passed context.Background().
+//
+// Returns false if the call can not be traced because a variant could not be created.
+func (m *InstrumentationManager) useTransactionVariant(inv *invocationInfo) bool {
+	if inv.decl == nil || inv.call == nil {
+		return true
+	}
+
+	variant, ok := m.transactionVariants[inv.decl]
+	if !ok {
+		reason := m.signatureConstraint(inv)
+		if reason == "" {
+			return true
+		}
+		variant = m.createTransactionVariant(inv, reason)
+		m.transactionVariants[inv.decl] = variant
+	}
+
+	if variant == nil || !renameCallee(inv.call.Fun, variant.Name.Name) {
+		return false
+	}
+	inv.decl = variant
+	return true
+}
+
+// implementedInterface returns a known interface that a method implements, or nil if it is not a method of an
+// interface that the application may use.
+func (m *InstrumentationManager) implementedInterface(fn *types.Func) *types.TypeName {
+	named := namedReceiver(fn)
+	if named == nil {
+		return nil
+	}
+	for _, typeName := range m.getKnownInterfaces() {
+		iface := typeName.Type().Underlying().(*types.Interface)
+		for i := 0; i < iface.NumMethods(); i++ {
+			if iface.Method(i).Name() == fn.Name() && implementedMethod(named, iface, iface.Method(i)) == fn {
+				return typeName
+			}
+		}
+	}
+	return nil
+}
+
+// signatureConstraint returns the reason that a transaction parameter can not be added to a function declared in this
+// application, or an empty string if it can be. The signature of a method must not change if it implements an interface,
+// and the signature of an exported function must not change if it may be used by other modules.
+func (m *InstrumentationManager) signatureConstraint(inv *invocationInfo) string {
+	state, ok := m.packages[inv.packageName]
+	if !ok {
+		return ""
+	}
+
+	fn, ok := util.ObjectOf(inv.decl.Name, state.pkg).(*types.Func)
+	if !ok || acceptsTransaction(fn) {
+		return ""
+	}
+
+	// functions that read the transaction from a struct parameter are passed it without changing their signature
+	if _, ok := m.carrierFunctions[fn]; ok {
+		return ""
+	}
+
+	if typeName := m.implementedInterface(fn); typeName != nil {
+		return fmt.Sprintf("it implements %s", types.TypeString(typeName.Type(), types.RelativeTo(state.pkg.Types)))
+	}
+
+	if m.options.PreserveExportedAPIs && isExportedAPI(fn) {
+		return fmt.Sprintf("it is exported by %s and may be used by other modules", fn.Pkg().Path())
+	}
+	return ""
+}
+
+// interfaceSignatureConstraint returns the reason that a transaction parameter can not be added to an interface method
+// declared in this application and its implementations, or an empty string if it can be. Like functions, their
+// signatures must not change if they are exported and may be used by other modules.
+func (m *InstrumentationManager) interfaceSignatureConstraint(method *types.Func, impls []*invocationInfo) string {
+	if !m.options.PreserveExportedAPIs {
+		return ""
+	}
+	if isExportedAPI(method) {
+		return fmt.Sprintf("it is exported by %s and may be used by other modules", method.Pkg().Path())
+	}
+
+	for _, impl := range impls {
+		state, ok := m.packages[impl.packageName]
+		if !ok {
+			continue
+		}
+		if fn, ok := util.ObjectOf(impl.decl.Name, state.pkg).(*types.Func); ok && isExportedAPI(fn) {
+			return fmt.Sprintf("%s is exported by %s and may be used by other modules", functionKey(impl.decl), fn.Pkg().Path())
+		}
+	}
+	return ""
+}
+
+// isExportedAPI returns true if a function or method can be used by packages outside of the module it is declared in.
+func isExportedAPI(fn *types.Func) bool {
+	if !fn.Exported() || fn.Pkg() == nil || fn.Pkg().Name() == "main" || slices.Contains(strings.Split(fn.Pkg().Path(), "/"), "internal") {
+		return false
+	}
+
+	if named := namedReceiver(fn); named != nil {
+		return named.Obj().Exported()
+	}
+	return true
+}
+
+// getKnownInterfaces returns the package level interface types that a type declared in this application may implement.
+// This includes every interface declared in this application, the exported interfaces of packages imported by it, and error.
+func (m *InstrumentationManager) getKnownInterfaces() []*types.TypeName {
+	if m.knownInterfaces != nil {
+		return m.knownInterfaces
+	}
+
+	m.knownInterfaces = []*types.TypeName{types.Universe.Lookup("error").(*types.TypeName)}
+	addInterfaces := func(pkg *types.Package, exportedOnly bool) {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || typeName.IsAlias() || (exportedOnly && !typeName.Exported()) {
+				continue
+			}
+			if named, ok := typeName.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.IsInterface(typeName.Type()) {
+				m.knownInterfaces = append(m.knownInterfaces, typeName)
+			}
+		}
+	}
+
+	visited := map[*types.Package]bool{}
+	imported := []*types.Package{}
+	for _, path := range m.getSortedPackages() {
+		state := m.packages[path]
+		if util.IsTestPackage(state.pkg) || state.pkg.Types == nil {
+			continue
+		}
+		visited[state.pkg.Types] = true
+		imported = append(imported, state.pkg.Types.Imports()...)
+		addInterfaces(state.pkg.Types, false)
+	}
+
+	for len(imported) > 0 {
+		pkg := imported[0]
+		imported = imported[1:]
+		if visited[pkg] {
+			continue
+		}
+		visited[pkg] = true
+		imported = append(imported, pkg.Imports()...)
+		addInterfaces(pkg, true)
+	}
+	return m.knownInterfaces
+}
+
+// createTransactionVariant adds a variant of a function declaration that accepts a transaction to the file it is declared in.
+// The body of the function is moved to the variant, and replaced with a call to the variant. If a variant can not be
+// created, a warning is added to the function declaration and nil is returned.
+func (m *InstrumentationManager) createTransactionVariant(inv *invocationInfo, reason string) *dst.FuncDecl {
+	state := m.packages[inv.packageName]
+	decl := inv.decl
+	name := decl.Name.Name + transactionVariantSuffix
+	if m.options.Propagation == PropagateContext {
+		name = decl.Name.Name + contextVariantSuffix
+	}
+	fn := util.ObjectOf(decl.Name, state.pkg).(*types.Func)
+
+	warn := func(problem string) *dst.FuncDecl {
+		comment.Warn(state.pkg, transactionVariantRule, decl, decl, fmt.Sprintf("the transaction can not be passed to %s because %s", decl.Name.Name, reason), problem)
+		return nil
+	}
+	if fn.Type().(*types.Signature).Variadic() {
+		return warn("a transaction parameter can not be added to a variant of a variadic function")
+	}
+	if isDeclared(fn, name) {
+		return warn(fmt.Sprintf("a variant of the function that accepts a transaction can not be created because %s is already declared", name))
+	}
+
+	file, index := findFuncDecl(state, decl)
+	if file == nil {
+		return nil
+	}
+
+	variant := &dst.FuncDecl{
+		Name: dst.NewIdent(name),
+		Type: dst.Clone(decl.Type).(*dst.FuncType),
+		Body: decl.Body,
+	}
+	if decl.Recv != nil {
+		variant.Recv = dst.Clone(decl.Recv).(*dst.FieldList)
+	}
+	for _, field := range variant.Type.Params.List {
+		if len(field.Names) == 0 {
+			field.Names = []*dst.Ident{dst.NewIdent("_")}
+		}
+	}
+	variant.Decs.Before = dst.EmptyLine
+	variant.Decs.Start.Append(
+		fmt.Sprintf("// %s is %s with a New Relic transaction passed to it.", name, decl.Name.Name),
+		fmt.Sprintf("// The signature of %s is preserved because %s.", decl.Name.Name, reason),
+	)
+
+	args := forwardedArguments(decl)
+	if m.options.Propagation == PropagateContext {
+		args = append([]dst.Expr{codegen.BackgroundContext()}, args...)
+	} else {
+		args = append(args, dst.NewIdent("nil"))
+	}
+	call := codegen.ForwardCall(variantCallee(decl, name), args, decl.Type.Results != nil && len(decl.Type.Results.List) > 0)
+	call.Decorations().Before = dst.NewLine
+	call.Decorations().After = dst.NewLine
+	decl.Body = &dst.BlockStmt{List: []dst.Stmt{call}}
+
+	file.Decls = slices.Insert(file.Decls, index+1, dst.Decl(variant))
+	state.tracedFuncs[functionKey(variant)] = &tracedFunctionDecl{body: variant}
+	m.variantOriginals[variant] = decl
+	return variant
+}
+
+// isDeclared returns true if a function, or a method of the same receiver as a method, already has the given name.
+func isDeclared(fn *types.Func, name string) bool {
+	if named := namedReceiver(fn); named != nil {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, fn.Pkg(), name)
+		return obj != nil
+	}
+	return fn.Pkg().Scope().Lookup(name) != nil
+}
+
+// findFuncDecl returns the file that a function is declared in, and the index of its declaration in that file.
+func findFuncDecl(state *packageState, decl *dst.FuncDecl) (*dst.File, int) {
+	for _, file := range state.pkg.Syntax {
+		if i := slices.Index(file.Decls, dst.Decl(decl)); i >= 0 {
+			return file, i
+		}
+	}
+	return nil, -1
+}
+
+// forwardedArguments returns the parameters of a function declaration as arguments that can be passed to another function.
+// Parameters that are unnamed, or named "_", are given a name so that they can be forwarded.
+func forwardedArguments(decl *dst.FuncDecl) []dst.Expr {
+	args := []dst.Expr{}
+	for _, field := range decl.Type.Params.List {
+		if len(field.Names) == 0 {
+			field.Names = []*dst.Ident{dst.NewIdent("_")}
+		}
+		for i, ident := range field.Names {
+			if ident.Name == "_" {
+				field.Names[i] = dst.NewIdent(fmt.Sprintf("arg%d", len(args)))
+			}
+			args = append(args, dst.NewIdent(field.Names[i].Name))
+		}
+	}
+	return args
+}
+
+// variantCallee returns the expression that a function declaration calls its variant by. The receiver of a method is named
+// if it is unnamed, and the type parameters of a generic function are passed to the variant.
+func variantCallee(decl *dst.FuncDecl, name string) dst.Expr {
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		recv := decl.Recv.List[0]
+		if len(recv.Names) == 0 || recv.Names[0].Name == "_" {
+			recv.Names = []*dst.Ident{dst.NewIdent("recv")}
+		}
+		return &dst.SelectorExpr{X: dst.NewIdent(recv.Names[0].Name), Sel: dst.NewIdent(name)}
+	}
+
+	if decl.Type.TypeParams == nil || len(decl.Type.TypeParams.List) == 0 {
+		return dst.NewIdent(name)
+	}
+	typeArgs := []dst.Expr{}
+	for _, field := range decl.Type.TypeParams.List {
+		for _, ident := range field.Names {
+			typeArgs = append(typeArgs, dst.NewIdent(ident.Name))
+		}
+	}
+	return &dst.IndexListExpr{X: dst.NewIdent(name), Indices: typeArgs}
+}
+
+// renameCallee renames the function called by a call expression. Returns false if the function is not called by name.
+func renameCallee(fun dst.Expr, name string) bool {
+	switch v := fun.(type) {
+	case *dst.Ident:
+		v.Name = name
+	case *dst.SelectorExpr:
+		v.Sel.Name = name
+	case *dst.IndexExpr:
+		return renameCallee(v.X, name)
+	case *dst.IndexListExpr:
+		return renameCallee(v.X, name)
+	case *dst.ParenExpr:
+		return renameCallee(v.X, name)
+	default:
+		return false
+	}
+	return true
+}
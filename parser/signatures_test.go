@@ -0,0 +1,322 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/decorator/resolver/guess"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestTransactionVariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "method that implements a standard library interface",
+			code: `package main
+
+import "fmt"
+
+type config struct {
+	name string
+}
+
+func (c *config) String() string {
+	return fmt.Sprintf("config %s", c.name)
+}
+
+func main() {
+	c := &config{name: "test"}
+	fmt.Println(c.String())
+}
+`,
+			expect: `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type config struct {
+	name string
+}
+
+func (c *config) String() string {
+	return c.StringWithTxn(nil)
+}
+
+// StringWithTxn is String with a New Relic transaction passed to it.
+// The signature of String is preserved because it implements fmt.Stringer.
+func (c *config) StringWithTxn(nrTxn *newrelic.Transaction) string {
+	defer nrTxn.StartSegment("String").End()
+
+	return fmt.Sprintf("config %s", c.name)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	c := &config{name: "test"}
+	nrTxn := NewRelicAgent.StartTransaction("String")
+	fmt.Println(c.StringWithTxn(nrTxn))
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "method that implements an application interface with unnamed parameters",
+			code: `package main
+
+import "net/http"
+
+type fetcher interface {
+	Fetch(string) error
+}
+
+type client struct{}
+
+func (client) Fetch(string) error {
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func main() {
+	var f fetcher = client{}
+	client{}.Fetch("http://example.com")
+	_ = f
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type fetcher interface {
+	Fetch(string) error
+}
+
+type client struct{}
+
+func (recv client) Fetch(arg0 string) error {
+	return recv.FetchWithTxn(arg0, nil)
+}
+
+// FetchWithTxn is Fetch with a New Relic transaction passed to it.
+// The signature of Fetch is preserved because it implements fetcher.
+func (client) FetchWithTxn(_ string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("Fetch").End()
+
+	_, err := http.Get("http://example.com")
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	var f fetcher = client{}
+	nrTxn := NewRelicAgent.StartTransaction("Fetch")
+	client{}.FetchWithTxn("http://example.com", nrTxn)
+	nrTxn.End()
+	_ = f
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "method that accepts a context is not changed",
+			code: `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type store struct{}
+
+func (s *store) Write(ctx context.Context) {
+	fmt.Println("writing")
+}
+
+type writer interface {
+	Write(context.Context)
+}
+
+func main() {
+	var w writer = &store{}
+	s := &store{}
+	s.Write(context.Background())
+	_ = w
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type store struct{}
+
+func (s *store) Write(ctx context.Context) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("Write").End()
+
+	fmt.Println("writing")
+}
+
+type writer interface {
+	Write(context.Context)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	var w writer = &store{}
+	s := &store{}
+	nrTxn := NewRelicAgent.StartTransaction("Write")
+	s.Write(newrelic.NewContext(context.Background(), nrTxn))
+	nrTxn.End()
+	_ = w
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestPreserveExportedInterfaces(t *testing.T) {
+	defer panicRecovery(t)
+	if testing.Short() {
+		t.Skip("Skipping Stateful Tracing Function Integration Tests in short mode")
+	}
+	id, err := pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	defer cleanTestApp(t, testDir)
+
+	lib := `package lib
+
+type Processor interface {
+	Process(name string) error
+}
+
+type Impl struct{}
+
+func (i *Impl) Process(name string) error {
+	return nil
+}
+`
+	app := fmt.Sprintf(`package main
+
+import "github.com/newrelic/go-easy-instrumentation/parser/%s/lib"
+
+func main() {
+	var p lib.Processor = &lib.Impl{}
+	p.Process("work")
+}
+`, testDir)
+	if err := os.MkdirAll(filepath.Join(testDir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "lib", "lib.go"), []byte(lib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "app.go"), []byte(app), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := decorator.Load(&packages.Config{Dir: testDir, Mode: packages.LoadSyntax}, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewInstrumentationManager(pkgs, Config{AgentVariableName: "NewRelicAgent"}, filepath.Join(testDir, "new-relic-instrumentation.diff"), testDir)
+	manager.SetOptions(Options{PreserveExportedAPIs: true})
+	manager.tracingFunctions.stateless = append(manager.tracingFunctions.stateless, InstrumentMain)
+	if err := manager.TracePackageCalls(); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.InstrumentApplication(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pkg := range pkgs {
+		restorer := decorator.NewRestorerWithImports(testDir, guess.New())
+		buf := bytes.NewBuffer([]byte{})
+		if err := restorer.Fprint(buf, pkg.Syntax[0]); err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Name == "lib" {
+			assert.Equal(t, lib, buf.String())
+		} else {
+			assert.Contains(t, buf.String(), "because it is exported by github.com/newrelic/go-easy-instrumentation/parser/"+testDir+"/lib and may be used by other modules\n\tp.Process(\"work\")")
+		}
+	}
+}
+
+func Test_isExportedAPI(t *testing.T) {
+	newFunc := func(path, pkgName, name string) *types.Func {
+		pkg := types.NewPackage(path, pkgName)
+		return types.NewFunc(token.NoPos, pkg, name, types.NewSignatureType(nil, nil, nil, nil, nil, false))
+	}
+
+	tests := []struct {
+		name string
+		fn   *types.Func
+		want bool
+	}{
+		{name: "exported function", fn: newFunc("example.com/app/client", "client", "Fetch"), want: true},
+		{name: "unexported function", fn: newFunc("example.com/app/client", "client", "fetch"), want: false},
+		{name: "main package", fn: newFunc("example.com/app", "main", "Fetch"), want: false},
+		{name: "internal package", fn: newFunc("example.com/app/internal/client", "client", "Fetch"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isExportedAPI(tt.fn))
+		})
+	}
+}
@@ -49,7 +49,10 @@ func InstrumentSlogHandler(manager *InstrumentationManager, c *dstutil.Cursor) {
 			stmt := decl.Body.List[i]
 
 			slogHandler := slogMiddlewareCall(stmt)
-			if slogHandler != "" {
+			if slogHandler != "" && i+1 < len(decl.Body.List) && isWrappedHandler(decl.Body.List[i+1], slogHandler) {
+				// the handler was already wrapped, and its uses replaced by a previous run
+				i++
+			} else if slogHandler != "" {
 				// We detected an slog handler
 				nrHandler := "NR" + slogHandler
 				handlerNames = append(handlerNames, slogHandler)
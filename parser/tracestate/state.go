@@ -27,7 +27,11 @@ type State struct {
 	async            bool                    // async indicates that the current function is an async function.
 	needsSegment     bool                    // needsSegment indicates that a segment should be created for the current function.
 	addTracingParam  bool                    // addTracingParam indicates that a tracing parameter should be added to the current function.
+	contextFirst     bool                    // contextFirst indicates that transactions are passed to functions in a context added as their first parameter.
+	recoverPanics    bool                    // recoverPanics indicates that the transaction is not ended by a deferred End, so panics must be recovered to be captured.
 	agentVariable    string                  // agentVariable is the name of the agent variable in the main function.
+	functionName     string                  // functionName is the name of the function declaration being traced, or that a function literal is declared in.
+	segmentName      string                  // segmentName is the name of the segment created for the function declaration being traced.
 	txnVariable      string                  // txnVariable is the name of the transaction variable in the current scope.
 	object           traceobject.TraceObject // object is the object that contains the transaction, along with helper functions for how to utilize it.
 	funcLitVariables map[string]*dst.FuncLit // funcLitVariables is a map of function literals that have been created in the current scope.
@@ -85,6 +89,9 @@ func (tc *State) functionCall(obj traceobject.TraceObject) *State {
 		main:             false,
 		needsSegment:     true,
 		addTracingParam:  true,
+		contextFirst:     tc.contextFirst,
+		functionName:     tc.functionName,
+		recoverPanics:    tc.main, // transactions started in main are ended after the call returns
 		funcLitVariables: make(map[string]*dst.FuncLit),
 	}
 }
@@ -98,10 +105,44 @@ func (tc *State) goroutine(obj traceobject.TraceObject) *State {
 		needsSegment:     true,
 		addTracingParam:  true,
 		async:            true,
+		contextFirst:     tc.contextFirst,
+		functionName:     tc.functionName,
+		recoverPanics:    true, // panics in a new goroutine are not recovered by the end of the transaction
 		funcLitVariables: make(map[string]*dst.FuncLit),
 	}
 }
 
+// Clone returns a copy of the state that can be used to trace another declaration invoked by the same function call,
+// such as each implementation of an interface method.
+func (tc *State) Clone() *State {
+	clone := *tc
+	clone.funcLitVariables = make(map[string]*dst.FuncLit)
+	return &clone
+}
+
+// AsyncFunctionLiteral creates a trace state for a function literal that is run in a new goroutine by an API,
+// such as errgroup.Group.Go. The signature of the function literal is defined by the API, so the transaction can
+// not be passed to it as a parameter. Instead, the function literal must assign a new goroutine transaction to the
+// default transaction variable at the start of its body.
+func (tc *State) AsyncFunctionLiteral() *State {
+	return &State{
+		txnVariable:      codegen.DefaultTransactionVariable,
+		object:           traceobject.NewTransaction(),
+		needsSegment:     true,
+		async:            true,
+		contextFirst:     tc.contextFirst,
+		functionName:     tc.functionName,
+		recoverPanics:    true, // panics in a new goroutine are not recovered by the end of the transaction
+		funcLitVariables: make(map[string]*dst.FuncLit),
+	}
+}
+
+// PropagateWithContext makes this state, and the states created from it, pass transactions to functions that do not accept
+// a context or transaction in a context that is added as their first parameter, rather than in a transaction parameter.
+func (tc *State) PropagateWithContext() {
+	tc.contextFirst = true
+}
+
 // CreateSegment creates a segment for the current function if needed.
 // Calling this will add a defer statement to the function declaration that will create a segment as the first
 // statement in the function.
@@ -114,6 +155,9 @@ func (tc *State) CreateSegment(node dst.Node) (string, bool) {
 	switch decl := node.(type) {
 	case *dst.FuncDecl:
 		name := decl.Name.Name
+		if tc.segmentName != "" {
+			name = tc.segmentName
+		}
 		if tc.async {
 			name = fmt.Sprintf("async %s", name)
 		}
@@ -157,6 +201,35 @@ func (tc *State) IsMain() bool {
 	return tc.main
 }
 
+// SetFunctionName sets the name of the function declaration being traced.
+func (tc *State) SetFunctionName(name string) {
+	tc.functionName = name
+}
+
+// SetSegmentName sets the name of the segment created for the function declaration being traced. Segments are named
+// after the function when this is not set.
+func (tc *State) SetSegmentName(name string) {
+	tc.segmentName = name
+}
+
+// FunctionName returns the name of the function declaration being traced, or that the function literal being traced is declared in.
+func (tc *State) FunctionName() string {
+	return tc.functionName
+}
+
+// RecoverPanics returns true if the current function is the first function in its goroutine to use a transaction that is not
+// ended by a deferred call to End in that goroutine. A panic in this function, or in the functions it calls, would end the
+// program without being captured by the transaction, unless it is recovered here.
+func (tc *State) RecoverPanics() bool {
+	return tc.recoverPanics
+}
+
+// TransactionEndDeferred is called when the transaction passed to the current function is ended by a deferred call to End,
+// which records panics when the agent is configured to.
+func (tc *State) TransactionEndDeferred() {
+	tc.recoverPanics = false
+}
+
 // TransactionVariable returns the name of the transaction variable.
 func (tc *State) TransactionVariable() dst.Expr {
 	if tc.main || tc.txnVariable == "" {
@@ -189,9 +262,17 @@ func (tc *State) AgentVariable() dst.Expr {
 // being passed. In general, the rules for what is passed are:
 //  1. If the function takes an argument of the same type as the tracing object, we will pass as that type.
 //  2. If the function takes an argument of type context.Context, we will inject the transaction into the passed context.
-//  3. If neither case 1 or case 2 is met, a *newrelic.Transaction will be passed as the last argument of the function.
+//  3. If neither case 1 or case 2 is met, a *newrelic.Transaction will be passed as the last argument of the function,
+//     or a context containing the transaction will be passed as its first argument if the state propagates with context.
 func (tc *State) AddToCall(pkg *decorator.Package, call *dst.CallExpr, async bool) (*State, string) {
-	callReturn := tc.object.AddToCall(pkg, call, tc.txnVariable, async)
+	var callReturn traceobject.AddToCallReturn
+	ok := false
+	if tc.contextFirst {
+		callReturn, ok = traceobject.PrependToCall(tc.object, pkg, call, tc.txnVariable, async)
+	}
+	if !ok {
+		callReturn = tc.object.AddToCall(pkg, call, tc.txnVariable, async)
+	}
 	if callReturn.NeedsTx {
 		tc.txnUsed = true
 	}
@@ -202,6 +283,20 @@ func (tc *State) AddToCall(pkg *decorator.Package, call *dst.CallExpr, async boo
 	return tc.functionCall(callReturn.TraceObject), callReturn.Import
 }
 
+// AddToCarrierCall passes the transaction to a function call in the struct argument at index, for functions that read
+// the transaction from a struct parameter that carries it. If the transaction can not be passed in the argument, it is
+// passed to the call like it is by AddToCall.
+func (tc *State) AddToCarrierCall(pkg *decorator.Package, call *dst.CallExpr, index int) (*State, string) {
+	callReturn, ok := traceobject.AddToCarrierArgument(tc.object, pkg, call, index, tc.txnVariable)
+	if !ok {
+		return tc.AddToCall(pkg, call, false)
+	}
+	if callReturn.NeedsTx {
+		tc.txnUsed = true
+	}
+	return tc.functionCall(callReturn.TraceObject), callReturn.Import
+}
+
 // FuncDeclaration creates a trace state for a function declaration.
 func (tc *State) FuncLiteralDeclaration(pkg *decorator.Package, lit *dst.FuncLit) *State {
 	return tc.functionCall(tc.object)
@@ -239,8 +334,27 @@ func (tc *State) GetFuncLitVariable(pkg *decorator.Package, variable dst.Expr) (
 // being passed. In general, the rules for what is passed are:
 //  1. If the function takes an argument of the same type as the tracing object, we will pass as that type.
 //  2. If the function takes an argument of type context.Context, we will inject the transaction into the passed context.
-//  3. If neither case 1 or case 2 is met, a *newrelic.Transaction will be passed as the last argument of the function.
+//  3. If neither case 1 or case 2 is met, a *newrelic.Transaction will be passed as the last argument of the function,
+//     or a context.Context will be added as its first parameter if the state propagates with context.
 func (tc *State) AddParameterToDeclaration(pkg *decorator.Package, node dst.Node) (string, bool) {
+	// functions passed the transaction in a struct read it from the struct parameter that carries it
+	_, carried := tc.object.(*traceobject.Struct)
+	if tc.addTracingParam && tc.contextFirst && !carried {
+		var obj traceobject.TraceObject
+		var goGet string
+		ok := false
+		switch decl := node.(type) {
+		case *dst.FuncDecl:
+			obj, goGet, ok = traceobject.PrependToParams(pkg, decl.Type, decl.Body)
+		case *dst.FuncLit:
+			obj, goGet, ok = traceobject.PrependToParams(pkg, decl.Type, decl.Body)
+		}
+		if ok {
+			tc.object = obj
+			return goGet, true
+		}
+	}
+
 	if tc.addTracingParam {
 		switch decl := node.(type) {
 		case *dst.FuncDecl:
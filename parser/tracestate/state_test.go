@@ -50,6 +50,7 @@ func TestState_AddToCall(t *testing.T) {
 		async           bool
 		needsSegment    bool
 		addTracingParam bool
+		contextFirst    bool
 		agentVariable   string
 		txnVariable     string
 		object          traceobject.TraceObject
@@ -130,6 +131,52 @@ func TestState_AddToCall(t *testing.T) {
 			want1: codegen.NewRelicAgentImportPath,
 			want2: &dst.CallExpr{Args: []dst.Expr{codegen.WrapContextExpression(knownContext, codegen.DefaultTransactionVariable, false)}},
 		},
+		{
+			name: "function call without context has context added as first argument when propagating with context",
+			fields: fields{
+				contextFirst: true,
+				txnVariable:  codegen.DefaultTransactionVariable,
+				object:       traceobject.NewTransaction(),
+			},
+			args: args{
+				pkg:   defaultDecorator,
+				call:  &dst.CallExpr{Args: []dst.Expr{dst.NewIdent("id")}},
+				async: false,
+			},
+			want: &State{
+				needsSegment:     true,
+				addTracingParam:  true,
+				contextFirst:     true,
+				txnVariable:      codegen.DefaultTransactionVariable,
+				object:           traceobject.NewContext(),
+				funcLitVariables: make(map[string]*dst.FuncLit),
+			},
+			want1: codegen.NewRelicAgentImportPath,
+			want2: &dst.CallExpr{Args: []dst.Expr{codegen.WrapContextExpression(codegen.BackgroundContext(), codegen.DefaultTransactionVariable, false), dst.NewIdent("id")}},
+		},
+		{
+			name: "function call without context is passed the context parameter when propagating with context",
+			fields: fields{
+				contextFirst: true,
+				txnVariable:  codegen.DefaultTransactionVariable,
+				object:       traceobject.NewContext("ctx"),
+			},
+			args: args{
+				pkg:   defaultDecorator,
+				call:  &dst.CallExpr{Args: []dst.Expr{}},
+				async: false,
+			},
+			want: &State{
+				needsSegment:     true,
+				addTracingParam:  true,
+				contextFirst:     true,
+				txnVariable:      codegen.DefaultTransactionVariable,
+				object:           traceobject.NewContext(),
+				funcLitVariables: make(map[string]*dst.FuncLit),
+			},
+			want1: "",
+			want2: &dst.CallExpr{Args: []dst.Expr{dst.NewIdent("ctx")}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -139,6 +186,7 @@ func TestState_AddToCall(t *testing.T) {
 				async:           tt.fields.async,
 				needsSegment:    tt.fields.needsSegment,
 				addTracingParam: tt.fields.addTracingParam,
+				contextFirst:    tt.fields.contextFirst,
 				agentVariable:   tt.fields.agentVariable,
 				txnVariable:     tt.fields.txnVariable,
 				object:          tt.fields.object,
@@ -237,6 +285,20 @@ func TestState_AddParameterToDeclaration(t *testing.T) {
 			wantImport: "",
 			expect:     createTestFunction(),
 		},
+		{
+			name: "empty function declaration in function call propagated with context",
+			args: args{
+				pkg:  defaultDecorator,
+				node: createTestFunction(),
+			},
+			state: func() *State {
+				state := FunctionBody(codegen.DefaultTransactionVariable, traceobject.NewTransaction())
+				state.PropagateWithContext()
+				return state.functionCall(traceobject.NewTransaction())
+			}(),
+			wantImport: codegen.NewRelicAgentImportPath,
+			expect:     createTestFunction(codegen.NewContextParameter(codegen.DefaultContextParameter)),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -343,6 +405,16 @@ func TestState_CreateSegment(t *testing.T) {
 			want:  codegen.NewRelicAgentImportPath,
 			want1: true,
 		},
+		{
+			name:  "async function literal run by a library in a traced function",
+			state: FunctionBody(codegen.DefaultTransactionVariable).AsyncFunctionLiteral(),
+			args: args{node: &dst.FuncLit{
+				Type: &dst.FuncType{},
+				Body: &dst.BlockStmt{},
+			}},
+			want:  codegen.NewRelicAgentImportPath,
+			want1: true,
+		},
 		{
 			name:  "create segments from context when tracing functions called from a traced function",
 			state: Main("foo").functionCall(traceobject.NewContext()),
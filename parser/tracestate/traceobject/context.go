@@ -2,6 +2,7 @@ package traceobject
 
 import (
 	"fmt"
+	"go/types"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -14,8 +15,15 @@ const (
 	contextType = "context.Context"
 )
 
+// contextTransactionRule is the rule of the comment added to calls when a transaction is added to a context argument.
+var contextTransactionRule = comment.Rule{
+	ID:          "transaction-in-context",
+	Integration: "core",
+	Docs:        "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/",
+}
+
 // Context is a trace object that contains a context.Context object.
-// Note: this will not work for structured objects that contain a context.Context object.
+// Structs that contain a context.Context are handled by the Struct trace object.
 type Context struct {
 	contextParameterName string // the name of the context parameter for a function declaration
 }
@@ -29,6 +37,15 @@ func NewContext(name ...string) *Context {
 
 func (ctx *Context) AddToCall(pkg *decorator.Package, call *dst.CallExpr, transactionVariableName string, async bool) AddToCallReturn {
 	for i, arg := range call.Args {
+		// the transaction was already injected into the context passed to the call
+		if isTransactionContext(arg, transactionVariableName) {
+			return AddToCallReturn{
+				TraceObject: NewContext(),
+				Import:      codegen.NewRelicAgentImportPath,
+				NeedsTx:     true,
+			}
+		}
+
 		typ := util.TypeOf(arg, pkg)
 		if typ != nil && typ.String() == contextType {
 			ident, ok := arg.(*dst.Ident)
@@ -53,7 +70,7 @@ func (ctx *Context) AddToCall(pkg *decorator.Package, call *dst.CallExpr, transa
 				// if the context variable being passed is different from the one we know has a transaction
 				// pass a transaction to it defensively.
 				argumentString := util.WriteExpr(arg, pkg)
-				comment.Info(pkg, call, call,
+				comment.Info(pkg, contextTransactionRule, call, call,
 					fmt.Sprintf("a transaction was added to to the context argument %s to ensure a transaction is passed to the function call", argumentString),
 					fmt.Sprintf("This may not be necessary, and can be safely removed if this context %s is a child of %s", argumentString, util.WriteExpr(dst.NewIdent(ctx.contextParameterName), pkg)),
 				)
@@ -104,3 +121,115 @@ func (ctx *Context) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit) (Trac
 func (ctx *Context) AssignTransactionVariable(variableName string) (dst.Stmt, string) {
 	return codegen.TxnFromContext(variableName, dst.NewIdent(ctx.contextParameterName)), ""
 }
+
+// PrependToCall passes the transaction to a function call in a context that is added as its first argument. The context
+// is derived from the context parameter of the trace object, or from the context returned by RequestContext if it does not have one.
+// Context arguments that are not a variable, like r.Context(), have the transaction added to them.
+// Calls that already pass a context variable or the transaction are not changed, and false is returned.
+func PrependToCall(obj TraceObject, pkg *decorator.Package, call *dst.CallExpr, transactionVariableName string, async bool) (AddToCallReturn, bool) {
+	for i, arg := range call.Args {
+		if isTransactionArgument(arg, transactionVariableName) {
+			return AddToCallReturn{}, false
+		}
+		typ := util.TypeOf(arg, pkg)
+		if typ != nil && typ.String() == contextType {
+			if _, ok := arg.(*dst.Ident); ok {
+				return AddToCallReturn{}, false
+			}
+			call.Args[i] = codegen.WrapContextExpression(arg, transactionVariableName, async)
+			return AddToCallReturn{
+				TraceObject: NewContext(),
+				Import:      codegen.NewRelicAgentImportPath,
+				NeedsTx:     true,
+			}, true
+		}
+	}
+
+	// the context we know has a transaction can be passed to the function call as is
+	ctx, ok := obj.(*Context)
+	if ok && ctx.contextParameterName != "" && !async {
+		call.Args = append([]dst.Expr{dst.NewIdent(ctx.contextParameterName)}, call.Args...)
+		return AddToCallReturn{TraceObject: NewContext()}, true
+	}
+
+	var base dst.Expr
+	if ok && ctx.contextParameterName != "" {
+		base = dst.NewIdent(ctx.contextParameterName)
+	} else {
+		base = RequestContext(call, pkg)
+	}
+	call.Args = append([]dst.Expr{codegen.WrapContextExpression(base, transactionVariableName, async)}, call.Args...)
+	return AddToCallReturn{
+		TraceObject: NewContext(),
+		Import:      codegen.NewRelicAgentImportPath,
+		NeedsTx:     true,
+	}, true
+}
+
+// RequestContext returns an expression for the context that is in scope at node, so that contexts created there keep
+// its cancellation and deadline. This is the nearest context.Context variable in scope, the context field of the nearest
+// struct in scope that carries one, like a request struct, or the context of the nearest *http.Request in scope. If none
+// of them exist, context.Background() is returned.
+func RequestContext(node dst.Node, pkg *decorator.Package) dst.Expr {
+	ctx := util.LookupVariable(node, pkg, func(v *types.Var) bool {
+		return v.Type().String() == contextType
+	})
+	if ctx != nil {
+		return dst.NewIdent(ctx.Name())
+	}
+
+	carrier := util.LookupVariable(node, pkg, func(v *types.Var) bool {
+		_, ok := contextCarrier(v.Type())
+		return ok
+	})
+	if carrier != nil {
+		field, _ := contextCarrier(carrier.Type())
+		return &dst.SelectorExpr{X: dst.NewIdent(carrier.Name()), Sel: dst.NewIdent(field)}
+	}
+
+	req := util.LookupVariable(node, pkg, func(v *types.Var) bool {
+		return v.Type().String() == "*net/http.Request"
+	})
+	if req != nil {
+		return codegen.HttpRequestContext(req.Name())
+	}
+
+	return codegen.BackgroundContext()
+}
+
+// PrependToParams adds a context parameter as the first parameter of a function, so that a transaction can be passed to it
+// in a context. The parameter is named ctx, unless that name is already used in the function.
+// Functions that already accept a context or a transaction are not changed, and false is returned.
+func PrependToParams(pkg *decorator.Package, funcType *dst.FuncType, body *dst.BlockStmt) (TraceObject, string, bool) {
+	if obj, _ := getTracingParameter(pkg, funcType.Params.List); obj != nil {
+		return nil, "", false
+	}
+
+	name := codegen.DefaultContextParameter
+	used := false
+	nodes := []dst.Node{funcType}
+	if body != nil {
+		nodes = append(nodes, body)
+	}
+	for _, node := range nodes {
+		dst.Inspect(node, func(n dst.Node) bool {
+			ident, ok := n.(*dst.Ident)
+			if ok && ident.Name == name {
+				used = true
+			}
+			return !used
+		})
+	}
+	if used {
+		name = "nrCtx"
+	}
+
+	// parameter names must be used for all parameters or none of them
+	for _, field := range funcType.Params.List {
+		if len(field.Names) == 0 {
+			field.Names = []*dst.Ident{dst.NewIdent("_")}
+		}
+	}
+	funcType.Params.List = append([]*dst.Field{codegen.NewContextParameter(name)}, funcType.Params.List...)
+	return NewContext(name), codegen.NewRelicAgentImportPath, true
+}
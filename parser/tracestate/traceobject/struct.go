@@ -0,0 +1,280 @@
+package traceobject
+
+import (
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+const (
+	transactionPointerType = "*" + codegen.NewRelicAgentImportPath + ".Transaction"
+)
+
+// carrierKind is the way that a struct carries a transaction.
+type carrierKind int
+
+const (
+	transactionField carrierKind = iota // an exported field of type *newrelic.Transaction
+	contextField                        // an exported field of type context.Context
+)
+
+// ignoredCarriers are struct types that carry a context, but are instrumented explicitly by an integration.
+var ignoredCarriers = map[string]bool{
+	"net/http.Request": true,
+}
+
+// Struct is a trace object for a struct parameter that carries a transaction, such as a request struct or a
+// wrapper around a context. The transaction is carried in an exported field of type *newrelic.Transaction or
+// context.Context, which is set by the callers of the function.
+type Struct struct {
+	parameterName string      // the name of the struct parameter for a function declaration
+	accessor      string      // the name of the field that carries the transaction
+	kind          carrierKind // how the transaction is carried
+}
+
+// structCarrier returns the name of the field that a struct type carries a transaction in, and how it is carried.
+// Pointers to structs are followed. Transaction fields are preferred over context fields.
+func structCarrier(t types.Type) (string, carrierKind, bool) {
+	st := carrierStruct(t)
+	if st == nil {
+		return "", 0, false
+	}
+	if name, ok := exportedField(st, transactionPointerType); ok {
+		return name, transactionField, true
+	}
+	if name, ok := exportedField(st, contextType); ok {
+		return name, contextField, true
+	}
+	return "", 0, false
+}
+
+// contextCarrier returns the name of the exported context.Context field of a struct type, following pointers.
+func contextCarrier(t types.Type) (string, bool) {
+	st := carrierStruct(t)
+	if st == nil {
+		return "", false
+	}
+	return exportedField(st, contextType)
+}
+
+// carrierStruct returns the struct type of a named struct, or a pointer to one, that may carry a transaction.
+func carrierStruct(t types.Type) *types.Struct {
+	if t == nil {
+		return nil
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || ignoredCarriers[named.Obj().Pkg().Path()+"."+named.Obj().Name()] {
+		return nil
+	}
+	st, _ := named.Underlying().(*types.Struct)
+	return st
+}
+
+// exportedField returns the name of the first exported field of a struct with the given type.
+func exportedField(st *types.Struct, fieldType string) (string, bool) {
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if field.Exported() && field.Type().String() == fieldType {
+			return field.Name(), true
+		}
+	}
+	return "", false
+}
+
+// CarrierParameter returns the index of the parameter of a function that can carry a transaction, or -1 if it does not
+// have one. This is the first named struct parameter that carries a transaction in a field. Functions that accept a
+// context or a transaction are passed the transaction in that parameter instead.
+func CarrierParameter(sig *types.Signature) int {
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		switch params.At(i).Type().String() {
+		case contextType, transactionPointerType:
+			return -1
+		}
+	}
+	for i := 0; i < params.Len(); i++ {
+		if sig.Variadic() && i == params.Len()-1 {
+			break
+		}
+		param := params.At(i)
+		if param.Name() == "" || param.Name() == "_" {
+			continue
+		}
+		if _, _, ok := structCarrier(param.Type()); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// carrierParameter returns a struct trace object for the parameter of a function that can carry a transaction, or nil if
+// it does not have one. It is the same parameter that CarrierParameter returns for the signature of the function.
+func carrierParameter(pkg *decorator.Package, params []*dst.Field) *Struct {
+	for _, param := range params {
+		if _, ok := param.Type.(*dst.Ellipsis); ok {
+			return nil
+		}
+		for _, name := range param.Names {
+			if name.Name == "_" {
+				continue
+			}
+			accessor, kind, ok := structCarrier(util.TypeOf(param.Type, pkg))
+			if ok {
+				return &Struct{parameterName: name.Name, accessor: accessor, kind: kind}
+			}
+		}
+	}
+	return nil
+}
+
+// IsCarrierLiteral returns true if an argument is a struct literal, or the address of one, that a transaction can be
+// added to by AddToCarrierArgument.
+func IsCarrierLiteral(arg dst.Expr) bool {
+	return compositeLiteral(arg) != nil
+}
+
+// AddToCarrierArgument passes a transaction to a function call in the struct argument at index, which must carry the
+// transaction in a field. The transaction is added to struct literals:
+//
+//	handle(&Request{ID: id, Ctx: newrelic.NewContext(ctx, nrTxn)})
+//
+// and a struct parameter of a function that was passed the transaction in it is passed as it is. Returns false if the
+// transaction can not be passed in the argument.
+func AddToCarrierArgument(obj TraceObject, pkg *decorator.Package, call *dst.CallExpr, index int, transactionVariableName string) (AddToCallReturn, bool) {
+	if index < 0 || index >= len(call.Args) {
+		return AddToCallReturn{}, false
+	}
+	arg := call.Args[index]
+
+	// the struct we know carries the transaction is being passed to the function call
+	if s, ok := obj.(*Struct); ok {
+		if ident, ok := arg.(*dst.Ident); ok && ident.Path == "" && ident.Name == s.parameterName {
+			return AddToCallReturn{TraceObject: &Struct{}}, true
+		}
+	}
+
+	accessor, kind, ok := structCarrier(util.TypeOf(arg, pkg))
+	lit := compositeLiteral(arg)
+	if !ok || lit == nil {
+		return AddToCallReturn{}, false
+	}
+
+	setCarrierField(pkg, lit, accessor, kind, dst.NewIdent(transactionVariableName))
+	return AddToCallReturn{
+		TraceObject: &Struct{},
+		Import:      codegen.NewRelicAgentImportPath,
+		NeedsTx:     true,
+	}, true
+}
+
+// compositeLiteral returns the composite literal of an expression, or of the address it is taken from.
+func compositeLiteral(expr dst.Expr) *dst.CompositeLit {
+	if unary, ok := expr.(*dst.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+	lit, _ := expr.(*dst.CompositeLit)
+	return lit
+}
+
+// setCarrierField sets the field of a struct literal that carries a transaction. A context field is wrapped in a new
+// context containing the transaction, or set to one derived from the context returned by RequestContext if it is not set.
+func setCarrierField(pkg *decorator.Package, lit *dst.CompositeLit, field string, kind carrierKind, txn dst.Expr) {
+	value := func(existing dst.Expr) dst.Expr {
+		if kind == transactionField {
+			return txn
+		}
+		if existing == nil {
+			existing = RequestContext(lit, pkg)
+		}
+		return codegen.NewContextExpression(existing, txn)
+	}
+
+	for i, elt := range lit.Elts {
+		kv, ok := elt.(*dst.KeyValueExpr)
+		if !ok {
+			// values of struct literals without keys are set in the order of the fields
+			if index := fieldIndex(util.TypeOf(lit, pkg), field); index == i {
+				lit.Elts[i] = value(elt)
+				return
+			}
+			continue
+		}
+		if key, ok := kv.Key.(*dst.Ident); ok && key.Name == field {
+			kv.Value = value(kv.Value)
+			return
+		}
+	}
+
+	if len(lit.Elts) == 0 || isKeyValue(lit.Elts[0]) {
+		lit.Elts = append(lit.Elts, &dst.KeyValueExpr{Key: dst.NewIdent(field), Value: value(nil)})
+	}
+}
+
+func isKeyValue(expr dst.Expr) bool {
+	_, ok := expr.(*dst.KeyValueExpr)
+	return ok
+}
+
+// fieldIndex returns the index of a field in a struct type, or -1 if it is not a field of the struct.
+func fieldIndex(t types.Type, name string) int {
+	if t == nil {
+		return -1
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return -1
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddToCall passes the transaction carried by the struct to the function call like a transaction. Calls to functions
+// that are passed the transaction in a struct are handled by AddToCarrierArgument.
+func (s *Struct) AddToCall(pkg *decorator.Package, call *dst.CallExpr, transactionVariableName string, async bool) AddToCallReturn {
+	return NewTransaction().AddToCall(pkg, call, transactionVariableName, async)
+}
+
+// AddToFuncDecl reads the transaction from the struct parameter of the function declaration that carries it. If it
+// does not have one, a transaction parameter is added to it.
+func (s *Struct) AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl) (TraceObject, string) {
+	if obj, goGet := getTracingParameter(pkg, decl.Type.Params.List); obj != nil {
+		return obj, goGet
+	}
+	if obj := carrierParameter(pkg, decl.Type.Params.List); obj != nil {
+		return obj, ""
+	}
+	return NewTransaction().AddToFuncDecl(pkg, decl)
+}
+
+// AddToFuncLit adds the transaction as a parameter to the function literal if it does not already accept one.
+func (s *Struct) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit) (TraceObject, string) {
+	return NewTransaction().AddToFuncLit(pkg, lit)
+}
+
+// AssignTransactionVariable assigns the transaction carried by the struct to a variable.
+func (s *Struct) AssignTransactionVariable(variableName string) (dst.Stmt, string) {
+	carrier := &dst.SelectorExpr{
+		X:   dst.NewIdent(s.parameterName),
+		Sel: dst.NewIdent(s.accessor),
+	}
+
+	if s.kind == transactionField {
+		return &dst.AssignStmt{
+			Lhs: []dst.Expr{dst.NewIdent(variableName)},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{carrier},
+		}, ""
+	}
+	return codegen.TxnFromContext(variableName, carrier), ""
+}
@@ -0,0 +1,153 @@
+package traceobject
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_structCarrier(t *testing.T) {
+	app := types.NewPackage("example.com/app", "app")
+	contextNamed := types.NewNamed(types.NewTypeName(token.NoPos, types.NewPackage("context", "context"), "Context", nil), types.NewInterfaceType(nil, nil), nil)
+	transactionNamed := types.NewNamed(types.NewTypeName(token.NoPos, types.NewPackage(codegen.NewRelicAgentImportPath, "newrelic"), "Transaction", nil), types.NewStruct(nil, nil), nil)
+
+	newStruct := func(pkg *types.Package, name string, fields ...*types.Var) *types.Named {
+		return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), types.NewStruct(fields, nil), nil)
+	}
+	field := func(name string, typ types.Type) *types.Var {
+		return types.NewField(token.NoPos, app, name, typ, false)
+	}
+
+	withMethod := newStruct(app, "Session", field("ctx", contextNamed))
+	recv := types.NewParam(token.NoPos, app, "s", types.NewPointer(withMethod))
+	results := types.NewTuple(types.NewParam(token.NoPos, app, "", contextNamed))
+	withMethod.AddMethod(types.NewFunc(token.NoPos, app, "Context", types.NewSignatureType(recv, nil, nil, nil, results, false)))
+
+	tests := []struct {
+		name         string
+		typ          types.Type
+		wantAccessor string
+		wantKind     carrierKind
+		wantOk       bool
+	}{
+		{
+			name:         "struct with a context field",
+			typ:          newStruct(app, "Request", field("ID", types.Typ[types.Int]), field("Ctx", contextNamed)),
+			wantAccessor: "Ctx",
+			wantKind:     contextField,
+			wantOk:       true,
+		},
+		{
+			name:         "pointer to a struct with a transaction field is preferred over its context field",
+			typ:          types.NewPointer(newStruct(app, "Job", field("Ctx", contextNamed), field("Txn", types.NewPointer(transactionNamed)))),
+			wantAccessor: "Txn",
+			wantKind:     transactionField,
+			wantOk:       true,
+		},
+		{
+			name: "a Context method does not carry a transaction, since callers can not set it",
+			typ:  types.NewPointer(withMethod),
+		},
+		{
+			name: "unexported fields do not carry a transaction",
+			typ:  newStruct(app, "Request", field("ctx", contextNamed)),
+		},
+		{
+			name: "http requests are instrumented by the net/http integration",
+			typ:  types.NewPointer(newStruct(types.NewPackage("net/http", "http"), "Request", field("Ctx", contextNamed))),
+		},
+		{
+			name: "basic types do not carry a transaction",
+			typ:  types.Typ[types.String],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessor, kind, ok := structCarrier(tt.typ)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantAccessor, accessor)
+			assert.Equal(t, tt.wantKind, kind)
+		})
+	}
+}
+
+func TestStruct_AssignTransactionVariable(t *testing.T) {
+	tests := []struct {
+		name   string
+		object *Struct
+		want   dst.Stmt
+	}{
+		{
+			name:   "transaction field",
+			object: &Struct{parameterName: "job", accessor: "Txn", kind: transactionField},
+			want: &dst.AssignStmt{
+				Lhs: []dst.Expr{dst.NewIdent("nrTxn")},
+				Tok: token.DEFINE,
+				Rhs: []dst.Expr{&dst.SelectorExpr{X: dst.NewIdent("job"), Sel: dst.NewIdent("Txn")}},
+			},
+		},
+		{
+			name:   "context field",
+			object: &Struct{parameterName: "req", accessor: "Ctx", kind: contextField},
+			want:   codegen.TxnFromContext("nrTxn", &dst.SelectorExpr{X: dst.NewIdent("req"), Sel: dst.NewIdent("Ctx")}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, imp := tt.object.AssignTransactionVariable("nrTxn")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, "", imp)
+		})
+	}
+}
+
+func TestCarrierParameter(t *testing.T) {
+	app := types.NewPackage("example.com/app", "app")
+	contextNamed := types.NewNamed(types.NewTypeName(token.NoPos, types.NewPackage("context", "context"), "Context", nil), types.NewInterfaceType(nil, nil), nil)
+	request := types.NewPointer(types.NewNamed(types.NewTypeName(token.NoPos, app, "Request", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, app, "Ctx", contextNamed, false),
+	}, nil), nil))
+
+	param := func(name string, typ types.Type) *types.Var {
+		return types.NewParam(token.NoPos, app, name, typ)
+	}
+	signature := func(variadic bool, params ...*types.Var) *types.Signature {
+		return types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), nil, variadic)
+	}
+
+	tests := []struct {
+		name string
+		sig  *types.Signature
+		want int
+	}{
+		{
+			name: "named struct parameter",
+			sig:  signature(false, param("id", types.Typ[types.Int]), param("req", request)),
+			want: 1,
+		},
+		{
+			name: "functions that accept a context are passed the transaction in it",
+			sig:  signature(false, param("ctx", contextNamed), param("req", request)),
+			want: -1,
+		},
+		{
+			name: "unnamed parameters can not be read",
+			sig:  signature(false, param("_", request)),
+			want: -1,
+		},
+		{
+			name: "variadic parameters are not a single struct",
+			sig:  signature(true, param("reqs", types.NewSlice(request))),
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CarrierParameter(tt.sig))
+		})
+	}
+}
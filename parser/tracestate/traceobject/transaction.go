@@ -27,17 +27,28 @@ func transactionReturn() AddToCallReturn {
 	}
 }
 
+// isTransactionArgument returns true if an argument passes the transaction, or a copy of it for a goroutine.
 func isTransactionArgument(arg dst.Expr, transactionVariable string) bool {
-	ident, ok := arg.(*dst.Ident)
-	if !ok {
-		return false
+	switch v := arg.(type) {
+	case *dst.Ident:
+		return v.Name == transactionVariable
+	case *dst.CallExpr:
+		// nrTxn.NewGoroutine()
+		sel, ok := v.Fun.(*dst.SelectorExpr)
+		return ok && sel.Sel.Name == "NewGoroutine" && isTransactionArgument(sel.X, transactionVariable)
 	}
+	return false
+}
 
-	if ident.Name == transactionVariable {
-		return true
+// isTransactionContext returns true if an argument is a context that the transaction was already added to, like the
+// contexts created by codegen.WrapContextExpression.
+func isTransactionContext(arg dst.Expr, transactionVariable string) bool {
+	call, ok := arg.(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return false
 	}
-
-	return false
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && ident.Name == "NewContext" && ident.Path == codegen.NewRelicAgentImportPath && isTransactionArgument(call.Args[1], transactionVariable)
 }
 
 // AddToCall adds the transaction as an argument to the function call.
@@ -51,6 +62,15 @@ func (txn *Transaction) AddToCall(pkg *decorator.Package, call *dst.CallExpr, tr
 			return transactionReturn()
 		}
 
+		// the transaction was already injected into the context passed to the call
+		if isTransactionContext(arg, transactionVariable) {
+			return AddToCallReturn{
+				TraceObject: NewContext(),
+				Import:      codegen.NewRelicAgentImportPath,
+				NeedsTx:     true,
+			}
+		}
+
 		// if the call already contains a context, inject a transaction into it rather than adding an argument
 		if typ != nil && typ.String() == contextType {
 			call.Args[i] = codegen.WrapContextExpression(arg, transactionVariable, async)
@@ -76,7 +96,11 @@ func (txn *Transaction) AddToCall(pkg *decorator.Package, call *dst.CallExpr, tr
 
 func getTracingParameter(pkg *decorator.Package, params []*dst.Field) (TraceObject, string) {
 	for _, param := range params {
-		ident, ok := param.Type.(*dst.Ident)
+		paramType := param.Type
+		if star, ok := paramType.(*dst.StarExpr); ok {
+			paramType = star.X
+		}
+		ident, ok := paramType.(*dst.Ident)
 		if ok && ident.Name == "Transaction" && ident.Path == codegen.NewRelicAgentImportPath {
 			return NewTransaction(), codegen.NewRelicAgentImportPath
 		}
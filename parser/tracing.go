@@ -2,14 +2,18 @@ package parser
 
 import (
 	"fmt"
+	"go/token"
+	"go/types"
 	"reflect"
 	"slices"
 
 	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
 	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 )
 
@@ -18,8 +22,18 @@ import (
 // This function returns a FuncDecl object pointer that contains the potentially modified version of the FuncDecl object, fn, passed. If
 // the bool field is true, then the function was modified, and requires a transaction most likely.
 //
-// This function can accept a FuncDecl or FuncLit object for the node only.
+// This function can accept a FuncDecl or FuncLit object for the node only. Function declarations traced by it are the
+// entry points of the call graph, so it must only be called for the roots of tracing, such as main and handlers.
 func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *tracestate.State) (dst.Node, bool) {
+	if decl, ok := node.(*dst.FuncDecl); ok {
+		manager.addCallGraphEntryPoint(manager.getPackageName(), decl)
+	}
+	return traceFunction(manager, node, tracing)
+}
+
+// traceFunction adds tracing to a function like TraceFunction, without making it an entry point of the call graph.
+// It is used to trace the functions called by a traced function.
+func traceFunction(manager *InstrumentationManager, node dst.Node, tracing *tracestate.State) (dst.Node, bool) {
 	TopLevelFunctionChanged := false
 	nodeType := reflect.TypeOf(node)
 	if nodeType != reflect.TypeOf(&dst.FuncDecl{}) && nodeType != reflect.TypeOf(&dst.FuncLit{}) {
@@ -35,6 +49,8 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 
 	if isFuncDecl {
 		comment.Debug(manager.getDecoratorPackage(), node, fmt.Sprintf("TraceFunction called for function decl: %s", decl.Name.Name))
+		tracing.SetFunctionName(functionKey(decl))
+		tracing.SetSegmentName(manager.segmentName(decl))
 		funcType = decl.Type
 		funcBody = decl.Body
 	} else if isFuncLit {
@@ -45,6 +61,16 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 		return node, false
 	}
 
+	// functions traced by a previous run are not traced again
+	if isTracedFunction(funcBody) {
+		comment.Debug(manager.getDecoratorPackage(), node, "Function was already traced")
+		return node, false
+	}
+
+	if manager.options.Propagation == PropagateContext {
+		tracing.PropagateWithContext()
+	}
+
 	// Check if the function already has a transaction parameter
 	if !manager.hasTransactionParameter(funcType) {
 		tracingImport, ok := tracing.AddParameterToDeclaration(manager.getDecoratorPackage(), node)
@@ -81,6 +107,11 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 		return true
 	}, nil)
 
+	// Recover panics that would otherwise end the program before the transaction is ended
+	if recoverPanics(manager, node, tracing) {
+		TopLevelFunctionChanged = true
+	}
+
 	// Create segment if needed
 	if !hasSegment {
 		segmentImport, ok := tracing.CreateSegment(node)
@@ -96,8 +127,15 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 		case *dst.BlockStmt, *dst.ForStmt:
 			return true
 		case *dst.GoStmt:
+			if isTracedGoroutine(v) {
+				return false
+			}
 			if tracing.IsMain() {
-				comment.Info(manager.getDecoratorPackage(), v, v, fmt.Sprintf("%s doesn't support tracing goroutines in a main method; please instrument manually.", common.ApplicationName), "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines")
+				if manager.options.TraceMainGoroutines && traceMainGoroutine(manager, v, c, tracing) {
+					TopLevelFunctionChanged = true
+					return false
+				}
+				comment.Info(manager.getDecoratorPackage(), goroutineInMainRule, v, v, fmt.Sprintf("%s doesn't support tracing goroutines in a main method; please instrument manually.", common.ApplicationName), goroutineInMainRule.Docs)
 				return false
 			}
 			switch fun := v.Call.Fun.(type) {
@@ -121,24 +159,32 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 				return false
 
 			default:
-				rootPkg := manager.currentPackage
 				tracableInvocations := manager.findInvocationInfo(v.Call, tracing)
 				for _, invInfo := range tracableInvocations {
+					if passesTransaction(invInfo.call) || !manager.useTransactionVariant(invInfo) {
+						continue
+					}
 					childState, tracingImport := tracing.AddToCall(manager.getDecoratorPackage(), v.Call, true)
 					manager.addImport(tracingImport)
 					c.Replace(v)
 					TopLevelFunctionChanged = true
-
-					if manager.shouldInstrumentFunction(invInfo) {
-						manager.setPackage(invInfo.packageName)
-						comment.Debug(manager.getDecoratorPackage(), node, fmt.Sprintf("Tracing function: %s", invInfo.decl.Name.Name))
-						TraceFunction(manager, invInfo.decl, childState)
-						manager.setPackage(rootPkg)
-					}
+					manager.traceInvocation(invInfo, childState)
 				}
 			}
 
 		case dst.Stmt:
+			// statements generated by a previous run are not instrumented again
+			if manager.generatedCode().isStatement(v) {
+				return false
+			}
+
+			// function literals run in a new goroutine by a library are traced like goroutines, and their
+			// body must not be traced again with the state of this function
+			if traceAsyncLaunchSite(manager, v, tracing) {
+				TopLevelFunctionChanged = true
+				return false
+			}
+
 			downstreamFunctionTraced := false
 			assign, ok := v.(*dst.AssignStmt)
 			if ok && len(assign.Rhs) == 1 && len(assign.Lhs) == 1 {
@@ -157,7 +203,6 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 				}
 			}
 
-			rootPkg := manager.currentPackage
 			tracableInvocations := manager.findInvocationInfo(v, tracing)
 			transactionCreatedForStatement := false // prevent multiple transactions from being created for the same statement
 
@@ -165,7 +210,15 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 			for _, invInfo := range tracableInvocations {
 				// If the current function is the function that declares the NR App, we do not want to propagate tracing to it
 				// Additionally, if the function is already being traced by an existing transaction we can skip it
-				if manager.setupFunc == invInfo.decl || manager.transactionCache.IsFunctionInTransactionScope(invInfo.functionName) {
+				if (invInfo.decl != nil && manager.setupFunc == invInfo.decl) || manager.transactionCache.IsFunctionInTransactionScope(invInfo.functionName) {
+					continue
+				}
+				// calls that were passed the transaction by a previous run are already traced
+				if passesTransaction(invInfo.call) {
+					continue
+				}
+				// functions whose signature can not be changed are passed the transaction through a variant of the function
+				if !manager.useTransactionVariant(invInfo) {
 					continue
 				}
 
@@ -174,15 +227,18 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 					tracing.WrapWithTransaction(c, invInfo.functionName, codegen.DefaultTransactionVariable)
 					transactionCreatedForStatement = true
 				}
-				childState, tracingImport := tracing.AddToCall(manager.getDecoratorPackage(), invInfo.call, false)
+				var childState *tracestate.State
+				var tracingImport string
+				if index, ok := manager.carrierParameter(invInfo); ok {
+					childState, tracingImport = tracing.AddToCarrierCall(manager.getDecoratorPackage(), invInfo.call, index)
+				} else {
+					childState, tracingImport = tracing.AddToCall(manager.getDecoratorPackage(), invInfo.call, false)
+				}
 				manager.addImport(tracingImport)
 				TopLevelFunctionChanged = true
 				// If not present, wrap the function with a transaction
-				if manager.shouldInstrumentFunction(invInfo) {
-					manager.setPackage(invInfo.packageName)
-					TraceFunction(manager, invInfo.decl, childState)
+				if manager.traceInvocation(invInfo, childState) {
 					downstreamFunctionTraced = true
-					manager.setPackage(rootPkg)
 				}
 			}
 
@@ -208,7 +264,7 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 	// Check if error cache is still full, if so add unchecked error warning
 	if manager.errorCache.GetExpression() != nil {
 		stmt := manager.errorCache.GetStatement()
-		comment.Warn(manager.getDecoratorPackage(), stmt, stmt, "Unchecked Error, please consult New Relic documentation on error capture", "https://docs.newrelic.com/docs/apm/agents/go-agent/api-guides/guide-using-go-agent-api/#errors")
+		comment.Warn(manager.getDecoratorPackage(), uncheckedErrorRule, stmt, stmt, "Unchecked Error, please consult New Relic documentation on error capture", uncheckedErrorRule.Docs)
 		manager.errorCache.Clear()
 	}
 
@@ -220,6 +276,234 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 	return outputNode, TopLevelFunctionChanged
 }
 
+// segmentName returns the name of the segment created for a function declaration in the current package.
+// Variants that accept a transaction are named after the function they were created for.
+func (m *InstrumentationManager) segmentName(decl *dst.FuncDecl) string {
+	if original, ok := m.variantOriginals[decl]; ok {
+		decl = original
+	}
+	switch m.options.SegmentNames {
+	case SegmentNameMethod:
+		return functionKey(decl)
+	case SegmentNameQualified:
+		return m.getDecoratorPackage().Name + "." + functionKey(decl)
+	}
+	return decl.Name.Name
+}
+
+// traceMainGoroutine starts a background transaction for a goroutine launched from main that calls a function
+// defined in the application. The transaction is named after the function and ended when the goroutine returns.
+// If the function returns an error, it is noticed on the transaction:
+//
+//	go func(nrTxn *newrelic.Transaction, jobs chan int) {
+//		defer nrTxn.End()
+//		err := worker(jobs, nrTxn)
+//		if err != nil {
+//			nrTxn.NoticeError(err)
+//		}
+//	}(app.StartTransaction("worker"), jobs)
+//
+// The receiver and arguments of the call are passed to the function literal, so that they are still evaluated when
+// the go statement is executed. The function is then traced with the transaction.
+//
+// Returns true if the goroutine was traced.
+func traceMainGoroutine(manager *InstrumentationManager, stmt *dst.GoStmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	var invocation *invocationInfo
+	for _, invInfo := range manager.findInvocationInfo(stmt.Call, tracing) {
+		if invInfo.call == stmt.Call && ((invInfo.decl != nil && invInfo.decl != manager.setupFunc) || len(invInfo.implementations) > 0) {
+			invocation = invInfo
+			break
+		}
+	}
+	if invocation == nil || !manager.useTransactionVariant(invocation) {
+		return false
+	}
+
+	pkg := manager.getDecoratorPackage()
+	returnsError := util.IsError(util.TypeOf(stmt.Call, pkg)) && manager.integrationEnabled(errorsIntegration)
+	arguments, ok := goroutineArguments(stmt, pkg)
+	if !ok {
+		comment.Debug(pkg, stmt, fmt.Sprintf("Not tracing goroutine %s, since its receiver or arguments can not be passed to a function literal", invocation.functionName))
+		return false
+	}
+
+	childState, tracingImport := tracing.AddToCall(pkg, stmt.Call, false)
+	childState.TransactionEndDeferred()
+	manager.addImport(tracingImport)
+
+	// arguments that the transaction was added to must be evaluated inside of the goroutine
+	inCall := map[dst.Node]bool{}
+	dst.Inspect(stmt.Call, func(n dst.Node) bool {
+		inCall[n] = true
+		return true
+	})
+	arguments = slices.DeleteFunc(arguments, func(arg goroutineArgument) bool {
+		return !inCall[arg.value] || references(arg.value, codegen.DefaultTransactionVariable)
+	})
+	params := []*dst.Field{}
+	args := []dst.Expr{}
+	replacements := map[dst.Node]string{}
+	for _, arg := range arguments {
+		params = append(params, &dst.Field{Names: []*dst.Ident{dst.NewIdent(arg.name)}, Type: arg.typ})
+		if arg.passed != nil {
+			args = append(args, arg.passed)
+		} else {
+			args = append(args, arg.value)
+		}
+		replacements[arg.value] = arg.name
+	}
+	call := dstutil.Apply(stmt.Call, func(c *dstutil.Cursor) bool {
+		if name, ok := replacements[c.Node()]; ok {
+			c.Replace(dst.NewIdent(name))
+			return false
+		}
+		return true
+	}, nil).(*dst.CallExpr)
+
+	var body []dst.Stmt
+	if returnsError {
+		errVariable := dst.NewIdent("err")
+		body = []dst.Stmt{
+			&dst.AssignStmt{
+				Lhs: []dst.Expr{errVariable},
+				Tok: token.DEFINE,
+				Rhs: []dst.Expr{call},
+			},
+			codegen.IfErrorNotNilNoticeError(errVariable, dst.NewIdent(codegen.DefaultTransactionVariable), manager.errorRules()),
+		}
+	} else {
+		body = []dst.Stmt{&dst.ExprStmt{X: call}}
+	}
+
+	goroutine := codegen.TransactionGoroutine(tracing.AgentVariable(), invocation.functionName, body...)
+	lit := goroutine.Call.Fun.(*dst.FuncLit)
+	lit.Type.Params.List = append(lit.Type.Params.List, params...)
+	goroutine.Call.Args = append(goroutine.Call.Args, args...)
+	goroutine.Decs.NodeDecs = stmt.Decs.NodeDecs
+	comment.Debug(pkg, stmt, fmt.Sprintf("Starting a transaction for goroutine: %s", invocation.functionName))
+	c.Replace(goroutine)
+	manager.addImport(codegen.NewRelicAgentImportPath)
+
+	manager.traceInvocation(invocation, childState)
+	return true
+}
+
+// goroutineArgument is an argument of the call in a go statement that is passed to the function literal that
+// replaces it, as a parameter with the given name and type.
+type goroutineArgument struct {
+	value  dst.Expr
+	name   string
+	typ    dst.Expr
+	passed dst.Expr // the expression passed to the parameter, if it is not value
+}
+
+// goroutineArguments returns the arguments of the call in a go statement that need to be evaluated when the go statement
+// is executed. The receiver of a method is one of them, and is passed by address if the method has a pointer receiver,
+// so that the method is still called on the same value. Constants and functions are left in the call. Parameters that
+// are passed a variable are named after it. Returns false if the type of an argument can not be declared in the package,
+// or if the function called is a field of a struct.
+func goroutineArguments(stmt *dst.GoStmt, pkg *decorator.Package) ([]goroutineArgument, bool) {
+	sig, ok := util.TypeOf(stmt.Call.Fun, pkg).(*types.Signature)
+	if !ok {
+		return nil, false
+	}
+
+	// the number of times each name is used in the go statement
+	used := map[string]int{codegen.DefaultTransactionVariable: 1}
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok {
+			used[ident.Name]++
+		}
+		return true
+	})
+
+	var arguments []goroutineArgument
+	if sel, ok := stmt.Call.Fun.(*dst.SelectorExpr); ok {
+		receiver, ok := goroutineReceiver(sel, pkg, used)
+		if !ok {
+			return nil, false
+		}
+		if receiver != nil {
+			arguments = append(arguments, *receiver)
+		}
+	}
+
+	for i, arg := range stmt.Call.Args {
+		if _, isFuncLit := arg.(*dst.FuncLit); isFuncLit || util.IsConstant(arg, pkg) {
+			continue
+		}
+		ident, isIdent := arg.(*dst.Ident)
+		if isIdent {
+			if _, isFunc := util.ObjectOf(ident, pkg).(*types.Func); isFunc {
+				continue
+			}
+		}
+
+		var t types.Type
+		switch params := sig.Params(); {
+		case i >= params.Len()-1 && sig.Variadic() && !stmt.Call.Ellipsis:
+			t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		case i < params.Len():
+			t = params.At(i).Type()
+		default:
+			return nil, false
+		}
+		typ, ok := util.TypeExpression(t, pkg)
+		if !ok {
+			return nil, false
+		}
+
+		arguments = append(arguments, goroutineArgument{value: arg, name: goroutineParameterName(arg, pkg, used), typ: typ})
+	}
+	return arguments, true
+}
+
+// goroutineReceiver returns the receiver of a method called in a go statement. It returns nil if the selector
+// is a method expression, which is called on a type rather than a value.
+func goroutineReceiver(sel *dst.SelectorExpr, pkg *decorator.Package, used map[string]int) (*goroutineArgument, bool) {
+	selection := util.Selection(sel, pkg)
+	if selection == nil || selection.Kind() == types.MethodExpr {
+		return nil, true
+	}
+	if selection.Kind() != types.MethodVal {
+		return nil, false
+	}
+
+	recv := util.TypeOf(sel.X, pkg)
+	if recv == nil {
+		return nil, false
+	}
+	value := sel.X
+	_, isPointer := recv.Underlying().(*types.Pointer)
+	if _, pointerMethod := selection.Obj().Type().(*types.Signature).Recv().Type().(*types.Pointer); pointerMethod && !isPointer {
+		recv = types.NewPointer(recv)
+		value = &dst.UnaryExpr{Op: token.AND, X: sel.X}
+	}
+
+	typ, ok := util.TypeExpression(recv, pkg)
+	if !ok {
+		return nil, false
+	}
+	return &goroutineArgument{value: sel.X, name: goroutineParameterName(sel.X, pkg, used), typ: typ, passed: value}, true
+}
+
+// goroutineParameterName returns the name of the parameter that arg is passed to. A variable that is only used as
+// this argument can be shadowed by the parameter, so it is named after it.
+func goroutineParameterName(arg dst.Expr, pkg *decorator.Package, used map[string]int) string {
+	if ident, ok := arg.(*dst.Ident); ok {
+		if _, isVar := util.ObjectOf(ident, pkg).(*types.Var); isVar && used[ident.Name] == 1 {
+			return ident.Name
+		}
+	}
+
+	name := "arg"
+	for j := 2; used[name] > 0; j++ {
+		name = fmt.Sprintf("arg%d", j)
+	}
+	used[name]++
+	return name
+}
+
 // hasTransactionParameter checks if a function has a transaction parameter
 // by examining the function's parameter list for any parameter names that exist
 // in the transaction cache.
@@ -0,0 +1,780 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagateWithContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "add a context as the first parameter of traced functions",
+			code: `package main
+
+import "net/http"
+
+func fetch(url string) error {
+	_, err := http.Get(url)
+	return err
+}
+
+func run(url string) error {
+	return fetch(url)
+}
+
+func main() {
+	run("http://example.com")
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func fetch(ctx context.Context, url string) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	_, err := http.Get(url)
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func run(ctx context.Context, url string) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("run").End()
+
+	return fetch(ctx, url)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(newrelic.NewContext(context.Background(), nrTxn), "http://example.com")
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "functions that accept a context are passed it",
+			code: `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func work(ctx context.Context, id int) {
+	fmt.Println(id)
+}
+
+func run(id int) {
+	work(context.Background(), id)
+	go work(context.TODO(), id)
+}
+
+func main() {
+	run(1)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(ctx context.Context, id int) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("work").End()
+
+	fmt.Println(id)
+}
+
+func run(ctx context.Context, id int) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("run").End()
+
+	work(newrelic.NewContext(context.Background(), nrTxn), id)
+	go work(newrelic.NewContext(context.TODO(), nrTxn.NewGoroutine()), id)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("run")
+	run(newrelic.NewContext(context.Background(), nrTxn), 1)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "variant of a method that implements an interface accepts a context",
+			code: `package main
+
+import "fmt"
+
+type config struct {
+	name string
+}
+
+func (c *config) String() string {
+	return fmt.Sprintf("config %s", c.name)
+}
+
+func main() {
+	c := &config{name: "test"}
+	fmt.Println(c.String())
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type config struct {
+	name string
+}
+
+func (c *config) String() string {
+	return c.StringCtx(context.Background())
+}
+
+// StringCtx is String with a New Relic transaction passed to it.
+// The signature of String is preserved because it implements fmt.Stringer.
+func (c *config) StringCtx(ctx context.Context) string {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("String").End()
+
+	return fmt.Sprintf("config %s", c.name)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	c := &config{name: "test"}
+	nrTxn := NewRelicAgent.StartTransaction("String")
+	fmt.Println(c.StringCtx(newrelic.NewContext(context.Background(), nrTxn)))
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, tt.code, Options{Propagation: PropagateContext}, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestPropagateWithContextInHandler(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func work(name string) {
+	fmt.Println(name)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	work("index")
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+	expect := `package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(ctx context.Context, name string) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("work").End()
+
+	fmt.Println(name)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	work(newrelic.NewContext(r.Context(), nrTxn), "index")
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+	defer panicRecovery(t)
+	got := testStatelessTracingFunctionWithOptions(t, code, Options{Propagation: PropagateContext}, InstrumentHandleFunction)
+	assert.Equal(t, expect, got)
+}
+
+func TestPropagateWithContextInStruct(t *testing.T) {
+	code := `package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type Request struct {
+	Ctx context.Context
+	ID  int
+}
+
+func step(id int) {
+	fmt.Println(id)
+}
+
+func handle(req *Request) {
+	step(req.ID)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	handle(&Request{Ctx: r.Context(), ID: 1})
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+	expect := `package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Request struct {
+	Ctx context.Context
+	ID  int
+}
+
+func step(ctx context.Context, id int) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("step").End()
+
+	fmt.Println(id)
+}
+
+func handle(req *Request) {
+	nrTxn := newrelic.FromContext(req.Ctx)
+	defer nrTxn.StartSegment("handle").End()
+
+	step(newrelic.NewContext(req.Ctx, nrTxn), req.ID)
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	handle(&Request{Ctx: newrelic.NewContext(r.Context(), nrTxn), ID: 1})
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+	defer panicRecovery(t)
+	got := testStatelessTracingFunctionWithOptions(t, code, Options{Propagation: PropagateContext}, InstrumentHandleFunction)
+	assert.Equal(t, expect, got)
+}
+
+func TestParsePropagationStrategy(t *testing.T) {
+	got, err := ParsePropagationStrategy("context")
+	assert.NoError(t, err)
+	assert.Equal(t, PropagateContext, got)
+
+	got, err = ParsePropagationStrategy("transaction")
+	assert.NoError(t, err)
+	assert.Equal(t, PropagateTransaction, got)
+
+	_, err = ParsePropagationStrategy("ctx")
+	assert.Error(t, err)
+}
+
+func TestStructTraceObjects(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "pass the transaction in the context field of a struct",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type Request struct {
+	ID  int
+	Ctx context.Context
+}
+
+func fetch(req *Request) error {
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func handle(req *Request) error {
+	return fetch(req)
+}
+
+func main() {
+	handle(&Request{ID: 1})
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Request struct {
+	ID  int
+	Ctx context.Context
+}
+
+func fetch(req *Request) error {
+	nrTxn := newrelic.FromContext(req.Ctx)
+	defer nrTxn.StartSegment("fetch").End()
+
+	_, err := http.Get("http://example.com")
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func handle(req *Request) error {
+	nrTxn := newrelic.FromContext(req.Ctx)
+	defer nrTxn.StartSegment("handle").End()
+
+	return fetch(req)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("handle")
+	handle(&Request{ID: 1, Ctx: newrelic.NewContext(context.Background(), nrTxn)})
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "pass the transaction in the transaction field of a struct literal without keys",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Job struct {
+	URL string
+	Txn *newrelic.Transaction
+}
+
+func process(job Job) {
+	http.Get(job.URL)
+}
+
+func main() {
+	process(Job{"http://example.com", nil})
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Job struct {
+	URL string
+	Txn *newrelic.Transaction
+}
+
+func process(job Job) {
+	nrTxn := job.Txn
+	defer nrTxn.StartSegment("process").End()
+
+	http.Get(job.URL)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("process")
+	process(Job{"http://example.com", nrTxn})
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "structs with only a Context method are passed a transaction parameter",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type Session struct {
+	ctx context.Context
+}
+
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+func load(s *Session) {
+	http.Get("http://example.com")
+}
+
+func main() {
+	s := &Session{ctx: context.Background()}
+	load(s)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Session struct {
+	ctx context.Context
+}
+
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+func load(s *Session, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("load").End()
+
+	http.Get("http://example.com")
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Session{ctx: context.Background()}
+	nrTxn := NewRelicAgent.StartTransaction("load")
+	load(s, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "structs passed in a variable are passed a transaction parameter",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type Request struct {
+	ID  int
+	Ctx context.Context
+}
+
+func build(id int) *Request {
+	return &Request{ID: id}
+}
+
+func fetch(req *Request) error {
+	_, err := http.Get("http://example.com")
+	return err
+}
+
+func handle(req *Request) error {
+	return fetch(req)
+}
+
+func main() {
+	req := build(1)
+	handle(req)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Request struct {
+	ID  int
+	Ctx context.Context
+}
+
+func build(id int, nrTxn *newrelic.Transaction) *Request {
+	defer nrTxn.StartSegment("build").End()
+
+	return &Request{ID: id}
+}
+
+func fetch(req *Request, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("fetch").End()
+
+	_, err := http.Get("http://example.com")
+
+	if err != nil {
+		nrTxn.NoticeError(err)
+	}
+	return err
+}
+
+func handle(req *Request, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("handle").End()
+
+	return fetch(req, nrTxn)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("build")
+	req := build(1, nrTxn)
+	nrTxn.End()
+	nrTxn = NewRelicAgent.StartTransaction("handle")
+	handle(req, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunction(t, tt.code, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestSegmentNaming(t *testing.T) {
+	code := `package main
+
+type Store struct{}
+
+func (s *Store) Load() {}
+
+func main() {
+	s := &Store{}
+	s.Load()
+}
+`
+	tests := []struct {
+		name   string
+		naming SegmentNaming
+		expect string
+	}{
+		{
+			name: "segments are named after the function by default",
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct{}
+
+func (s *Store) Load(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("Load").End()
+
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{}
+	nrTxn := NewRelicAgent.StartTransaction("Load")
+	s.Load(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:   "method segments include the receiver type",
+			naming: SegmentNameMethod,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct{}
+
+func (s *Store) Load(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("Store.Load").End()
+
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{}
+	nrTxn := NewRelicAgent.StartTransaction("Load")
+	s.Load(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:   "qualified segments include the package name",
+			naming: SegmentNameQualified,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct{}
+
+func (s *Store) Load(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("main.Store.Load").End()
+
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	s := &Store{}
+	nrTxn := NewRelicAgent.StartTransaction("Load")
+	s.Load(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			got := testStatelessTracingFunctionWithOptions(t, code, Options{SegmentNames: tt.naming}, InstrumentMain)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
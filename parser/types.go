@@ -63,7 +63,7 @@ type StatefulTracingFunction func(manager *InstrumentationManager, stmt dst.Stmt
 type StatelessTracingFunction func(manager *InstrumentationManager, c *dstutil.Cursor)
 
 // PreInstrumentationTracingFunction defines a function that is executed before any instrumentation is applied to a code block.
-// These functions are executed on every node in the DST tree of every function declared in an application.
+// These functions are executed on every node in the DST tree of every function and package level declaration in an application.
 type PreInstrumentationTracingFunction func(manager *InstrumentationManager, c *dstutil.Cursor)
 
 // FactDiscoveryFunction identify a "Fact" about a code pattern, which can be referenced later to identify
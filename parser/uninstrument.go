@@ -0,0 +1,604 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// RemoveInstrumentation removes the instrumentation generated by this tool from the application, so that WriteDiff
+// writes a diff that reverts it. Instrumentation that was written by hand is left in place, along with any generated
+// code that uses the variables of an application that was already instrumented.
+func (m *InstrumentationManager) RemoveInstrumentation() error {
+	// the files of a package are also part of its test variant, and are only changed once
+	visited := map[string]bool{}
+	for _, name := range m.getSortedPackages() {
+		pkg := m.packages[name].pkg
+		for _, file := range pkg.Syntax {
+			path := pkg.Decorator.Filenames[file]
+			if visited[path] || util.IsGenerated(pkg.Decorator, file) {
+				continue
+			}
+			visited[path] = true
+			newInstrumentationRemover(pkg, m.packages[name].generated).removeFromFile(file)
+		}
+	}
+	return nil
+}
+
+// instrumentationRemover removes the generated instrumentation from a file.
+type instrumentationRemover struct {
+	*generatedCode
+	pkg *decorator.Package
+
+	wrappedHandlers   map[string]string    // the variables of wrapped slog handlers, and the handlers they wrap
+	removed           map[dst.Stmt]bool    // the generated statements
+	nonEmptyIfs       map[*dst.IfStmt]bool // the if statements that had a body before generated statements were removed from it
+	unwrappedContexts map[dst.Expr]bool    // the contexts that a transaction was added to
+	httpClients       map[string]bool      // the package level http clients with a New Relic round tripper
+	rewrittenRequests map[dst.Expr]bool    // the requests created by RewriteHttpMethodCall, and their contexts
+}
+
+func newInstrumentationRemover(pkg *decorator.Package, generated *generatedCode) *instrumentationRemover {
+	return &instrumentationRemover{
+		generatedCode:     generated,
+		pkg:               pkg,
+		wrappedHandlers:   map[string]string{},
+		removed:           map[dst.Stmt]bool{},
+		nonEmptyIfs:       map[*dst.IfStmt]bool{},
+		unwrappedContexts: map[dst.Expr]bool{},
+		httpClients:       instrumentedHttpClients(pkg),
+		rewrittenRequests: map[dst.Expr]bool{},
+	}
+}
+
+// instrumentedHttpClients returns the names of the package level variables of a package that are http clients with a
+// New Relic round tripper, like the client declared for the calls rewritten by RewriteHttpMethodCall.
+func instrumentedHttpClients(pkg *decorator.Package) map[string]bool {
+	clients := map[string]bool{}
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*dst.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value, ok := spec.(*dst.ValueSpec)
+				if !ok || len(value.Names) != len(value.Values) {
+					continue
+				}
+				for i, name := range value.Names {
+					if isInstrumentedHttpClient(value.Values[i]) {
+						clients[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return clients
+}
+
+// removeFromFile removes the generated instrumentation from a file, and warns about the uses of generated variables
+// that could not be removed.
+func (r *instrumentationRemover) removeFromFile(file *dst.File) {
+	// init functions are added to instrument package level http clients, and are removed once they are empty
+	inits := map[*dst.FuncDecl]bool{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*dst.FuncDecl); ok && fn.Name.Name == "init" && fn.Recv == nil && fn.Body != nil && len(fn.Body.List) > 0 {
+			inits[fn] = true
+		}
+	}
+
+	dstutil.Apply(file, r.pre, r.post)
+
+	file.Decls = slices.DeleteFunc(file.Decls, func(decl dst.Decl) bool {
+		fn, ok := decl.(*dst.FuncDecl)
+		return ok && inits[fn] && len(fn.Body.List) == 0
+	})
+
+	dst.Inspect(file, func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok && ident.Path == "" {
+			if handler, ok := r.wrappedHandlers[ident.Name]; ok {
+				ident.Name = handler
+			}
+		}
+		return true
+	})
+
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*dst.FuncDecl); ok && fn.Body != nil {
+			r.warnRemainingReferences(fn)
+		}
+	}
+}
+
+// pre removes generated comments, and finds the generated statements before their children are changed. The types of
+// calls are checked here, since they are only known for the nodes of the original syntax tree.
+func (r *instrumentationRemover) pre(c *dstutil.Cursor) bool {
+	n := c.Node()
+	if n == nil {
+		return true
+	}
+	removeGeneratedComments(n.Decorations())
+
+	if stmt, ok := n.(dst.Stmt); ok && r.isStatement(stmt) {
+		r.removed[stmt] = true
+		if assign, ok := stmt.(*dst.AssignStmt); ok {
+			if wrapped, handler, ok := wrappedHandler(assign); ok {
+				r.wrappedHandlers[wrapped] = handler
+			}
+		}
+		return false
+	}
+
+	switch v := n.(type) {
+	case *dst.BlockStmt:
+		r.findRewrittenRequests(v.List)
+	case *dst.IfStmt:
+		r.nonEmptyIfs[v] = hasStatements(v)
+	case *dst.GoStmt:
+		if call := r.mainGoroutineCall(v); call != nil {
+			v.Call = call
+		}
+	case *dst.CallExpr:
+		sig := calleeSignature(v, r.pkg)
+		if sig == nil {
+			break
+		}
+		if hasTransactionParameter(sig) && len(v.Args) == sig.Params().Len() {
+			v.Args = v.Args[:len(v.Args)-1]
+		}
+		// options that add integrations, like the interceptors of grpc servers, are passed as variadic arguments
+		if sig.Variadic() {
+			fixed := sig.Params().Len() - 1
+			if len(v.Args) > fixed {
+				v.Args = slices.Concat(v.Args[:fixed], slices.DeleteFunc(slices.Clone(v.Args[fixed:]), r.isIntegrationArgument))
+			}
+		}
+	}
+	return true
+}
+
+// post removes generated statements and parameters, and unwraps the expressions that were wrapped with a transaction.
+func (r *instrumentationRemover) post(c *dstutil.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *dst.BlockStmt:
+		n.List = r.filterStatements(n.List)
+	case *dst.CaseClause:
+		n.Body = r.filterStatements(n.Body)
+	case *dst.CommClause:
+		n.Body = r.filterStatements(n.Body)
+	case *dst.FuncType:
+		if n.Params != nil && len(n.Params.List) > 0 && isTransactionParameter(n.Params.List[len(n.Params.List)-1]) {
+			n.Params.List = n.Params.List[:len(n.Params.List)-1]
+		}
+	case *dst.CallExpr:
+		if replacement := r.unwrapCall(n); replacement != nil {
+			if n.Decs.Before != dst.None {
+				replacement.Decorations().Before = n.Decs.Before
+				replacement.Decorations().After = n.Decs.After
+			}
+			c.Replace(replacement)
+		}
+	}
+	return true
+}
+
+// filterStatements removes the generated statements from a list of statements. Comments of the original code that
+// were moved onto a generated statement are moved back to the statements next to it, and the statements around the
+// removed ones are spaced like they were before they were instrumented.
+func (r *instrumentationRemover) filterStatements(stmts []dst.Stmt) []dst.Stmt {
+	kept := make([]dst.Stmt, 0, len(stmts))
+
+	// the spacing and comments of the statements removed since the last statement that was kept
+	removing, captured := false, false
+	var spacing dst.SpaceType
+	var start []string
+
+	for i, stmt := range stmts {
+		isCaptured := r.restoreCapturedReturn(stmt, stmts[i+1:])
+		if isCaptured || r.removed[stmt] || r.isEmptiedIf(stmt) {
+			decs := stmt.Decorations()
+			if !removing {
+				removing, spacing = true, decs.Before
+			}
+			if decs.Before == dst.EmptyLine || decs.After == dst.EmptyLine {
+				spacing = dst.EmptyLine
+			}
+			captured = captured || isCaptured
+			for _, c := range decs.Start {
+				if !strings.HasPrefix(c, codegen.CapturedReturnComment) {
+					start = append(start, c)
+				}
+			}
+			if len(decs.End) > 0 && len(kept) > 0 {
+				kept[len(kept)-1].Decorations().End.Append(decs.End.All()...)
+			}
+			continue
+		}
+
+		if removing {
+			decs := stmt.Decorations()
+			switch {
+			case len(kept) == 0 || captured:
+				// blocks do not start with an empty line, and returns are not spaced from the code that came before them
+				spacing = dst.NewLine
+			case decs.Before == dst.EmptyLine:
+				spacing = dst.EmptyLine
+			}
+			decs.Before = spacing
+			if len(kept) > 0 {
+				kept[len(kept)-1].Decorations().After = spacing
+			}
+			if len(start) > 0 {
+				decs.Start.Replace(append(start, decs.Start.All()...)...)
+			}
+			removing, captured, start = false, false, nil
+		}
+		kept = append(kept, stmt)
+	}
+
+	// blocks do not end with an empty line
+	if removing && len(kept) > 0 {
+		kept[len(kept)-1].Decorations().After = dst.NewLine
+	}
+	return kept
+}
+
+// restoreCapturedReturn restores a call that was moved out of a return statement so that its error could be noticed:
+//
+//	// generated by go-easy-instrumentation; returnValue0:error
+//	returnValue0 := errors.New("failed")
+//	if returnValue0 != nil {
+//		nrTxn.NoticeError(returnValue0)
+//	}
+//	return nil, returnValue0
+//
+// becomes
+//
+//	return nil, errors.New("failed")
+//
+// It returns true if the statement is the assignment of the captured values, which can be removed.
+func (r *instrumentationRemover) restoreCapturedReturn(stmt dst.Stmt, following []dst.Stmt) bool {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 || len(assign.Decs.Start) == 0 || !strings.HasPrefix(assign.Decs.Start[0], codegen.CapturedReturnComment) {
+		return false
+	}
+
+	values := []string{}
+	for i, lhs := range assign.Lhs {
+		if !isIdent(lhs, fmt.Sprintf("returnValue%d", i)) {
+			return false
+		}
+		values = append(values, lhs.(*dst.Ident).Name)
+	}
+
+	for _, next := range following {
+		ret, ok := next.(*dst.ReturnStmt)
+		if !ok {
+			continue
+		}
+		if len(values) == 1 {
+			for i, result := range ret.Results {
+				if isIdent(result, values[0]) {
+					ret.Results[i] = assign.Rhs[0]
+				}
+			}
+		} else {
+			ret.Results = []dst.Expr{assign.Rhs[0]}
+		}
+		return true
+	}
+	return false
+}
+
+// isEmptiedIf returns true if a statement is an if statement whose body only contained generated statements, like the
+// checks of the errors that are noticed.
+func (r *instrumentationRemover) isEmptiedIf(stmt dst.Stmt) bool {
+	ifStmt, ok := stmt.(*dst.IfStmt)
+	return ok && ifStmt.Init == nil && r.nonEmptyIfs[ifStmt] && !hasStatements(ifStmt)
+}
+
+// mainGoroutineCall returns the call of a goroutine that a transaction was started for in main, so that the go
+// statement can be restored. The arguments that were passed to the function literal are put back in the call:
+//
+//	go func(nrTxn *newrelic.Transaction, jobs chan int) {
+//		defer nrTxn.End()
+//		worker(jobs, nrTxn)
+//	}(NewRelicAgent.StartTransaction("worker"), jobs)
+//
+// becomes
+//
+//	go worker(jobs)
+func (r *instrumentationRemover) mainGoroutineCall(stmt *dst.GoStmt) *dst.CallExpr {
+	lit, ok := stmt.Call.Fun.(*dst.FuncLit)
+	if !ok || len(stmt.Call.Args) == 0 || !isStartTransaction(stmt.Call.Args[0]) {
+		return nil
+	}
+	params := lit.Type.Params
+	if params == nil || len(params.List) != len(stmt.Call.Args) || !isTransactionParameter(params.List[0]) {
+		return nil
+	}
+	arguments := map[string]dst.Expr{}
+	for i, param := range params.List[1:] {
+		if len(param.Names) != 1 {
+			return nil
+		}
+		arguments[param.Names[0].Name] = stmt.Call.Args[i+1]
+	}
+
+	call := goroutineCall(lit.Body.List)
+	if call == nil {
+		return nil
+	}
+	return dstutil.Apply(call, func(c *dstutil.Cursor) bool {
+		ident, ok := c.Node().(*dst.Ident)
+		if !ok || ident.Path != "" || c.Name() == "Sel" || c.Name() == "Key" {
+			return true
+		}
+		if arg, isParam := arguments[ident.Name]; isParam {
+			// receivers of pointer methods are passed by address, which the method call does implicitly
+			if unary, ok := arg.(*dst.UnaryExpr); ok && unary.Op == token.AND && c.Name() == "X" {
+				if _, ok := c.Parent().(*dst.SelectorExpr); ok {
+					arg = unary.X
+				}
+			}
+			c.Replace(arg)
+		}
+		return true
+	}, nil).(*dst.CallExpr)
+}
+
+// goroutineCall returns the call in the body of a function literal generated by traceMainGoroutine.
+func goroutineCall(body []dst.Stmt) *dst.CallExpr {
+	if len(body) < 2 {
+		return nil
+	}
+	if end, ok := body[0].(*dst.DeferStmt); !ok || !isTransactionMethodCall(end.Call, "End") {
+		return nil
+	}
+	switch len(body) {
+	case 2:
+		// worker(jobs, nrTxn)
+		if expr, ok := body[1].(*dst.ExprStmt); ok {
+			call, _ := expr.X.(*dst.CallExpr)
+			return call
+		}
+	case 3:
+		// err := worker(jobs, nrTxn)
+		// if err != nil {
+		// 	nrTxn.NoticeError(err)
+		// }
+		assign, ok := body[1].(*dst.AssignStmt)
+		_, checked := body[2].(*dst.IfStmt)
+		if ok && checked && len(assign.Lhs) == 1 && len(assign.Rhs) == 1 {
+			call, _ := assign.Rhs[0].(*dst.CallExpr)
+			return call
+		}
+	}
+	return nil
+}
+
+// findRewrittenRequests finds the requests created by RewriteHttpMethodCall in a list of statements. These are sent by
+// an instrumented http client if they were created successfully:
+//
+//	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), nrTxn), http.MethodGet, url, nil)
+//	if err == nil {
+//		resp, err = nrHttpClient.Do(req)
+//	}
+//
+// The original call can not always be restored, so the rewritten code is left in place along with its client, and the
+// use of the transaction in it is warned about by warnRemainingReferences.
+func (r *instrumentationRemover) findRewrittenRequests(stmts []dst.Stmt) {
+	for i := 0; i+1 < len(stmts); i++ {
+		assign, ok := stmts[i].(*dst.AssignStmt)
+		if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+			continue
+		}
+		request, ok := assign.Lhs[0].(*dst.Ident)
+		call, isCall := assign.Rhs[0].(*dst.CallExpr)
+		if !ok || !isCall || !isFunctionCall(call, codegen.HttpImportPath, httpNewRequestWithContext) || len(call.Args) != 4 {
+			continue
+		}
+		send, ok := stmts[i+1].(*dst.IfStmt)
+		if !ok || !r.sendsRequest(send.Body, request.Name) {
+			continue
+		}
+		r.rewrittenRequests[call] = true
+		r.rewrittenRequests[call.Args[0]] = true
+	}
+}
+
+// sendsRequest returns true if the last statement of a block sends a request with an instrumented http client.
+func (r *instrumentationRemover) sendsRequest(body *dst.BlockStmt, request string) bool {
+	if body == nil || len(body.List) == 0 {
+		return false
+	}
+	assign, ok := body.List[len(body.List)-1].(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return false
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 || !isIdent(call.Args[0], request) {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != httpDo {
+		return false
+	}
+	client, ok := sel.X.(*dst.Ident)
+	return ok && client.Path == "" && r.httpClients[client.Name]
+}
+
+// unwrapCall returns the expression that a call wrapped with a transaction, or nil if the call was not generated.
+// Handlers that were wrapped with newrelic.WrapHandleFunc are unwrapped in place.
+func (r *instrumentationRemover) unwrapCall(call *dst.CallExpr) dst.Expr {
+	if r.rewrittenRequests[call] {
+		return nil
+	}
+
+	// http.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "/", index))
+	if len(call.Args) == 1 && (isNewRelicCall(call.Args[0], "WrapHandleFunc") || isNewRelicCall(call.Args[0], "WrapHandle")) {
+		if wrap := call.Args[0].(*dst.CallExpr); len(wrap.Args) == 3 && (r.isAgent(wrap.Args[0]) || isTransactionMethodCall(wrap.Args[0], "Application")) {
+			call.Args = wrap.Args[1:]
+		}
+	}
+
+	switch {
+	case isNewRelicCall(call, "NewContext") && len(call.Args) == 2 && isTransaction(call.Args[1]):
+		// newrelic.NewContext(ctx, nrTxn)
+		r.unwrappedContexts[call.Args[0]] = true
+		return call.Args[0]
+	case isNewRelicCall(call, "RequestWithTransactionContext") && len(call.Args) == 2 && isTransaction(call.Args[1]):
+		// newrelic.RequestWithTransactionContext(req, nrTxn)
+		return call.Args[0]
+	case isFunctionCall(call, codegen.HttpImportPath, httpNewRequestWithContext) && len(call.Args) == 4 && r.unwrappedContexts[call.Args[0]] && isFunctionCall(call.Args[0], "context", "Background"):
+		// http.NewRequestWithContext(newrelic.NewContext(context.Background(), nrTxn), "GET", url, nil) is created
+		// from http.NewRequest, which uses the background context
+		call.Fun.(*dst.Ident).Name = httpNewRequest
+		call.Args = call.Args[1:]
+	}
+	return nil
+}
+
+// warnRemainingReferences comments on the statements of a function that still use a generated variable after its
+// declaration was removed, since the code that uses it could not be recognized.
+func (r *instrumentationRemover) warnRemainingReferences(decl *dst.FuncDecl) {
+	names := []string{codegen.DefaultTransactionVariable}
+	for agent := range r.agents {
+		names = append(names, agent)
+	}
+	slices.Sort(names[1:])
+
+	for _, name := range names {
+		if declares(decl, name) {
+			continue
+		}
+		for _, stmt := range decl.Body.List {
+			if references(stmt, name) {
+				comment.Warn(r.pkg, notRemovedRule, stmt, stmt, fmt.Sprintf("%s could not remove the instrumentation that uses %s; please remove it by hand", common.ApplicationName, name))
+			}
+		}
+	}
+}
+
+// calleeSignature returns the signature of the function that is called, or nil if the call is a conversion.
+func calleeSignature(call *dst.CallExpr, pkg *decorator.Package) *types.Signature {
+	if pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+	fun, ok := pkg.Decorator.Ast.Nodes[call.Fun].(ast.Expr)
+	if !ok {
+		return nil
+	}
+	tv, ok := pkg.TypesInfo.Types[fun]
+	if !ok || tv.IsType() || tv.Type == nil {
+		return nil
+	}
+	sig, _ := tv.Type.Underlying().(*types.Signature)
+	return sig
+}
+
+// hasTransactionParameter returns true if the last parameter of a function is a generated transaction parameter.
+func hasTransactionParameter(sig *types.Signature) bool {
+	params := sig.Params()
+	if params.Len() == 0 || sig.Variadic() {
+		return false
+	}
+	last := params.At(params.Len() - 1)
+	return last.Name() == codegen.DefaultTransactionVariable && last.Type().String() == "*"+codegen.NewRelicAgentImportPath+".Transaction"
+}
+
+// hasStatements returns true if any branch of an if statement has statements.
+func hasStatements(stmt *dst.IfStmt) bool {
+	if len(stmt.Body.List) > 0 {
+		return true
+	}
+	switch e := stmt.Else.(type) {
+	case *dst.BlockStmt:
+		return len(e.List) > 0
+	case *dst.IfStmt:
+		return hasStatements(e)
+	}
+	return false
+}
+
+// declares returns true if a variable is declared by a function, as a parameter or a local variable.
+func declares(node dst.Node, name string) bool {
+	declared := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.Field:
+			declared = declared || slices.ContainsFunc(v.Names, func(ident *dst.Ident) bool { return ident.Name == name })
+		case *dst.ValueSpec:
+			declared = declared || slices.ContainsFunc(v.Names, func(ident *dst.Ident) bool { return ident.Name == name })
+		case *dst.AssignStmt:
+			declared = declared || (v.Tok == token.DEFINE && slices.ContainsFunc(v.Lhs, func(lhs dst.Expr) bool { return isIdent(lhs, name) }))
+		case *dst.RangeStmt:
+			declared = declared || (v.Tok == token.DEFINE && (isIdent(v.Key, name) || isIdent(v.Value, name)))
+		}
+		return !declared
+	})
+	return declared
+}
+
+// references returns true if a variable is used in a node.
+func references(node dst.Node, name string) bool {
+	used := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		if sel, ok := n.(*dst.SelectorExpr); ok {
+			// the names of fields and methods are not variables
+			dst.Inspect(sel.X, func(n dst.Node) bool {
+				used = used || isIdentNode(n, name)
+				return !used
+			})
+			return false
+		}
+		used = used || isIdentNode(n, name)
+		return !used
+	})
+	return used
+}
+
+// isIdentNode returns true if a node is a local identifier with a name.
+func isIdentNode(n dst.Node, name string) bool {
+	expr, ok := n.(dst.Expr)
+	return ok && isIdent(expr, name)
+}
+
+// removeGeneratedComments removes the comments that were added to a node to inform users about its instrumentation.
+// These are written before the existing comments of the node, and separated from them by an empty comment line.
+func removeGeneratedComments(decs *dst.NodeDecs) {
+	for {
+		existing := decs.Start.All()
+		for _, method := range []string{httpGet, httpPost, httpHead, httpPostForm} {
+			removeCannotTraceOutboundHttpComment(method, decs)
+		}
+
+		start := decs.Start.All()
+		if len(start) > 0 && (strings.HasPrefix(start[0], "// "+comment.InfoHeader+":") || strings.HasPrefix(start[0], "// "+comment.WarnHeader+":")) {
+			end := slices.Index(start, "//")
+			if end < 0 {
+				start = nil
+			} else {
+				start = start[end+1:]
+			}
+			decs.Start.Replace(start...)
+		}
+
+		if len(decs.Start.All()) == len(existing) {
+			return
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveInstrumentation(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "removes the agent, transactions and segments",
+			code: `package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(n int, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("work").End()
+
+	if n < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	// do some work
+	nrTxn := NewRelicAgent.StartTransaction("work")
+	err := work(1, nrTxn)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		println(err.Error())
+	}
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+			expect: `package main
+
+import "errors"
+
+func work(n int) error {
+	if n < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
+
+func main() {
+	// do some work
+	err := work(1)
+	if err != nil {
+		println(err.Error())
+	}
+}
+`,
+		},
+		{
+			name: "unwraps handlers and restores captured returns",
+			code: `package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func check(r *http.Request, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("check").End()
+
+	if r.URL.Path == "" {
+		println("empty path")
+
+		// generated by go-easy-instrumentation; returnValue0:error
+		returnValue0 := errors.New("empty path")
+		if returnValue0 != nil {
+			nrTxn.NoticeError(returnValue0)
+		}
+
+		return returnValue0
+	}
+	return nil
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	if err := check(r, nrTxn); err != nil {
+		nrTxn.NoticeError(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("hello world"))
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	http.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "/", index))
+	http.ListenAndServe(":8000", nil)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+			expect: `package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+func check(r *http.Request) error {
+	if r.URL.Path == "" {
+		println("empty path")
+		return errors.New("empty path")
+	}
+	return nil
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	if err := check(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("hello world"))
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8000", nil)
+}
+`,
+		},
+		{
+			name: "restores goroutines launched from main with their arguments",
+			code: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func worker(name string, count int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("worker").End()
+
+	println(name, count)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	x := 1
+	go func(nrTxn *newrelic.Transaction, arg int) {
+		defer nrTxn.End()
+		worker("jobs", arg, nrTxn)
+	}(NewRelicAgent.StartTransaction("worker"), x+1)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+			expect: `package main
+
+func worker(name string, count int) {
+	println(name, count)
+}
+
+func main() {
+	x := 1
+	go worker("jobs", x+1)
+}
+`,
+		},
+		{
+			name: "restores goroutines launched from main with their receivers",
+			code: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type W struct {
+	n int
+}
+
+func (w *W) run(k int, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("run").End()
+
+	println(w.n, k)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	var v W
+	go func(nrTxn *newrelic.Transaction, v *W) {
+		defer nrTxn.End()
+		v.run(7, nrTxn)
+	}(NewRelicAgent.StartTransaction("run"), &v)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+			expect: `package main
+
+type W struct {
+	n int
+}
+
+func (w *W) run(k int) {
+	println(w.n, k)
+}
+
+func main() {
+	var v W
+	go v.run(7)
+}
+`,
+		},
+		{
+			name: "keeps instrumentation written by hand",
+			code: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func index(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	defer txn.StartSegment("index").End()
+	w.Write([]byte("hello world"))
+}
+
+func main() {
+	app, err := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if err != nil {
+		panic(err)
+	}
+
+	client := &http.Client{}
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+
+	http.HandleFunc(newrelic.WrapHandleFunc(app, "/", index))
+	http.ListenAndServe(":8000", nil)
+
+	app.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "removes generated comments and warns about uses of generated variables",
+			code: `package main
+
+import (
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("work").End()
+
+	// NR WARN: go-easy-instrumentation does not know how to instrument this
+	// https://docs.newrelic.com/
+	//
+	// count the work
+	nrTxn.AddAttribute("work", 1)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("work")
+	work(nrTxn)
+	nrTxn.End()
+}
+`,
+			expect: `package main
+
+func work() {
+	// NR WARN: go-easy-instrumentation could not remove the instrumentation that uses nrTxn; please remove it by hand
+	//
+	// count the work
+	nrTxn.AddAttribute("work", 1)
+}
+
+func main() {
+	work()
+}
+`,
+		},
+		{
+			name: "leaves rewritten http requests and warns about them",
+			code: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func fetch(url string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("fetch").End()
+
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), nrTxn), http.MethodGet, url, nil)
+	if err == nil {
+		resp, err = nrHttpClient.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var nrHttpClient = &http.Client{Transport: newrelic.NewRoundTripper(nil)}
+
+func fetch(url string) error {
+	var resp *http.Response
+	// NR WARN: go-easy-instrumentation could not remove the instrumentation that uses nrTxn; please remove it by hand
+	req, err := http.NewRequestWithContext(newrelic.NewContext(context.Background(), nrTxn), http.MethodGet, url, nil)
+	if err == nil {
+		resp, err = nrHttpClient.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			if err := manager.RemoveInstrumentation(); err != nil {
+				t.Fatal(err)
+			}
+			if err := manager.WriteFiles(nil, func(string) error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := os.ReadFile(filepath.Join(testDir, "app.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := tt.expect
+			if expect == "" {
+				expect = tt.code
+			}
+			assert.Equal(t, expect, string(contents))
+		})
+	}
+}
@@ -83,7 +83,7 @@ func testInstrumentationManager(t *testing.T, code, testAppDir string) *Instrume
 	varName := "NewRelicAgent"
 	diffFile := filepath.Join(testAppDir, "new-relic-instrumentation.diff")
 
-	manager := NewInstrumentationManager(pkgs, appName, varName, diffFile, testAppDir)
+	manager := NewInstrumentationManager(pkgs, Config{AppName: appName, AgentVariableName: varName}, diffFile, testAppDir)
 	configureTestInstrumentationManager(manager)
 	return manager
 }
@@ -160,6 +160,10 @@ func testStatefulTracingFunction(t *testing.T, code string, stmtFunc StatefulTra
 }
 
 func testStatelessTracingFunction(t *testing.T, code string, tracingFunc StatelessTracingFunction, statefulTracingFuncs ...StatefulTracingFunction) string {
+	return testStatelessTracingFunctionWithOptions(t, code, Options{}, tracingFunc, statefulTracingFuncs...)
+}
+
+func testStatelessTracingFunctionWithOptions(t *testing.T, code string, options Options, tracingFunc StatelessTracingFunction, statefulTracingFuncs ...StatefulTracingFunction) string {
 	id, err := pseudo_uuid()
 	if err != nil {
 		t.Fatal(err)
@@ -174,6 +178,7 @@ func testStatelessTracingFunction(t *testing.T, code string, tracingFunc Statele
 		t.Fatalf("Package was nil: %+v", manager.packages)
 	}
 
+	manager.SetOptions(options)
 	manager.tracingFunctions.stateful = append(manager.tracingFunctions.stateful, statefulTracingFuncs...)
 	manager.tracingFunctions.stateless = append(manager.tracingFunctions.stateless, tracingFunc)
 	err = manager.TracePackageCalls()
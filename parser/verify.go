@@ -0,0 +1,320 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	godiffpatch "github.com/sourcegraph/go-diff-patch"
+	"golang.org/x/tools/go/packages"
+)
+
+// VerificationProblem is a type error or go vet diagnostic in the instrumented application that Verify found.
+type VerificationProblem struct {
+	File    string // the file the problem is in, relative to the application
+	Line    int
+	Column  int
+	Message string
+	Hunk    string // the header of the hunk of the diff that the problem is in, or empty if it is outside of the changes
+}
+
+func (p VerificationProblem) String() string {
+	if p.File == "" {
+		// errors of a package that are not at a position, like imports that can not be resolved
+		return p.Message
+	}
+	position := fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	if p.Hunk == "" {
+		return position + " (outside of the generated changes)"
+	}
+	return fmt.Sprintf("%s (in the generated change %s)", position, p.Hunk)
+}
+
+// instrumentedFile is a file of the application that was changed by the instrumentation.
+type instrumentedFile struct {
+	name     string // the path of the file relative to the application
+	modified []byte
+	hunks    []hunk
+}
+
+// hunk is the range of lines of an instrumented file that a hunk of the diff changes.
+type hunk struct {
+	header      string
+	first, last int
+}
+
+// hunkHeader matches the header of a hunk of a unified diff, and captures the range of lines in the new file.
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// parseHunks returns the hunks of the unified diff of a file.
+func parseHunks(patch string) []hunk {
+	var hunks []hunk
+	for _, line := range strings.Split(patch, "\n") {
+		match := hunkHeader.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(match[1])
+		count := 1
+		if match[2] != "" {
+			count, _ = strconv.Atoi(match[2])
+		}
+		hunks = append(hunks, hunk{header: match[0], first: first, last: first + count - 1})
+	}
+	return hunks
+}
+
+// hunkAt returns the header of the hunk that changes a line of an instrumented file, or an empty string if no hunk does.
+func (f *instrumentedFile) hunkAt(line int) string {
+	for _, h := range f.hunks {
+		if h.first <= line && line <= h.last {
+			return h.header
+		}
+	}
+	return ""
+}
+
+// Verify checks that the instrumented application builds without writing the changes to its files. The instrumented
+// files are loaded from an overlay to type check the packages again, and go vet is run on them when they type check.
+// The modules required by the instrumentation must have been added first.
+//
+// An error that lists the problems, and the hunks of the diff that they are in, is returned when the instrumentation
+// breaks the build. Problems that the application already had are ignored.
+func (m *InstrumentationManager) Verify() error {
+	files, err := m.instrumentedFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	dir, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return err
+	}
+	patterns := m.packagePatterns()
+
+	problems, err := typeCheck(dir, patterns, files)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		if problems, err = vet(dir, patterns, files); err != nil {
+			return err
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(problems, func(a, b VerificationProblem) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		lines[i] = "\t" + problem.String()
+	}
+	return fmt.Errorf("the instrumentation breaks the build of the application:\n%s", strings.Join(lines, "\n"))
+}
+
+// instrumentedFiles returns the files of the application that are changed by the instrumentation, by their path.
+func (m *InstrumentationManager) instrumentedFiles() (map[string]*instrumentedFile, error) {
+	files := map[string]*instrumentedFile{}
+	err := m.restoreFiles(func(path, name string, original, modified []byte) error {
+		// the files of a package are also part of its test variant, and are only restored the first time
+		if files[path] != nil || bytes.Equal(original, modified) {
+			return nil
+		}
+		files[path] = &instrumentedFile{
+			name:     name,
+			modified: modified,
+			hunks:    parseHunks(godiffpatch.GeneratePatch(name, string(original), string(modified))),
+		}
+		return nil
+	})
+	return files, err
+}
+
+// packagePatterns returns the import paths of the packages that are instrumented. Their test packages are loaded with them.
+func (m *InstrumentationManager) packagePatterns() []string {
+	var patterns []string
+	for _, state := range m.packages {
+		path := state.pkg.PkgPath
+		if strings.HasSuffix(path, "_test") || strings.HasSuffix(path, ".test") || slices.Contains(patterns, path) {
+			continue
+		}
+		patterns = append(patterns, path)
+	}
+	slices.Sort(patterns)
+	return patterns
+}
+
+// problemsFrom returns the problems at the positions of errors, like main.go:12:2, in the instrumented files. Positions
+// are resolved relative to dir. Problems that are also reported for the original files are not returned.
+func problemsFrom(dir string, files map[string]*instrumentedFile, reported, original []positionedMessage) []VerificationProblem {
+	known := map[string]bool{}
+	for _, msg := range original {
+		known[msg.file+"\x00"+msg.message] = true
+	}
+
+	var problems []VerificationProblem
+	for _, msg := range reported {
+		if known[msg.file+"\x00"+msg.message] {
+			continue
+		}
+		problem := VerificationProblem{File: msg.file, Line: msg.line, Column: msg.column, Message: msg.message}
+		if file, ok := files[msg.file]; ok {
+			problem.File = file.name
+			problem.Hunk = file.hunkAt(msg.line)
+		} else if name, err := filepath.Rel(dir, msg.file); err == nil {
+			problem.File = name
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// positionedMessage is an error message reported for a position in a file.
+type positionedMessage struct {
+	file         string
+	line, column int
+	message      string
+}
+
+// errorPosition matches the position of an error, like main.go:12:2, and the message that follows it.
+var errorPosition = regexp.MustCompile(`^(?:vet: )?(.+\.go):(\d+):(\d+):? (.*)$`)
+
+// parsePositionedMessage parses an error that starts with its position. Relative file names are resolved against dir.
+func parsePositionedMessage(dir, text string) (positionedMessage, bool) {
+	match := errorPosition.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return positionedMessage{}, false
+	}
+	file := match[1]
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return positionedMessage{file: file, line: line, column: column, message: strings.TrimSpace(match[4])}, true
+}
+
+// packageErrorMessage returns the message of an error reported when loading a package. Errors that are not at a
+// position in a file of the application, like imports that can not be resolved, are returned without a file.
+func packageErrorMessage(dir string, err packages.Error) positionedMessage {
+	if msg, ok := parsePositionedMessage(dir, err.Pos+": "+err.Msg); ok {
+		return msg
+	}
+	if err.Pos == "" || err.Pos == "-" {
+		return positionedMessage{message: strings.TrimSpace(err.Msg)}
+	}
+	return positionedMessage{message: strings.TrimSpace(err.Pos + ": " + err.Msg)}
+}
+
+// typeCheck loads the packages of the application with the instrumented files, and returns their type errors.
+func typeCheck(dir string, patterns []string, files map[string]*instrumentedFile) ([]VerificationProblem, error) {
+	overlay := map[string][]byte{}
+	for path, file := range files {
+		overlay[path] = file.modified
+	}
+
+	load := func(overlay map[string][]byte) ([]positionedMessage, error) {
+		pkgs, err := packages.Load(&packages.Config{Dir: dir, Mode: packages.LoadSyntax, Tests: true, Overlay: overlay}, patterns...)
+		if err != nil {
+			return nil, fmt.Errorf("loading the instrumented packages: %w", err)
+		}
+		var messages []positionedMessage
+		for _, pkg := range pkgs {
+			for _, pkgErr := range pkg.Errors {
+				if msg := packageErrorMessage(dir, pkgErr); !slices.Contains(messages, msg) {
+					messages = append(messages, msg)
+				}
+			}
+		}
+		return messages, nil
+	}
+
+	reported, err := load(overlay)
+	if err != nil || len(reported) == 0 {
+		return nil, err
+	}
+	original, err := load(nil)
+	if err != nil {
+		return nil, err
+	}
+	return problemsFrom(dir, files, reported, original), nil
+}
+
+// vet runs go vet on the packages of the application with the instrumented files, and returns the problems it reports.
+// The files are written to a temporary directory that replaces them with a build overlay.
+func vet(dir string, patterns []string, files map[string]*instrumentedFile) ([]VerificationProblem, error) {
+	tmp, err := os.MkdirTemp("", "go-easy-instrumentation-verify")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	replace := map[string]string{}
+	replaced := map[string]string{} // the files that are replaced by each file in the overlay
+	i := 0
+	for path, file := range files {
+		replacement := filepath.Join(tmp, fmt.Sprintf("%d_%s", i, filepath.Base(path)))
+		if err := os.WriteFile(replacement, file.modified, 0644); err != nil {
+			return nil, err
+		}
+		replace[path] = replacement
+		replaced[replacement] = path
+		i++
+	}
+	overlay, err := json.Marshal(map[string]any{"Replace": replace})
+	if err != nil {
+		return nil, err
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := os.WriteFile(overlayFile, overlay, 0644); err != nil {
+		return nil, err
+	}
+
+	reported, err := goVet(dir, patterns, "-overlay="+overlayFile)
+	if err != nil || len(reported) == 0 {
+		return nil, err
+	}
+	// go vet reports the positions of problems in the files of the overlay
+	for i, msg := range reported {
+		if path, ok := replaced[msg.file]; ok {
+			reported[i].file = path
+		}
+	}
+	original, err := goVet(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	return problemsFrom(dir, files, reported, original), nil
+}
+
+// goVet runs go vet, and returns the diagnostics it reports.
+func goVet(dir string, patterns []string, flags ...string) ([]positionedMessage, error) {
+	cmd := exec.Command("go", append(append([]string{"vet"}, flags...), patterns...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+
+	var messages []positionedMessage
+	for _, line := range strings.Split(string(output), "\n") {
+		if msg, ok := parsePositionedMessage(dir, line); ok {
+			messages = append(messages, msg)
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || len(messages) == 0) {
+		return nil, fmt.Errorf("running go vet: %w\n%s", err, output)
+	}
+	return messages, nil
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func Test_parseHunks(t *testing.T) {
+	patch := `--- a/main.go
++++ b/main.go
+@@ -1,3 +1,5 @@
+ package main
++
++import "fmt"
+@@ -10 +12,2 @@ func main() {
++	fmt.Println()
+@@ -20,2 +23 @@
+`
+	file := &instrumentedFile{hunks: parseHunks(patch)}
+	tests := []struct {
+		line int
+		want string
+	}{
+		{line: 1, want: "@@ -1,3 +1,5 @@"},
+		{line: 5, want: "@@ -1,3 +1,5 @@"},
+		{line: 6, want: ""},
+		{line: 13, want: "@@ -10 +12,2 @@"},
+		{line: 23, want: "@@ -20,2 +23 @@"},
+		{line: 24, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("line %d", tt.line), func(t *testing.T) {
+			assert.Equal(t, tt.want, file.hunkAt(tt.line))
+		})
+	}
+}
+
+func Test_parsePositionedMessage(t *testing.T) {
+	tests := []struct {
+		text string
+		want positionedMessage
+		ok   bool
+	}{
+		{text: "/app/main.go:12:2: undefined: work", want: positionedMessage{file: "/app/main.go", line: 12, column: 2, message: "undefined: work"}, ok: true},
+		{text: "vet: ./main.go:3:5: self-assignment of n to n", want: positionedMessage{file: "/app/main.go", line: 3, column: 5, message: "self-assignment of n to n"}, ok: true},
+		{text: "# example.com/app", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := parsePositionedMessage("/app", tt.text)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_packageErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  packages.Error
+		want positionedMessage
+	}{
+		{name: "positioned error", err: packages.Error{Pos: "/app/main.go:12:2", Msg: "undefined: work"}, want: positionedMessage{file: "/app/main.go", line: 12, column: 2, message: "undefined: work"}},
+		{name: "error without a position", err: packages.Error{Msg: "could not import example.com/missing"}, want: positionedMessage{message: "could not import example.com/missing"}},
+		{name: "error outside of go files", err: packages.Error{Pos: "/app/go.mod:3", Msg: "unknown directive"}, want: positionedMessage{message: "/app/go.mod:3: unknown directive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, packageErrorMessage("/app", tt.err))
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// appendToMain adds a statement to the end of main, like instrumentation that breaks the build would
+	appendToMain := func(stmt dst.Stmt) func(*dst.File) {
+		return func(file *dst.File) {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*dst.FuncDecl); ok && fn.Name.Name == "main" {
+					fn.Body.List = append(fn.Body.List, stmt)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		code   string
+		change func(*dst.File)
+		expect []string
+	}{
+		{
+			name: "instrumentation that builds",
+			code: `package main
+
+func work(n int) int {
+	return n * 2
+}
+
+func main() {
+	println(work(1))
+}
+`,
+		},
+		{
+			name: "instrumentation that does not type check",
+			code: `package main
+
+func main() {
+	println("hello")
+}
+`,
+			change: appendToMain(&dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("undefinedFunction")}}),
+			expect: []string{"app.go:", "undefined: undefinedFunction", "(in the generated change @@"},
+		},
+		{
+			name: "instrumentation that go vet reports",
+			code: `package main
+
+func main() {
+	n := 1
+	println(n)
+}
+`,
+			change: appendToMain(&dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("n")}, Tok: token.ASSIGN, Rhs: []dst.Expr{dst.NewIdent("n")}}),
+			expect: []string{"self-assignment of n to n", "(in the generated change @@"},
+		},
+		{
+			name: "problems the application already had are ignored",
+			code: `package main
+
+func main() {
+	n := 1
+	n = n
+	println(n)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer panicRecovery(t)
+			id, err := pseudo_uuid()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testDir := fmt.Sprintf("tmp_%s", id)
+			defer cleanTestApp(t, testDir)
+
+			manager := testInstrumentationManager(t, tt.code, testDir)
+			steps := []func() error{
+				manager.DetectDependencyIntegrations,
+				manager.TracePackageCalls,
+				manager.ScanApplication,
+				manager.InstrumentApplication,
+			}
+			for _, step := range steps {
+				if err := step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.change != nil {
+				tt.change(manager.getDecoratorPackage().Syntax[0])
+			}
+
+			err = manager.Verify()
+			if len(tt.expect) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			if err == nil {
+				t.Fatal("expected the verification to fail")
+			}
+			for _, expect := range tt.expect {
+				if !strings.Contains(err.Error(), expect) {
+					t.Errorf("expected the error to contain %q, got: %v", expect, err)
+				}
+			}
+		})
+	}
+}
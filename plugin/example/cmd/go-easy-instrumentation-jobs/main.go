@@ -0,0 +1,20 @@
+// go-easy-instrumentation-jobs is a build of go-easy-instrumentation that also instruments the job runner in the
+// jobs package, with the integration registered by the jobsplugin package.
+//
+//	go build ./plugin/example/cmd/go-easy-instrumentation-jobs
+//	go-easy-instrumentation-jobs instrument /path/to/your/app
+package main
+
+import (
+	"log"
+
+	"github.com/newrelic/go-easy-instrumentation/cmd"
+	"github.com/newrelic/go-easy-instrumentation/plugin"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobsplugin"
+)
+
+func main() {
+	log.Default().SetFlags(0)
+	plugin.Register(jobsplugin.Integration)
+	cmd.Execute()
+}
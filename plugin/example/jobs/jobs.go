@@ -0,0 +1,53 @@
+// Package jobs is a small job runner that stands in for a framework that is only used inside of an organization.
+// It is instrumented by the example plugin in the jobsplugin package.
+package jobs
+
+import (
+	"context"
+	"fmt"
+)
+
+// Job is a unit of work that is run by a handler.
+type Job struct {
+	Name    string
+	Payload []byte
+}
+
+// Handler runs a job.
+type Handler func(ctx context.Context, job *Job) error
+
+// Middleware wraps the handlers of a runner.
+type Middleware func(next Handler) Handler
+
+// Runner runs jobs with the handler registered for their name.
+type Runner struct {
+	handlers   map[string]Handler
+	middleware []Middleware
+}
+
+// NewRunner creates a runner with no handlers.
+func NewRunner() *Runner {
+	return &Runner{handlers: map[string]Handler{}}
+}
+
+// Use adds middleware that wraps every handler of the runner.
+func (r *Runner) Use(middleware Middleware) {
+	r.middleware = append(r.middleware, middleware)
+}
+
+// Handle registers the handler for jobs with the given name.
+func (r *Runner) Handle(name string, handler Handler) {
+	r.handlers[name] = handler
+}
+
+// Run runs a job with the handler registered for its name.
+func (r *Runner) Run(ctx context.Context, job *Job) error {
+	handler, ok := r.handlers[job.Name]
+	if !ok {
+		return fmt.Errorf("no handler registered for job %q", job.Name)
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	return handler(ctx, job)
+}
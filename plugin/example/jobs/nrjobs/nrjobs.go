@@ -0,0 +1,26 @@
+// Package nrjobs instruments the jobs package with the New Relic Go agent.
+package nrjobs
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+)
+
+// Middleware starts a background transaction, named after the job, for every job run by a runner. The transaction
+// is passed to the handler in its context, and errors returned by the handler are noticed on it.
+func Middleware(app *newrelic.Application) jobs.Middleware {
+	return func(next jobs.Handler) jobs.Handler {
+		return func(ctx context.Context, job *jobs.Job) error {
+			txn := app.StartTransaction(job.Name)
+			defer txn.End()
+
+			err := next(newrelic.NewContext(ctx, txn), job)
+			if err != nil {
+				txn.NoticeError(err)
+			}
+			return err
+		}
+	}
+}
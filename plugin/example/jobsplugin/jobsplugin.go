@@ -0,0 +1,152 @@
+// Package jobsplugin is an example plugin that instruments the job runner in the jobs package.
+//
+// It adds New Relic middleware to job runners, which starts a transaction for every job that is run, and traces
+// the handlers of jobs with the transaction that the middleware passes to them in their context:
+//
+//	runner := jobs.NewRunner()
+//	runner.Use(nrjobs.Middleware(NewRelicAgent))
+//	runner.Handle("resize", resizeImage)
+//
+//	func resizeImage(ctx context.Context, job *jobs.Job) error {
+//		nrTxn := newrelic.FromContext(ctx)
+//		...
+//	}
+package jobsplugin
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/newrelic/go-easy-instrumentation/plugin"
+)
+
+const (
+	jobsImportPath     = "github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+	nrjobsImportPath   = jobsImportPath + "/nrjobs"
+	newrelicImportPath = "github.com/newrelic/go-agent/v3/newrelic"
+
+	// transactionVariable is the name of the variable that the transaction of a job is assigned to in its handler.
+	transactionVariable = "nrTxn"
+)
+
+// Integration instruments the job runner in the jobs package.
+var Integration = plugin.Integration{
+	Name:        "jobs",
+	Library:     jobsImportPath,
+	Description: "adds middleware to job runners, and traces the handlers of their jobs",
+	Stateless:   []plugin.StatelessTracingFunction{TraceJobHandler},
+	Stateful:    []plugin.StatefulTracingFunction{InstrumentRunner},
+}
+
+// InstrumentRunner adds New Relic middleware to a job runner that is created in a function that a transaction is
+// passed to, such as main.
+func InstrumentRunner(manager plugin.Manager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	runner, ok := newRunnerVariable(stmt)
+	if !ok || c.Index() < 0 {
+		return false
+	}
+
+	c.InsertAfter(&dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent(runner),
+				Sel: dst.NewIdent("Use"),
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun:  &dst.Ident{Name: "Middleware", Path: nrjobsImportPath},
+					Args: []dst.Expr{tracing.AgentVariable()},
+				},
+			},
+		},
+	})
+	manager.AddImport(nrjobsImportPath)
+	return true
+}
+
+// newRunnerVariable returns the name of the variable that a job runner is assigned to in a statement like:
+//
+//	runner := jobs.NewRunner()
+func newRunnerVariable(stmt dst.Stmt) (string, bool) {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return "", false
+	}
+
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return "", false
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	if !ok || fun.Name != "NewRunner" || fun.Path != jobsImportPath {
+		return "", false
+	}
+
+	runner, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok || runner.Name == "_" {
+		return "", false
+	}
+	return runner.Name, true
+}
+
+// TraceJobHandler traces the functions declared with the signature of a job handler, and gets the transaction that
+// the middleware passes to them from their context.
+func TraceJobHandler(manager plugin.Manager, c *dstutil.Cursor) {
+	decl, ok := c.Node().(*dst.FuncDecl)
+	if !ok || decl.Body == nil || !isJobHandler(manager, decl) {
+		return
+	}
+
+	ctx := decl.Type.Params.List[0]
+	if len(ctx.Names) != 1 || ctx.Names[0].Name == "_" {
+		manager.Comment(decl, fmt.Sprintf("the context of the job handler %s must be named for it to be traced", decl.Name.Name))
+		return
+	}
+
+	_, ok = manager.TraceFunction(decl, tracestate.FunctionBody(transactionVariable))
+	if !ok {
+		return
+	}
+
+	txnFromContext := &dst.AssignStmt{
+		Lhs: []dst.Expr{dst.NewIdent(transactionVariable)},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun:  &dst.Ident{Name: "FromContext", Path: newrelicImportPath},
+				Args: []dst.Expr{dst.NewIdent(ctx.Names[0].Name)},
+			},
+		},
+	}
+	txnFromContext.Decs.After = dst.EmptyLine
+	decl.Body.List = append([]dst.Stmt{txnFromContext}, decl.Body.List...)
+	manager.AddImport(newrelicImportPath)
+}
+
+// isJobHandler returns true if a function has the signature of a job handler:
+//
+//	func(ctx context.Context, job *jobs.Job) error
+func isJobHandler(manager plugin.Manager, decl *dst.FuncDecl) bool {
+	pkg := manager.Package()
+	astDecl, ok := pkg.Decorator.Ast.Nodes[decl].(*ast.FuncDecl)
+	if !ok {
+		return false
+	}
+	obj := pkg.TypesInfo.Defs[astDecl.Name]
+	if obj == nil {
+		return false
+	}
+	sig, ok := obj.Type().(*types.Signature)
+	if !ok || sig.Params().Len() != 2 || sig.Results().Len() != 1 {
+		return false
+	}
+
+	return sig.Params().At(0).Type().String() == "context.Context" &&
+		sig.Params().At(1).Type().String() == "*"+jobsImportPath+".Job" &&
+		sig.Results().At(0).Type().String() == "error"
+}
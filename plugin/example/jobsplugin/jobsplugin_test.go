@@ -0,0 +1,246 @@
+package jobsplugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/decorator/resolver/guess"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/plugin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestMain(m *testing.M) {
+	plugin.Register(Integration)
+	os.Exit(m.Run())
+}
+
+// instrument instruments an application with a single file, and returns the instrumented file.
+func instrument(t *testing.T, code string, options parser.Options) string {
+	if testing.Short() {
+		t.Skip("Skipping plugin integration tests in short mode")
+	}
+
+	// the application is created in this module so that it can import the jobs package
+	dir, err := os.MkdirTemp(".", "tmp_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.WriteFile(filepath.Join(dir, "app.go"), []byte(code), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := decorator.Load(&packages.Config{Dir: dir, Mode: packages.LoadSyntax})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := parser.NewInstrumentationManager(pkgs, parser.Config{AgentVariableName: "NewRelicAgent", Options: options}, filepath.Join(dir, "new-relic-instrumentation.diff"), dir)
+	steps := []func() error{
+		manager.DetectDependencyIntegrations,
+		manager.TracePackageCalls,
+		manager.ScanApplication,
+		manager.InstrumentApplication,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = decorator.NewRestorerWithImports(dir, guess.New()).Fprint(buf, pkgs[0].Syntax[0])
+	if err != nil {
+		t.Fatalf("Failed to restore the file: %v", err)
+	}
+	return buf.String()
+}
+
+func TestIntegration(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		options parser.Options
+		expect  string
+	}{
+		{
+			name: "adds middleware to runners and traces job handlers",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+)
+
+func resize(ctx context.Context, payload []byte) error {
+	return nil
+}
+
+func resizeImage(ctx context.Context, job *jobs.Job) error {
+	return resize(ctx, job.Payload)
+}
+
+func main() {
+	runner := jobs.NewRunner()
+	runner.Handle("resize", resizeImage)
+	runner.Run(context.Background(), &jobs.Job{Name: "resize"})
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs/nrjobs"
+)
+
+func resize(ctx context.Context, payload []byte) error {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("resize").End()
+
+	return nil
+}
+
+func resizeImage(ctx context.Context, job *jobs.Job) error {
+	nrTxn := newrelic.FromContext(ctx)
+
+	return resize(newrelic.NewContext(ctx, nrTxn), job.Payload)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	runner := jobs.NewRunner()
+	runner.Use(nrjobs.Middleware(NewRelicAgent))
+	runner.Handle("resize", resizeImage)
+	runner.Run(context.Background(), &jobs.Job{Name: "resize"})
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "comments on job handlers with an unnamed context",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+)
+
+func resizeImage(_ context.Context, job *jobs.Job) error {
+	return nil
+}
+
+func main() {
+	runner := jobs.NewRunner()
+	runner.Handle("resize", resizeImage)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs/nrjobs"
+)
+
+// NR WARN: the context of the job handler resizeImage must be named for it to be traced
+func resizeImage(_ context.Context, job *jobs.Job) error {
+	return nil
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	runner := jobs.NewRunner()
+	runner.Use(nrjobs.Middleware(NewRelicAgent))
+	runner.Handle("resize", resizeImage)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name:    "disabled",
+			options: parser.Options{DisabledIntegrations: []string{"jobs"}},
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+)
+
+func resize(ctx context.Context, payload []byte) error {
+	return nil
+}
+
+func resizeImage(ctx context.Context, job *jobs.Job) error {
+	return resize(ctx, job.Payload)
+}
+
+func main() {
+	runner := jobs.NewRunner()
+	runner.Handle("resize", resizeImage)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/newrelic/go-easy-instrumentation/plugin/example/jobs"
+)
+
+func resize(ctx context.Context, payload []byte) error {
+	return nil
+}
+
+func resizeImage(ctx context.Context, job *jobs.Job) error {
+	return resize(ctx, job.Payload)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	runner := jobs.NewRunner()
+	runner.Handle("resize", resizeImage)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := instrument(t, tt.code, tt.options)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
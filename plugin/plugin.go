@@ -0,0 +1,95 @@
+// Package plugin is the public API for adding integrations to go-easy-instrumentation. Integrations instrument
+// libraries that the tool does not know about, such as frameworks that are only used inside of an organization.
+//
+// An integration is a named set of tracing functions. Stateless tracing functions are called on every node of every
+// function declared in the application, and are used to find the entry points of tracing, such as the handlers of a
+// framework. Stateful tracing functions are called on every statement of the functions that a transaction is passed
+// to, and are used to instrument the calls made in them.
+//
+// Integrations are registered by a custom build of the tool, which registers them before running its commands:
+//
+//	func main() {
+//		plugin.Register(jobsplugin.Integration)
+//		cmd.Execute()
+//	}
+//
+// Registered integrations are listed by list-integrations, and can be selected with --only and --disable like the
+// integrations that are part of the tool.
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+// Manager is the part of the instrumentation manager that tracing functions use to modify the application.
+type Manager interface {
+	// AddImport records that the package being instrumented imports the given path, so that the module that
+	// provides it is added to the application when the instrumentation is applied.
+	AddImport(path string)
+
+	// Package returns the package that is being instrumented.
+	Package() *decorator.Package
+
+	// TraceFunction passes the transaction of the tracing state to the functions called by a function declaration
+	// or function literal, and applies the tracing functions of every integration to its body. It returns the
+	// function, and true if it was modified.
+	TraceFunction(node dst.Node, tracing *tracestate.State) (dst.Node, bool)
+
+	// Comment adds a comment above a node that asks the user to review it, such as when a pattern can not be
+	// instrumented automatically.
+	Comment(node dst.Node, message string, additionalInfo ...string)
+}
+
+// StatefulTracingFunction is called on every statement of the functions that a transaction is passed to. The
+// tracing state holds the transaction that is in scope. It returns true if the statement was modified.
+type StatefulTracingFunction func(manager Manager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool
+
+// StatelessTracingFunction is called on every node of every function declared in the application, and on every
+// package level declaration.
+type StatelessTracingFunction func(manager Manager, c *dstutil.Cursor)
+
+// Integration is a named set of tracing functions that instrument a library.
+type Integration struct {
+	Name        string // the name used to select the integration with --only and --disable
+	Library     string // the import path of the library that is instrumented
+	Description string // what the integration instruments
+	Stateless   []StatelessTracingFunction
+	Stateful    []StatefulTracingFunction
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Integration
+)
+
+// Register adds an integration to the tool. It panics if the integration has no name or tracing functions, or if
+// an integration with the same name has already been registered.
+func Register(integration Integration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if integration.Name == "" {
+		panic("plugin: Register called with an integration that has no name")
+	}
+	if len(integration.Stateless) == 0 && len(integration.Stateful) == 0 {
+		panic(fmt.Sprintf("plugin: integration %q has no tracing functions", integration.Name))
+	}
+	if slices.ContainsFunc(registry, func(i Integration) bool { return i.Name == integration.Name }) {
+		panic(fmt.Sprintf("plugin: Register called twice for integration %q", integration.Name))
+	}
+	registry = append(registry, integration)
+}
+
+// Integrations returns the registered integrations, in the order they were registered.
+func Integrations() []Integration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return slices.Clone(registry)
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/dave/dst/dstutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(manager Manager, c *dstutil.Cursor) {}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  []Integration
+		wantNames []string
+		wantPanic bool
+	}{
+		{
+			name: "integrations are returned in the order they were registered",
+			register: []Integration{
+				{Name: "jobs", Stateless: []StatelessTracingFunction{noop}},
+				{Name: "rpc", Stateless: []StatelessTracingFunction{noop}},
+			},
+			wantNames: []string{"jobs", "rpc"},
+		},
+		{
+			name: "integrations must have a name",
+			register: []Integration{
+				{Stateless: []StatelessTracingFunction{noop}},
+			},
+			wantPanic: true,
+		},
+		{
+			name: "integrations must have tracing functions",
+			register: []Integration{
+				{Name: "jobs"},
+			},
+			wantPanic: true,
+		},
+		{
+			name: "integrations can not be registered twice",
+			register: []Integration{
+				{Name: "jobs", Stateless: []StatelessTracingFunction{noop}},
+				{Name: "jobs", Stateless: []StatelessTracingFunction{noop}},
+			},
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry = nil
+			defer func() { registry = nil }()
+
+			register := func() {
+				for _, i := range tt.register {
+					Register(i)
+				}
+			}
+			if tt.wantPanic {
+				assert.Panics(t, register)
+				return
+			}
+			register()
+
+			names := []string{}
+			for _, i := range Integrations() {
+				names = append(names, i.Name)
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}